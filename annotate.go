@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// annotationMarker starts the comment inserted above each compromised lockfile entry
+const annotationMarker = "# SHAI-HULUD: compromised"
+
+// annotateLockfile returns a copy of a yarn.lock or pnpm-lock.yaml with a marker comment
+// above each entry whose name@version is in compromised, and how many markers it added
+func annotateLockfile(content []byte, format string, compromised map[string]bool) ([]byte, int) {
+	lines := strings.Split(string(content), "\n")
+	annotated := make([]string, 0, len(lines))
+	markers := 0
+	section := ""
+
+	for i, line := range lines {
+		var name, version string
+		trimmed := strings.TrimSpace(line)
+		if line == trimmed && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			section = strings.TrimSuffix(trimmed, ":")
+		}
+		switch format {
+		case "yarn":
+			if line == trimmed && strings.Contains(line, "@") && strings.HasSuffix(line, ":") {
+				name = extractPackageNameFromYarnHeader(strings.Trim(line, `":`))
+				version = yarnEntryVersion(lines[i+1:])
+			}
+		case "pnpm":
+			// Package keys sit directly under packages: and, from v9, snapshots:, where an entry
+			// without dependencies is written as `key: {}`. Older lockfiles prefix keys with a
+			// slash, v9 does not and quotes scoped names
+			if (section == "packages" || section == "snapshots") && line == "  "+trimmed {
+				key, found := strings.CutSuffix(strings.TrimSuffix(trimmed, " {}"), ":")
+				if found {
+					name, version = parsePnpmPackageKey(strings.Trim(key, `'"`))
+				}
+			}
+		}
+
+		if name != "" && compromised[name+"@"+normalizeVersion(version)] {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+			annotated = append(annotated, fmt.Sprintf("%s%s %s@%s", indent, annotationMarker, name, version))
+			markers++
+		}
+		annotated = append(annotated, line)
+	}
+	return []byte(strings.Join(annotated, "\n")), markers
+}
+
+// yarnEntryVersion returns the version field of the yarn.lock entry whose body is lines
+func yarnEntryVersion(lines []string) string {
+	for _, line := range lines {
+		field := strings.TrimSpace(line)
+		if field == "" || line == field {
+			break
+		}
+		if strings.HasPrefix(field, "version") {
+			// Classic uses `version "1.0.0"`, Berry uses `version: 1.0.0`
+			return strings.Trim(strings.TrimPrefix(field, "version"), ` ":`)
+		}
+	}
+	return ""
+}
+
+// writeAnnotatedLockfiles writes annotated copies of the yarn and pnpm lockfiles with
+// compromised findings under outDir, mirroring their paths below root. The originals are
+// never modified. It returns the paths written
+func writeAnnotatedLockfiles(results []Result, root, outDir string, extra []lockfileMapping) ([]string, error) {
+	var written []string
+	for _, result := range results {
+		format := lockfileFormat(filepath.Base(result.LockFile), extra)
+		if format != "yarn" && format != "pnpm" {
+			continue
+		}
+		compromised := make(map[string]bool)
+		for _, pkg := range result.Packages {
+			if pkg.IsAffected {
+				compromised[pkg.Name+"@"+normalizeVersion(pkg.Version)] = true
+			}
+		}
+		if len(compromised) == 0 {
+			continue
+		}
+
+		content, err := readLockfile(result.LockFile)
+		if err != nil {
+			return written, err
+		}
+		annotated, markers := annotateLockfile(content, format, compromised)
+		if markers == 0 {
+			continue
+		}
+
+		target := filepath.Join(outDir, annotatedPath(result.LockFile, root))
+		if same, _ := sameFile(target, result.LockFile); same {
+			return written, fmt.Errorf("annotated copy of %s would overwrite it, choose another output directory", result.LockFile)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(target, annotated, 0644); err != nil {
+			return written, err
+		}
+		written = append(written, target)
+	}
+	return written, nil
+}
+
+// annotatedPath is where a lockfile's annotated copy goes below the output directory: its
+// path relative to root, or its full path as nested directories when outside root
+func annotatedPath(lockfile, root string) string {
+	abs, err := filepath.Abs(lockfile)
+	if err != nil {
+		abs = lockfile
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return strings.TrimPrefix(abs, filepath.VolumeName(abs))
+}
+
+// sameFile reports whether two paths name the same existing file
+func sameFile(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that the marker is inserted directly above the compromised yarn.lock entry only
+func TestAnnotateYarnLock(t *testing.T) {
+	content := `# yarn lockfile v1
+
+"@ctrl/tinycolor@^4.1.0":
+  version "4.1.0"
+
+"@ctrl/tinycolor@^4.1.1":
+  version "4.1.1"
+  resolved "https://registry.yarnpkg.com/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz"
+`
+	annotated, markers := annotateLockfile([]byte(content), "yarn", map[string]bool{"@ctrl/tinycolor@4.1.1": true})
+	if markers != 1 {
+		t.Fatalf("Expected one marker, got %d:\n%s", markers, annotated)
+	}
+	lines := strings.Split(string(annotated), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, annotationMarker) {
+			if line != annotationMarker+" @ctrl/tinycolor@4.1.1" || lines[i+1] != `"@ctrl/tinycolor@^4.1.1":` {
+				t.Errorf("Expected the marker above the 4.1.1 entry, got %q before %q", line, lines[i+1])
+			}
+		}
+	}
+	if strings.Replace(string(annotated), annotationMarker+" @ctrl/tinycolor@4.1.1\n", "", 1) != content {
+		t.Errorf("Expected the rest of the lockfile unchanged, got:\n%s", annotated)
+	}
+}
+
+// Test that pnpm markers keep the indentation of the package key
+func TestAnnotatePnpmLock(t *testing.T) {
+	content := `lockfileVersion: 5.4
+
+packages:
+  /left-pad@1.3.0:
+    resolution: {integrity: sha512-abc}
+
+  /is-odd@3.0.1:
+    resolution: {integrity: sha512-def}
+`
+	annotated, markers := annotateLockfile([]byte(content), "pnpm", map[string]bool{"is-odd@3.0.1": true})
+	if markers != 1 || !strings.Contains(string(annotated), "\n  "+annotationMarker+" is-odd@3.0.1\n  /is-odd@3.0.1:\n") {
+		t.Errorf("Expected an indented marker above is-odd, got:\n%s", annotated)
+	}
+}
+
+// Test that v9 pnpm keys, which have no leading slash, are marked in packages and snapshots
+func TestAnnotatePnpmLockV9(t *testing.T) {
+	content := `lockfileVersion: '9.0'
+
+importers:
+
+  .:
+    dependencies:
+      '@ctrl/tinycolor':
+        specifier: ^4.1.0
+        version: 4.1.1
+
+packages:
+
+  '@ctrl/tinycolor@4.1.1':
+    resolution: {integrity: sha512-abc}
+    engines: {node: '>=14'}
+
+  left-pad@1.3.0:
+    resolution: {integrity: sha512-def}
+
+snapshots:
+
+  '@ctrl/tinycolor@4.1.1': {}
+
+  left-pad@1.3.0: {}
+`
+	annotated, markers := annotateLockfile([]byte(content), "pnpm", map[string]bool{"@ctrl/tinycolor@4.1.1": true})
+	if markers != 2 {
+		t.Fatalf("Expected markers above the packages and snapshots entries, got %d:\n%s", markers, annotated)
+	}
+	for _, entry := range []string{"'@ctrl/tinycolor@4.1.1':\n    resolution", "'@ctrl/tinycolor@4.1.1': {}"} {
+		if !strings.Contains(string(annotated), "\n  "+annotationMarker+" @ctrl/tinycolor@4.1.1\n  "+entry) {
+			t.Errorf("Expected an indented marker above %q, got:\n%s", entry, annotated)
+		}
+	}
+	if strings.Contains(string(annotated), annotationMarker+" left-pad") {
+		t.Errorf("Expected left-pad to be left unmarked, got:\n%s", annotated)
+	}
+}
+
+// Test that annotated copies mirror the lockfile paths and leave the originals alone
+func TestWriteAnnotatedLockfiles(t *testing.T) {
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "app", "yarn.lock")
+	content := "left-pad@^1.3.0:\n  version \"1.3.0\"\n"
+	if err := os.MkdirAll(filepath.Dir(lockfile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results := []Result{
+		{LockFile: lockfile, Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}},
+		{LockFile: filepath.Join(root, "package-lock.json"), Packages: []Package{{Name: "is-odd", Version: "3.0.1", IsAffected: true}}},
+	}
+
+	out := t.TempDir()
+	written, err := writeAnnotatedLockfiles(results, root, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(out, "app", "yarn.lock")
+	if len(written) != 1 || written[0] != target {
+		t.Fatalf("Expected only the yarn.lock copy at %s, got %v", target, written)
+	}
+	annotated, err := os.ReadFile(target)
+	if err != nil || !strings.HasPrefix(string(annotated), annotationMarker+" left-pad@1.3.0\nleft-pad@^1.3.0:") {
+		t.Errorf("Expected the copy to be annotated, got %q (%v)", annotated, err)
+	}
+	if original, _ := os.ReadFile(lockfile); string(original) != content {
+		t.Errorf("Expected the original lockfile untouched, got %q", original)
+	}
+
+	if _, err := writeAnnotatedLockfiles(results, root, root, nil); err == nil {
+		t.Error("Expected writing over the original lockfile to be refused")
+	}
+}
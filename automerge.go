@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// renovateConfigPaths are the Renovate config locations checked relative to the root
+var renovateConfigPaths = []string{"renovate.json", ".renovaterc", ".renovaterc.json", ".github/renovate.json"}
+
+// dependabotConfigPaths are the Dependabot config locations checked relative to the root
+var dependabotConfigPaths = []string{".github/dependabot.yml", ".github/dependabot.yaml"}
+
+// renovateConfig is the subset of a Renovate config relevant to auto-merge
+type renovateConfig struct {
+	Automerge    bool `json:"automerge"`
+	PackageRules []struct {
+		Automerge         bool     `json:"automerge"`
+		MatchUpdateTypes  []string `json:"matchUpdateTypes"`
+		MatchPackageNames []string `json:"matchPackageNames"`
+	} `json:"packageRules"`
+}
+
+// parseRenovateAutomerge returns a description of each auto-merge setting in a Renovate config
+func parseRenovateAutomerge(content []byte) ([]string, error) {
+	var config renovateConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	var settings []string
+	if config.Automerge {
+		settings = append(settings, "automerge enabled for all updates")
+	}
+	for i, rule := range config.PackageRules {
+		if !rule.Automerge {
+			continue
+		}
+		scope := "all updates"
+		if len(rule.MatchUpdateTypes) > 0 {
+			scope = strings.Join(rule.MatchUpdateTypes, "/") + " updates"
+		}
+		if len(rule.MatchPackageNames) > 0 {
+			scope += " of " + strings.Join(rule.MatchPackageNames, ", ")
+		}
+		settings = append(settings, fmt.Sprintf("packageRules[%d] automerges %s", i, scope))
+	}
+
+	return settings, nil
+}
+
+// parseDependabotAutomerge returns a description of each auto-merge setting in a Dependabot config
+func parseDependabotAutomerge(content string) []string {
+	var settings []string
+	ecosystem := ""
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch key {
+		case "package-ecosystem":
+			ecosystem = value
+		case "automerge", "auto-merge", "automerged_updates":
+			if value == "false" {
+				continue
+			}
+			setting := fmt.Sprintf("line %d: %s", i+1, key)
+			if value != "" {
+				setting += " " + value
+			}
+			if ecosystem != "" {
+				setting += fmt.Sprintf(" (%s)", ecosystem)
+			}
+			settings = append(settings, setting)
+		}
+	}
+
+	return settings
+}
+
+// checkAutomerge reports auto-merge settings in dependency update configs under rootDir as notices
+func checkAutomerge(rootDir string) []Result {
+	var results []Result
+
+	report := func(path string, settings []string) {
+		if len(settings) == 0 {
+			return
+		}
+		var notices []string
+		for _, setting := range settings {
+			notices = append(notices, "auto-merge could pull in a compromised republish: "+setting)
+		}
+		results = append(results, Result{LockFile: path, Notices: notices})
+	}
+
+	for _, rel := range renovateConfigPaths {
+		path := filepath.Join(rootDir, rel)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		settings, err := parseRenovateAutomerge(content)
+		if err != nil {
+			continue
+		}
+		report(path, settings)
+	}
+
+	for _, rel := range dependabotConfigPaths {
+		path := filepath.Join(rootDir, rel)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		report(path, parseDependabotAutomerge(string(content)))
+	}
+
+	return results
+}
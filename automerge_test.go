@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRenovateAutomerge(t *testing.T) {
+	content := `{
+		"extends": ["config:recommended"],
+		"packageRules": [
+			{"matchUpdateTypes": ["minor", "patch"], "automerge": true},
+			{"matchPackageNames": ["react"], "automerge": false}
+		]
+	}`
+
+	settings, err := parseRenovateAutomerge([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 1 || !strings.Contains(settings[0], "minor/patch") {
+		t.Errorf("Expected minor/patch automerge rule to be detected, got %v", settings)
+	}
+
+	settings, err = parseRenovateAutomerge([]byte(`{"automerge": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 1 {
+		t.Errorf("Expected global automerge to be detected, got %v", settings)
+	}
+}
+
+func TestParseDependabotAutomerge(t *testing.T) {
+	content := `version: 2
+updates:
+  - package-ecosystem: "npm"
+    directory: "/"
+    schedule:
+      interval: "weekly"
+    automerged_updates:
+      - match:
+          update_type: "semver:minor"
+  - package-ecosystem: "github-actions"
+    directory: "/"
+    # auto-merge: true
+    auto-merge: false
+`
+
+	settings := parseDependabotAutomerge(content)
+	if len(settings) != 1 || !strings.Contains(settings[0], "automerged_updates") || !strings.Contains(settings[0], "(npm)") {
+		t.Errorf("Expected npm automerged_updates to be detected, got %v", settings)
+	}
+}
+
+// Test that auto-merge settings are reported as notices for both config formats
+func TestCheckAutomerge(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".github"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "renovate.json"), []byte(`{"automerge": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dependabot := "updates:\n  - package-ecosystem: npm\n    automerge: true\n"
+	if err := os.WriteFile(filepath.Join(root, ".github", "dependabot.yml"), []byte(dependabot), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkAutomerge(root)
+	if len(results) != 2 {
+		t.Fatalf("Expected results for both configs, got %+v", results)
+	}
+	for _, result := range results {
+		if len(result.Packages) != 0 || len(result.Notices) == 0 {
+			t.Errorf("Expected informational notices only, got %+v", result)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Baseline file formats accepted by --baseline-format
+const (
+	baselineFormatJSON    = "json"
+	baselineFormatDigests = "digests"
+)
+
+// BaselineEntry records one accepted finding in a JSON baseline
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	LockFile    string `json:"lockFile"`
+	Package     string `json:"package"`
+	Version     string `json:"version"`
+}
+
+// Baseline is the JSON baseline file: findings accepted as known, sorted by fingerprint
+type Baseline struct {
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// findingKind names what a finding reports, so a package that turns from a warning into a
+// compromised match is not hidden by its earlier baseline entry
+func findingKind(pkg Package) string {
+	switch {
+	case pkg.IsAffected:
+		return "affected"
+	case pkg.IsWarning:
+		return "warning"
+	case pkg.IsSuspicious:
+		return "suspicious"
+	}
+	return ""
+}
+
+// baselineLockFile returns a lockfile path relative to root in forward-slash form, so
+// fingerprints match wherever the repository is checked out
+func baselineLockFile(lockFile, root string) string {
+	if absPath, err := filepath.Abs(lockFile); err == nil {
+		if rel, err := filepath.Rel(root, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+			lockFile = rel
+		}
+	}
+	return filepath.ToSlash(lockFile)
+}
+
+// findingFingerprint identifies a finding by its lockfile, package, version and kind
+func findingFingerprint(lockFile string, pkg Package) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{lockFile, pkg.Name, pkg.Version, findingKind(pkg)}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// buildBaseline collects the fingerprints of every finding in results
+func buildBaseline(results []Result, root string) Baseline {
+	baseline := Baseline{Findings: []BaselineEntry{}}
+	seen := make(map[string]bool)
+	for _, result := range results {
+		lockFile := baselineLockFile(result.LockFile, root)
+		for _, pkg := range result.Packages {
+			if findingKind(pkg) == "" {
+				continue
+			}
+			fingerprint := findingFingerprint(lockFile, pkg)
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			baseline.Findings = append(baseline.Findings, BaselineEntry{
+				Fingerprint: fingerprint,
+				LockFile:    lockFile,
+				Package:     pkg.Name,
+				Version:     pkg.Version,
+			})
+		}
+	}
+	sort.Slice(baseline.Findings, func(i, j int) bool {
+		return baseline.Findings[i].Fingerprint < baseline.Findings[j].Fingerprint
+	})
+	return baseline
+}
+
+// writeBaseline writes the baseline as JSON or as a digests file with one fingerprint per line
+func writeBaseline(path, format string, baseline Baseline) error {
+	var content []byte
+	switch format {
+	case baselineFormatJSON:
+		output, err := json.MarshalIndent(baseline, "", "  ")
+		if err != nil {
+			return err
+		}
+		content = append(output, '\n')
+	case baselineFormatDigests:
+		var buf bytes.Buffer
+		for _, entry := range baseline.Findings {
+			buf.WriteString(entry.Fingerprint + "\n")
+		}
+		content = buf.Bytes()
+	default:
+		return fmt.Errorf("invalid baseline format '%s'. Valid options: json, digests", format)
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// loadBaseline reads the fingerprints from a baseline in either format. JSON baselines start
+// with '{'; anything else is read as digests, ignoring blank lines and # comments
+func loadBaseline(path string) (map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make(map[string]bool)
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		var baseline Baseline
+		if err := json.Unmarshal(content, &baseline); err != nil {
+			return nil, err
+		}
+		for _, entry := range baseline.Findings {
+			fingerprints[entry.Fingerprint] = true
+		}
+		return fingerprints, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fingerprints[line] = true
+	}
+	return fingerprints, scanner.Err()
+}
+
+// filterResultsByBaseline drops findings recorded in the baseline and recomputes the result flags
+func filterResultsByBaseline(results []Result, baseline map[string]bool, root string) ([]Result, bool, bool) {
+	var filtered []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, result := range results {
+		lockFile := baselineLockFile(result.LockFile, root)
+		var packages []Package
+		for _, pkg := range result.Packages {
+			if findingKind(pkg) != "" && baseline[findingFingerprint(lockFile, pkg)] {
+				continue
+			}
+			packages = append(packages, pkg)
+			if pkg.IsAffected {
+				anyAffected = true
+			}
+			if pkg.IsWarning {
+				anyWarnings = true
+			}
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			result.Packages = packages
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered, anyAffected, anyWarnings
+}
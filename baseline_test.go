@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test that JSON and digests baselines both round-trip and suppress the same findings
+func TestBaselineFormatsRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "app", "package-lock.json")
+	results := []Result{
+		{
+			LockFile: lockfile,
+			Packages: []Package{
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+				{Name: "is-odd", Version: "3.0.1", IsWarning: true},
+				{Name: "package-lock.json", Notice: "informational"},
+			},
+		},
+	}
+
+	var suppressed [][]Result
+	for _, format := range []string{baselineFormatJSON, baselineFormatDigests} {
+		path := filepath.Join(t.TempDir(), "baseline")
+		if err := writeBaseline(path, format, buildBaseline(results, root)); err != nil {
+			t.Fatal(err)
+		}
+		if format == baselineFormatDigests {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 2 {
+				t.Errorf("Expected one fingerprint per finding, got %q", content)
+			}
+		}
+
+		baseline, err := loadBaseline(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(baseline) != 2 {
+			t.Errorf("%s: expected 2 fingerprints, got %d", format, len(baseline))
+		}
+
+		// A newly introduced finding is still reported
+		scanned := []Result{{LockFile: lockfile, Packages: append(append([]Package{}, results[0].Packages...),
+			Package{Name: "chalk", Version: "5.6.1", IsAffected: true})}}
+		filtered, anyAffected, anyWarnings := filterResultsByBaseline(scanned, baseline, root)
+		if !anyAffected || anyWarnings {
+			t.Errorf("%s: expected only the new compromised package to remain, got affected=%v warnings=%v", format, anyAffected, anyWarnings)
+		}
+		suppressed = append(suppressed, filtered)
+	}
+
+	if !reflect.DeepEqual(suppressed[0], suppressed[1]) {
+		t.Errorf("Expected both formats to suppress the same findings, got %+v and %+v", suppressed[0], suppressed[1])
+	}
+	if packages := suppressed[0][0].Packages; len(packages) != 2 || packages[1].Name != "chalk" {
+		t.Errorf("Expected the notice and chalk to remain, got %+v", packages)
+	}
+}
+
+// Test that a package turning from a warning into a compromised match is not suppressed
+func TestBaselineKindChange(t *testing.T) {
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "package-lock.json")
+	baseline := make(map[string]bool)
+	for _, entry := range buildBaseline([]Result{{LockFile: lockfile, Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsWarning: true}}}}, root).Findings {
+		baseline[entry.Fingerprint] = true
+	}
+
+	_, anyAffected, _ := filterResultsByBaseline([]Result{{LockFile: lockfile, Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}}}, baseline, root)
+	if !anyAffected {
+		t.Error("Expected the compromised finding to survive a warning's baseline entry")
+	}
+}
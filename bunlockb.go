@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// bunLockbMagic opens every binary bun.lockb
+const bunLockbMagic = "#!/usr/bin/env bun\nbun-lockfile-format-v0\n"
+
+// Reasons a binary bun.lockb is checked through its tarball URLs, opening the scan's notice
+const (
+	bunUnavailableReason  = "bun is not available (see --bun-bin)"
+	bunExportFailedReason = "bun could not export this lockfile"
+)
+
+// bunLockbTarballRegex finds the registry tarball URLs in a bun.lockb string table, where
+// strings are stored back to back without separators
+var bunLockbTarballRegex = regexp.MustCompile(`https?://[!-~]+?\.tgz`)
+
+// parseBunLockb scans a binary bun.lockb by having bun print it as a Yarn v1 lockfile, which
+// `bun bun.lockb` does, and parsing that with the yarn.lock parser. Without bun, or when bun
+// cannot export the lockfile, the tarball URLs the lockfile records are checked instead.
+// bun runs on a copy of the lockfile in an empty temporary directory, so that the scanned
+// repository's bunfig.toml and .env files, which bun loads from its working directory, are
+// never read
+func parseBunLockb(lockfile string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	if opts.bunBinary == "" {
+		return parseBunLockbTarballs(lockfile, bunUnavailableReason, affected, opts, stats)
+	}
+	fallback := func(reason string) ([]Package, []string, bool, bool) {
+		return parseBunLockbTarballs(lockfile, fmt.Sprintf("%s (%s)", bunExportFailedReason, reason), affected, opts, stats)
+	}
+
+	dir, err := os.MkdirTemp("", "shai-hulud-bun-")
+	if err != nil {
+		return fallback(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	content, err := os.ReadFile(lockfile)
+	if err != nil {
+		return fallback(err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bun.lockb"), content, 0644); err != nil {
+		return fallback(err.Error())
+	}
+
+	cmd := exec.Command(opts.bunBinary, "bun.lockb")
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fallback(message)
+		}
+		return fallback(err.Error())
+	}
+
+	exported := filepath.Join(dir, "yarn.lock")
+	if err := os.WriteFile(exported, output, 0644); err != nil {
+		return fallback(err.Error())
+	}
+	return parseYarnLock(exported, affected, opts, stats)
+}
+
+// parseBunLockbTarballs checks a binary bun.lockb without bun, reading name@version from each
+// registry tarball URL in its string table. Packages whose URL bun did not record are missed,
+// so a notice carrying reason always says the scan was partial, and the file counts as a
+// warning so that it never passes as clean
+func parseBunLockbTarballs(lockfile, reason string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	comparator := versionComparatorFor("bun")
+
+	content, err := readLockfile(lockfile)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("%s and it could not be read (%v), it was not scanned", reason, err)}, false, true
+	}
+	if !bytes.HasPrefix(content, []byte(bunLockbMagic)) {
+		return nil, []string{fmt.Sprintf("%s and it is not a binary bun lockfile, it was not scanned", reason)}, false, true
+	}
+	stats.fileParsed()
+
+	var packages []Package
+	hasAffected := false
+	seen := make(map[string]bool)
+	for _, match := range bunLockbTarballRegex.FindAll(content, -1) {
+		name, file, ok := splitRegistryTarball(string(match))
+		if !ok {
+			continue
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(file, name[strings.LastIndex(name, "/")+1:]+"-"), ".tgz")
+		if _, valid := parseSemver(version); !valid || seen[name+"@"+version] {
+			continue
+		}
+		seen[name+"@"+version] = true
+
+		stats.packageEnumerated(name, version)
+		stats.mapLookup()
+		opts.trace.record(lockfile, name, version, affected)
+		pkg, ok := checkPackage(comparator, name, version, affected)
+		if !ok {
+			continue
+		}
+		packages = append(packages, pkg)
+		hasAffected = hasAffected || pkg.IsAffected
+	}
+
+	notice := fmt.Sprintf("%s, so only the registry tarballs it records were checked (%d found)", reason, len(seen))
+	return packages, []string{notice}, hasAffected, true
+}
+
+// incompleteScan reports whether the notices say a binary bun.lockb was checked only through
+// its tarball URLs, or a bun lockfile was not scanned at all
+func incompleteScan(notices []string) bool {
+	for _, notice := range notices {
+		if strings.HasPrefix(notice, bunUnavailableReason) || strings.HasPrefix(notice, bunExportFailedReason) ||
+			strings.HasPrefix(notice, bunUnparseableReason) {
+			return true
+		}
+	}
+	return false
+}
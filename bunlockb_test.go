@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Test that bun.lockb is exported through a bun executable on PATH and its packages checked
+func TestParseBunLockbViaBun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub bun is a shell script")
+	}
+
+	bin := t.TempDir()
+	stub := `#!/bin/sh
+if [ -e bunfig.toml ] || [ -e .env ]; then
+  echo "ran beside the project's bunfig.toml and .env" >&2
+  exit 1
+fi
+if [ "$1" != "bun.lockb" ] || ! grep -q bun-lockfile-format bun.lockb; then
+  echo "unexpected invocation: $*" >&2
+  exit 1
+fi
+cat <<'LOCK'
+# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+# bun ./bun.lockb --hash: 0123456789ABCDEF
+
+
+chalk@^5.6.0:
+  version "5.6.1"
+  resolved "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"
+  integrity sha512-AAAA
+LOCK
+`
+	if err := os.WriteFile(filepath.Join(bin, "bun"), []byte(stub), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	project := t.TempDir()
+	lockfile := filepath.Join(project, "bun.lockb")
+	if err := os.WriteFile(lockfile, []byte("#!/usr/bin/env bun\nbun-lockfile-format-v0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// bun loads these from its working directory, which must not be the scanned repository
+	for _, name := range []string{"bunfig.toml", ".env"} {
+		if err := os.WriteFile(filepath.Join(project, name), []byte("# untrusted\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	affected := map[string]map[string]bool{"chalk": {"5.6.1": true}}
+
+	if packages, notices, hasAffected, hasWarnings := parseLockfileAs(lockfile, "bun", affected, scanOptions{}, nil); hasAffected || !hasWarnings || len(packages) != 0 ||
+		len(notices) != 1 || !strings.Contains(notices[0], "were checked (0 found)") {
+		t.Errorf("Expected a partial scan notice counted as a warning without --bun-bin, got %+v %q", packages, notices)
+	}
+	if results, _, anyWarnings := scanLockfiles([]string{lockfile}, affected, nil, scanOptions{}, nil); !anyWarnings || len(results) != 1 || !results[0].Incomplete {
+		t.Errorf("Expected a partial scan with no matches not to pass as clean, got %+v", results)
+	}
+
+	opts := scanOptions{bunBinary: filepath.Join(bin, "bun")}
+	packages, _, hasAffected, _ := parseLockfileAs(lockfile, "bun", affected, opts, nil)
+	if !hasAffected || len(packages) != 1 || packages[0].Name != "chalk" || packages[0].Version != "5.6.1" {
+		t.Errorf("Expected chalk@5.6.1 to be found through bun, got %+v", packages)
+	}
+
+	// A lockfile bun cannot read is reported instead of passing as clean
+	if err := os.WriteFile(lockfile, []byte("not a lockfile\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	packages, notices, hasAffected, _ := parseBunLockb(lockfile, affected, opts, nil)
+	if hasAffected || len(packages) != 0 || len(notices) != 1 || !strings.Contains(notices[0], "unexpected invocation") {
+		t.Errorf("Expected a notice carrying bun's error, got %+v %q", packages, notices)
+	}
+}
+
+// Test that without bun a binary bun.lockb is checked through the tarball URLs it records
+func TestParseBunLockbTarballs(t *testing.T) {
+	var content bytes.Buffer
+	content.WriteString(bunLockbMagic)
+	content.Write([]byte{0x02, 0x00, 0x00, 0x00, 0x9c, 0x01, 0xff, 0x00})
+	// String table entries are stored back to back
+	content.WriteString("chalkhttps://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz")
+	content.WriteString("https://registry.npmjs.org/@ctrl%2ftinycolor/-/tinycolor-4.1.1.tgz\x00\x01")
+	content.WriteString("https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgzleft-pad")
+	lockfile := filepath.Join(t.TempDir(), "bun.lockb")
+	if err := os.WriteFile(lockfile, content.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	affected := map[string]map[string]bool{
+		"chalk":           {"5.6.1": true},
+		"@ctrl/tinycolor": {"4.1.2": true},
+	}
+
+	packages, notices, hasAffected, hasWarnings := parseLockfileAs(lockfile, "bun", affected, scanOptions{}, nil)
+	if !hasAffected || !hasWarnings || len(packages) != 2 || len(notices) != 1 {
+		t.Fatalf("Expected chalk compromised, tinycolor warned and a notice, got %+v %q", packages, notices)
+	}
+	if packages[0].Name != "chalk" || !packages[0].IsAffected || packages[1].Name != "@ctrl/tinycolor" || !packages[1].IsWarning {
+		t.Errorf("Expected findings read from the tarball URLs, got %+v", packages)
+	}
+	if !strings.Contains(notices[0], "bun is not available") || !strings.Contains(notices[0], "were checked (2 found)") {
+		t.Errorf("Expected a partial scan notice, got %q", notices[0])
+	}
+
+	if err := os.WriteFile(lockfile, []byte(`{"lockfileVersion": 0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	packages, notices, _, _ = parseLockfileAs(lockfile, "bun", affected, scanOptions{}, nil)
+	if len(packages) != 0 || len(notices) != 1 || !strings.Contains(notices[0], "not a binary bun lockfile, it was not scanned") {
+		t.Errorf("Expected an unrecognized file to be reported as not scanned, got %+v %q", packages, notices)
+	}
+}
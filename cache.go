@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// yarnCacheEntryRegex matches Yarn Berry cache filenames such as
+// lodash-npm-4.17.21-6382451519-eb835a2e51.zip or @babel-core-npm-7.20.0-abc123-def456.zip
+var yarnCacheEntryRegex = regexp.MustCompile(`^(.+)-npm-([0-9]+\.[0-9]+\.[0-9]+)-([0-9a-f]+)(?:-([0-9a-f]+))?\.zip$`)
+
+// findCacheDirs finds Yarn Berry caches (.yarn/cache) and pnpm stores (.pnpm-store) under rootDir
+func findCacheDirs(rootDir string) (yarnCaches, pnpmStores []string, err error) {
+	err = filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip inaccessible files
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		switch {
+		case d.Name() == "node_modules":
+			return filepath.SkipDir
+		case d.Name() == "cache" && filepath.Base(filepath.Dir(path)) == ".yarn":
+			yarnCaches = append(yarnCaches, path)
+			return filepath.SkipDir
+		case d.Name() == ".pnpm-store":
+			pnpmStores = append(pnpmStores, path)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return yarnCaches, pnpmStores, err
+}
+
+// scanCaches scans all package manager caches found under rootDir
+func scanCaches(rootDir string, affected map[string]map[string]bool) ([]Result, bool, bool) {
+	var results []Result
+	anyAffected := false
+	anyWarnings := false
+
+	yarnCaches, pnpmStores, err := findCacheDirs(rootDir)
+	if err != nil {
+		return results, anyAffected, anyWarnings
+	}
+
+	collect := func(dir string, packages []Package, hasAffected, hasWarnings bool) {
+		if len(packages) > 0 {
+			results = append(results, Result{
+				LockFile: dir,
+				Packages: packages,
+			})
+		}
+		if hasAffected {
+			anyAffected = true
+		}
+		if hasWarnings {
+			anyWarnings = true
+		}
+	}
+
+	for _, dir := range yarnCaches {
+		packages, hasAffected, hasWarnings := scanYarnCache(dir, affected)
+		collect(dir, packages, hasAffected, hasWarnings)
+	}
+	for _, dir := range pnpmStores {
+		packages, hasAffected, hasWarnings := scanPnpmStore(dir, affected)
+		collect(dir, packages, hasAffected, hasWarnings)
+	}
+
+	return results, anyAffected, anyWarnings
+}
+
+// scanYarnCache inspects .yarn/cache/*.zip filenames and verifies their checksums
+func scanYarnCache(cacheDir string, affected map[string]map[string]bool) ([]Package, bool, bool) {
+	comparator := versionComparatorFor("yarn")
+	var packages []Package
+	hasAffected := false
+	hasWarnings := false
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return packages, hasAffected, hasWarnings
+	}
+
+	// Yarn flattens "@scope/name" into "@scope-name" in cache filenames
+	slugs := make(map[string]string)
+	for name := range affected {
+		slugs[strings.Replace(name, "/", "-", 1)] = name
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := yarnCacheEntryRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		name, ok := slugs[matches[1]]
+		if !ok {
+			continue
+		}
+		version := matches[2]
+		checksum := matches[4]
+
+		pkg, ok := checkPackage(comparator, name, version, affected)
+		if checksum != "" && !yarnCacheChecksumMatches(filepath.Join(cacheDir, entry.Name()), checksum) {
+			if !ok {
+				pkg = Package{Name: name, Version: version}
+				ok = true
+			}
+			pkg.ChecksumMismatch = true
+			pkg.IsWarning = !pkg.IsAffected
+		}
+		if !ok {
+			continue
+		}
+
+		packages = append(packages, pkg)
+		if pkg.IsAffected {
+			hasAffected = true
+		}
+		if pkg.IsWarning {
+			hasWarnings = true
+		}
+	}
+
+	return packages, hasAffected, hasWarnings
+}
+
+// yarnCacheChecksumMatches checks that the sha512 of a cache archive starts with the checksum in its filename
+func yarnCacheChecksumMatches(path, checksum string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	hash := sha512.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(hex.EncodeToString(hash.Sum(nil)), checksum)
+}
+
+// pnpmStoreIndex is the subset of a pnpm store *-index.json file we care about
+type pnpmStoreIndex struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Files   map[string]struct {
+		Integrity string `json:"integrity"`
+	} `json:"files"`
+}
+
+// scanPnpmStore inspects the pnpm content-addressable store index and verifies stored file hashes
+func scanPnpmStore(storeDir string, affected map[string]map[string]bool) ([]Package, bool, bool) {
+	comparator := versionComparatorFor("pnpm")
+	var packages []Package
+	hasAffected := false
+	hasWarnings := false
+
+	filepath.WalkDir(storeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), "-index.json") {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		var index pnpmStoreIndex
+		if err := json.Unmarshal(content, &index); err != nil || index.Name == "" || index.Version == "" {
+			return nil
+		}
+
+		pkg, ok := checkPackage(comparator, index.Name, index.Version, affected)
+		if _, listed := affected[index.Name]; listed && !pnpmStoreFilesMatch(path, index) {
+			if !ok {
+				pkg = Package{Name: index.Name, Version: index.Version}
+				ok = true
+			}
+			pkg.ChecksumMismatch = true
+			pkg.IsWarning = !pkg.IsAffected
+		}
+		if !ok {
+			return nil
+		}
+
+		packages = append(packages, pkg)
+		if pkg.IsAffected {
+			hasAffected = true
+		}
+		if pkg.IsWarning {
+			hasWarnings = true
+		}
+		return nil
+	})
+
+	return packages, hasAffected, hasWarnings
+}
+
+// pnpmStoreFilesMatch verifies the content files referenced by an index against their recorded integrity
+func pnpmStoreFilesMatch(indexPath string, index pnpmStoreIndex) bool {
+	// Content files live in <store>/<version>/files/xx/<rest-of-hex>
+	filesDir := filepath.Dir(filepath.Dir(indexPath))
+
+	for _, file := range index.Files {
+		if !strings.HasPrefix(file.Integrity, "sha512-") {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(file.Integrity, "sha512-"))
+		if err != nil {
+			return false
+		}
+		hexDigest := hex.EncodeToString(digest)
+
+		content, err := os.ReadFile(filepath.Join(filesDir, hexDigest[:2], hexDigest[2:]))
+		if err != nil {
+			continue // Missing content files are not stored locally
+		}
+
+		sum := sha512.Sum512(content)
+		if hex.EncodeToString(sum[:]) != hexDigest {
+			return false
+		}
+	}
+
+	return true
+}
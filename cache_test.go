@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a compromised package planted in .yarn/cache is flagged
+func TestScanCachesYarnCache(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(root, ".yarn", "cache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := []byte("PK\x03\x04 planted archive")
+	sum := sha512.Sum512(archive)
+	checksum := hex.EncodeToString(sum[:])[:10]
+
+	files := map[string][]byte{
+		"@scoped-package-npm-2.0.0-6382451519-" + checksum + ".zip": archive,
+		"left-pad-npm-1.3.0-1234567890-0000000000.zip":              []byte("tampered"),
+		"safe-package-npm-1.0.0-1234567890-abcdef0123.zip":          []byte("ignored"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(cacheDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected := map[string]map[string]bool{
+		"@scoped/package": {"2.0.0": true},
+		"left-pad":        {"1.2.0": true},
+	}
+
+	results, anyAffected, anyWarnings := scanCaches(root, affected)
+
+	if !anyAffected {
+		t.Error("Expected planted cache entry to be flagged as affected")
+	}
+	if !anyWarnings {
+		t.Error("Expected checksum mismatch to be reported as a warning")
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 cache result, got %d", len(results))
+	}
+	if results[0].LockFile != cacheDir {
+		t.Errorf("Expected result for %s, got %s", cacheDir, results[0].LockFile)
+	}
+
+	found := make(map[string]Package)
+	for _, pkg := range results[0].Packages {
+		found[pkg.Name] = pkg
+	}
+
+	if pkg, ok := found["@scoped/package"]; !ok || !pkg.IsAffected || pkg.ChecksumMismatch {
+		t.Errorf("Expected @scoped/package@2.0.0 to be affected with a valid checksum, got %+v", pkg)
+	}
+	if pkg, ok := found["left-pad"]; !ok || !pkg.ChecksumMismatch || !pkg.IsWarning {
+		t.Errorf("Expected left-pad to be reported with a checksum mismatch, got %+v", pkg)
+	}
+	if _, ok := found["safe-package"]; ok {
+		t.Error("Expected safe-package to be ignored")
+	}
+}
+
+// Test that pnpm store index entries are matched against the affected list
+func TestScanCachesPnpmStore(t *testing.T) {
+	root := t.TempDir()
+	indexDir := filepath.Join(root, ".pnpm-store", "v3", "files", "ab")
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	index := `{"name": "left-pad", "version": "1.3.0", "files": {}}`
+	if err := os.WriteFile(filepath.Join(indexDir, "cdef-index.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+	}
+
+	results, anyAffected, _ := scanCaches(root, affected)
+
+	if !anyAffected {
+		t.Error("Expected pnpm store entry to be flagged as affected")
+	}
+	if len(results) != 1 || len(results[0].Packages) != 1 {
+		t.Fatalf("Expected 1 flagged package, got %+v", results)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedLockfiles keeps the lockfiles that changed since ref, or whose sibling package.json
+// did, according to git. Changes in the working tree and untracked files count as changes.
+// It fails when a lockfile is not inside a git repository or git cannot diff against ref, so
+// the caller can fall back to scanning everything
+func changedLockfiles(lockfiles []string, ref string) ([]string, error) {
+	changedByRepo := make(map[string]map[string]bool)
+	var changed []string
+
+	for _, lockfile := range lockfiles {
+		abs, err := filepath.Abs(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		repoRoot := findRepoRoot(filepath.Dir(abs))
+		if repoRoot == "" {
+			return nil, fmt.Errorf("%s is not inside a git repository", lockfile)
+		}
+
+		files, ok := changedByRepo[repoRoot]
+		if !ok {
+			files, err = gitChangedFiles(repoRoot, ref)
+			if err != nil {
+				return nil, err
+			}
+			changedByRepo[repoRoot] = files
+		}
+
+		rel, err := filepath.Rel(repoRoot, abs)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		if files[rel] || files[pathDir(rel)+"package.json"] {
+			changed = append(changed, lockfile)
+		}
+	}
+
+	return changed, nil
+}
+
+// gitChangedFiles lists the files in the repository at repoRoot that differ from ref,
+// including untracked ones, as forward-slash paths relative to repoRoot
+func gitChangedFiles(repoRoot, ref string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	commands := [][]string{
+		{"diff", "--name-only", "--relative", ref, "--"},
+		{"ls-files", "--others", "--exclude-standard"},
+	}
+	for _, args := range commands {
+		output, err := exec.Command("git", append([]string{"-C", repoRoot}, args...)...).Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				return nil, fmt.Errorf("git %s in %s: %s", args[0], repoRoot, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return nil, fmt.Errorf("git %s in %s: %w", args[0], repoRoot, err)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files[line] = true
+			}
+		}
+	}
+	return files, nil
+}
+
+// pathDir returns the directory part of a forward-slash path including its trailing slash,
+// or "" for a top-level file
+func pathDir(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i+1]
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Test that only the lockfile changed since the base commit is kept
+func TestChangedLockfiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	write := func(rel, content string) string {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	api := write("apps/api/package-lock.json", `{"lockfileVersion": 3}`)
+	web := write("apps/web/yarn.lock", "# yarn lockfile v1\n")
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+
+	write("apps/web/yarn.lock", "# yarn lockfile v1\n\nchalk@^5.6.1:\n  version \"5.6.1\"\n")
+	changed, err := changedLockfiles([]string{api, web}, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{web}) {
+		t.Errorf("Expected only %s to have changed, got %v", web, changed)
+	}
+
+	// A changed sibling manifest pulls its lockfile in too
+	write("apps/api/package.json", `{"name": "api"}`)
+	changed, err = changedLockfiles([]string{api, web}, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{api, web}) {
+		t.Errorf("Expected both lockfiles to have changed, got %v", changed)
+	}
+
+	if _, err := changedLockfiles([]string{api}, "no-such-ref"); err == nil {
+		t.Error("Expected an unknown ref to fail so the scan falls back to every lockfile")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+// VersionComparator orders versions and matches them against ranges using one ecosystem's
+// version semantics, so range logic is not tied to npm semver as more ecosystems are added
+type VersionComparator interface {
+	// Compare returns -1, 0 or 1 as a sorts before, equal to or after b
+	Compare(a, b string) int
+	// Satisfies reports whether version is inside rng
+	Satisfies(version, rng string) bool
+	// ValidRange reports whether rng is a range this ecosystem understands
+	ValidRange(rng string) bool
+}
+
+// npmSemverComparator implements npm's node-semver rules, shared by npm, yarn, pnpm and bun
+type npmSemverComparator struct{}
+
+// Compare orders by semver precedence, falling back to dotted numeric order for versions
+// that are not full semver, such as 1.2
+func (npmSemverComparator) Compare(a, b string) int {
+	versionA, okA := parseSemver(a)
+	versionB, okB := parseSemver(b)
+	if okA && okB {
+		return compareSemver(versionA, versionB)
+	}
+	return compareVersions(a, b)
+}
+
+func (npmSemverComparator) Satisfies(version, rng string) bool {
+	return satisfiesRange(version, rng)
+}
+
+func (npmSemverComparator) ValidRange(rng string) bool {
+	_, ok := parseRange(rng)
+	return ok
+}
+
+// versionComparators maps a lockfile format to its ecosystem's comparator
+var versionComparators = map[string]VersionComparator{
+	"npm":  npmSemverComparator{},
+	"yarn": npmSemverComparator{},
+	"pnpm": npmSemverComparator{},
+	"bun":  npmSemverComparator{},
+}
+
+// versionComparatorFor returns the comparator for a lockfile format, npm semver when the
+// format has none of its own
+func versionComparatorFor(format string) VersionComparator {
+	if comparator, ok := versionComparators[format]; ok {
+		return comparator
+	}
+	return npmSemverComparator{}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Test that every lockfile type gets its ecosystem's comparator, npm semver for the npm family
+func TestVersionComparatorFor(t *testing.T) {
+	for _, lockfile := range []string{"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "shrinkwrap.yaml", "bun.lock", "bun.lockb"} {
+		format := lockfileFormat(filepath.Base(lockfile), nil)
+		if _, ok := versionComparatorFor(format).(npmSemverComparator); !ok {
+			t.Errorf("Expected the npm semver comparator for %s (%q), got %T", lockfile, format, versionComparatorFor(format))
+		}
+	}
+	if _, ok := versionComparatorFor("").(npmSemverComparator); !ok {
+		t.Errorf("Expected unknown formats to default to npm semver")
+	}
+}
+
+// Test npm semver ordering and range matching through the comparator interface
+func TestNPMSemverComparator(t *testing.T) {
+	var comparator VersionComparator = npmSemverComparator{}
+
+	compareTests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3-rc.1", "1.2.3", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2", "1.2.1", -1}, // not full semver, compared numerically
+	}
+	for _, test := range compareTests {
+		if got := comparator.Compare(test.a, test.b); got != test.expected {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.a, test.b, got, test.expected)
+		}
+	}
+
+	if !comparator.Satisfies("2.1.0", "^2.0.0") || comparator.Satisfies("3.0.0", "^2.0.0") {
+		t.Errorf("Expected ^2.0.0 to match 2.1.0 but not 3.0.0")
+	}
+	if !comparator.ValidRange(">=1.0.0 <2") || comparator.ValidRange("not a range") {
+		t.Errorf("Expected range validation to follow npm semver")
+	}
+}
+
+// stubComparator matches every version against every range, to observe which comparator
+// listedVersion consults
+type stubComparator struct{ npmSemverComparator }
+
+func (stubComparator) Satisfies(version, rng string) bool {
+	return true
+}
+
+// Test that listed ranges are matched with the comparator passed in rather than npm semver
+func TestListedVersionUsesComparator(t *testing.T) {
+	versions := map[string]bool{">=2.0.0 <3.0.0": true}
+	if _, ok := listedVersion(npmSemverComparator{}, versions, "1.0.0"); ok {
+		t.Error("Expected npm semver to keep 1.0.0 outside >=2.0.0 <3.0.0")
+	}
+	if listed, ok := listedVersion(stubComparator{}, versions, "1.0.0"); !ok || listed != ">=2.0.0 <3.0.0" {
+		t.Errorf("Expected the stub comparator to decide the match, got %q, %v", listed, ok)
+	}
+	if pkg, ok := checkPackage(stubComparator{}, "left-pad", "1.0.0", map[string]map[string]bool{"left-pad": versions}); !ok || !pkg.IsAffected {
+		t.Errorf("Expected checkPackage to match through the stub comparator, got %+v", pkg)
+	}
+}
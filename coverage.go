@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Coverage statuses and skip reasons recorded for each discovered file
+const (
+	coverageScanned = "scanned"
+	coverageSkipped = "skipped"
+
+	skipExcluded   = "excluded"
+	skipNotSampled = "not sampled"
+	skipSuperseded = "superseded by npm-shrinkwrap.json"
+	skipParseError = "parse error"
+	skipNoLockfile = "no lockfile"
+)
+
+// CoverageEntry records what happened to one discovered lockfile or manifest
+type CoverageEntry struct {
+	Path    string `json:"path"`
+	Manager string `json:"manager,omitempty"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Coverage lists every lockfile and lockfile-less manifest found under the scanned roots,
+// so an audit can show that nothing was dropped silently
+type Coverage struct {
+	Root    string          `json:"root"`
+	Entries []CoverageEntry `json:"entries"`
+}
+
+// buildCoverage walks the roots for every lockfile of the selected managers, ignoring
+// include and exclude patterns, and records whether each one was scanned. discovered are
+// the lockfiles that passed the patterns, scanned those actually parsed, and results the
+// raw parser results used to spot files that failed to parse. Manifests are only listed
+// when they have no lockfile beside them, outside node_modules
+func buildCoverage(roots, managers []string, extra []lockfileMapping, discovered, scanned []string, results []Result, root string) Coverage {
+	absSet := func(paths []string) map[string]bool {
+		set := make(map[string]bool)
+		for _, path := range paths {
+			if abs, err := filepath.Abs(path); err == nil {
+				set[abs] = true
+			}
+		}
+		return set
+	}
+	discoveredSet, scannedSet := absSet(discovered), absSet(scanned)
+
+	parseErrors := make(map[string]bool)
+	for _, result := range results {
+		for _, notice := range result.Notices {
+			if strings.HasPrefix(notice, "parse error:") {
+				if abs, err := filepath.Abs(result.LockFile); err == nil {
+					parseErrors[abs] = true
+				}
+			}
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, manager := range managers {
+		selected[manager] = true
+	}
+
+	coverage := Coverage{Root: root, Entries: []CoverageEntry{}}
+	seen := make(map[string]bool)
+	for _, walkRoot := range roots {
+		lockfileDirs := make(map[string]bool)
+		var manifests []string
+		filepath.WalkDir(walkRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			abs, err := filepath.Abs(path)
+			if err != nil || seen[abs] {
+				return nil
+			}
+
+			if d.Name() == "package.json" {
+				if !strings.Contains(filepath.ToSlash(path), "node_modules/") {
+					manifests = append(manifests, path)
+				}
+				return nil
+			}
+			format := lockfileFormat(d.Name(), extra)
+			if format == "" || !selected[format] {
+				return nil
+			}
+			seen[abs] = true
+			lockfileDirs[filepath.Dir(abs)] = true
+
+			entry := CoverageEntry{Path: path, Manager: format, Status: coverageSkipped}
+			switch {
+			case scannedSet[abs]:
+				entry.Status, entry.Reason = coverageScanned, ""
+				if reason := lockfileSkipReason(path, format, parseErrors[abs]); reason != "" {
+					entry.Status, entry.Reason = coverageSkipped, reason
+				}
+			case discoveredSet[abs]:
+				entry.Reason = skipNotSampled
+			case d.Name() == "package-lock.json" && fileExists(filepath.Join(filepath.Dir(path), "npm-shrinkwrap.json")) && selected["npm"]:
+				entry.Reason = skipSuperseded
+			default:
+				entry.Reason = skipExcluded
+			}
+			coverage.Entries = append(coverage.Entries, entry)
+			return nil
+		})
+
+		for _, manifest := range manifests {
+			abs, err := filepath.Abs(manifest)
+			if err != nil || seen[abs] || lockfileDirs[filepath.Dir(abs)] {
+				continue
+			}
+			seen[abs] = true
+			coverage.Entries = append(coverage.Entries, CoverageEntry{Path: manifest, Status: coverageSkipped, Reason: skipNoLockfile})
+		}
+	}
+
+	sort.Slice(coverage.Entries, func(i, j int) bool {
+		return coverage.Entries[i].Path < coverage.Entries[j].Path
+	})
+	return coverage
+}
+
+// lockfileSkipReason explains why a lockfile handed to its parser yielded nothing, or returns ""
+func lockfileSkipReason(path, format string, parseError bool) string {
+	if parseError {
+		return skipParseError
+	}
+	// Without bun a binary bun.lockb is still checked through the tarball URLs it records
+	if filepath.Base(path) == "bun.lockb" {
+		return ""
+	}
+	if format == "npm" || format == "bun" {
+		content, err := readLockfile(path)
+		if err != nil || !json.Valid(content) {
+			return skipParseError
+		}
+	}
+	return ""
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// writeCoverage writes the coverage report as JSON
+func writeCoverage(path string, coverage Coverage, indent string) error {
+	output, err := json.MarshalIndent(coverage, "", indent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, output, 0644)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that coverage records scanned, excluded, unparseable and lockfile-less files
+func TestBuildCoverage(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app/package-lock.json":       `{"lockfileVersion": 3, "packages": {}}`,
+		"dist/package-lock.json":      `{"lockfileVersion": 3, "packages": {}}`,
+		"broken/package-lock.json":    `{"lockfileVersion": 3,`,
+		"tools/package.json":          `{"name": "tools"}`,
+		"app/package.json":            `{"name": "app"}`,
+		"node_modules/x/package.json": `{"name": "x"}`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	managers := []string{"npm"}
+	exclude := []string{"dist/**", "**/node_modules/**"}
+	lockfiles, err := findLockfiles(root, managers, nil, exclude, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, _ := scanLockfiles(lockfiles, map[string]map[string]bool{}, nil, scanOptions{}, nil)
+	coverage := buildCoverage([]string{root}, managers, nil, lockfiles, lockfiles, results, root)
+
+	expected := map[string]CoverageEntry{
+		"app/package-lock.json":    {Manager: "npm", Status: coverageScanned},
+		"broken/package-lock.json": {Manager: "npm", Status: coverageSkipped, Reason: skipParseError},
+		"dist/package-lock.json":   {Manager: "npm", Status: coverageSkipped, Reason: skipExcluded},
+		"tools/package.json":       {Status: coverageSkipped, Reason: skipNoLockfile},
+	}
+	if len(coverage.Entries) != len(expected) {
+		t.Fatalf("Expected %d coverage entries, got %+v", len(expected), coverage.Entries)
+	}
+	for _, entry := range coverage.Entries {
+		rel, _ := filepath.Rel(root, entry.Path)
+		want, ok := expected[filepath.ToSlash(rel)]
+		if !ok {
+			t.Errorf("Unexpected coverage entry %+v", entry)
+			continue
+		}
+		if entry.Manager != want.Manager || entry.Status != want.Status || entry.Reason != want.Reason {
+			t.Errorf("%s: expected %+v, got %+v", rel, want, entry)
+		}
+	}
+}
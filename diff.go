@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PackageChange describes a package that differs between two lockfiles
+type PackageChange struct {
+	Name       string `json:"package"`
+	OldVersion string `json:"oldVersion,omitempty"`
+	NewVersion string `json:"newVersion,omitempty"`
+	IsAffected bool   `json:"isAffected"`
+}
+
+// LockfileDiff describes the package differences between two lockfiles
+type LockfileDiff struct {
+	OldLockFile string          `json:"oldLockFile"`
+	NewLockFile string          `json:"newLockFile"`
+	Added       []PackageChange `json:"added"`
+	Removed     []PackageChange `json:"removed"`
+	Changed     []PackageChange `json:"changed"`
+	AnyAffected bool            `json:"anyAffected"`
+}
+
+// diffLockfiles enumerates both lockfiles and computes added, removed and changed packages
+func diffLockfiles(oldPath, newPath string) (LockfileDiff, error) {
+	diff := LockfileDiff{OldLockFile: oldPath, NewLockFile: newPath}
+
+	oldPackages, err := enumerateLockfile(oldPath)
+	if err != nil {
+		return diff, fmt.Errorf("reading %s: %w", oldPath, err)
+	}
+	newPackages, err := enumerateLockfile(newPath)
+	if err != nil {
+		return diff, fmt.Errorf("reading %s: %w", newPath, err)
+	}
+
+	for name, newVersions := range newPackages {
+		oldVersions, existed := oldPackages[name]
+		if !existed {
+			for _, version := range sortedVersions(newVersions) {
+				diff.Added = append(diff.Added, PackageChange{Name: name, NewVersion: version})
+			}
+			continue
+		}
+		if sameVersions(oldVersions, newVersions) {
+			continue
+		}
+		diff.Changed = append(diff.Changed, PackageChange{
+			Name:       name,
+			OldVersion: strings.Join(sortedVersions(oldVersions), ", "),
+			NewVersion: strings.Join(sortedVersions(newVersions), ", "),
+		})
+	}
+
+	for name, oldVersions := range oldPackages {
+		if _, exists := newPackages[name]; exists {
+			continue
+		}
+		for _, version := range sortedVersions(oldVersions) {
+			diff.Removed = append(diff.Removed, PackageChange{Name: name, OldVersion: version})
+		}
+	}
+
+	for _, changes := range [][]PackageChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.SliceStable(changes, func(i, j int) bool {
+			return changes[i].Name < changes[j].Name
+		})
+	}
+
+	return diff, nil
+}
+
+// markAffected flags added or changed packages whose newly-introduced version is compromised
+func (d *LockfileDiff) markAffected(affected map[string]map[string]bool) {
+	comparator := versionComparatorFor(lockfileFormat(filepath.Base(d.NewLockFile), nil))
+	mark := func(changes []PackageChange) {
+		for i := range changes {
+			previous := make(map[string]bool)
+			for _, version := range strings.Split(changes[i].OldVersion, ", ") {
+				previous[version] = true
+			}
+			for _, version := range strings.Split(changes[i].NewVersion, ", ") {
+				if _, listed := listedVersion(comparator, affected[changes[i].Name], version); listed && !previous[version] {
+					changes[i].IsAffected = true
+					d.AnyAffected = true
+				}
+			}
+		}
+	}
+	mark(d.Added)
+	mark(d.Changed)
+}
+
+// printLockfileDiff prints a human-readable lockfile comparison
+func printLockfileDiff(diff LockfileDiff, noColor bool) {
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	colorPrint("🔍 LOCKFILE COMPARISON\n", "cyan", noColor)
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	colorPrint(fmt.Sprintf("   old: %s\n", diff.OldLockFile), "gray", noColor)
+	colorPrint(fmt.Sprintf("   new: %s\n\n", diff.NewLockFile), "gray", noColor)
+
+	if diff.AnyAffected {
+		colorPrint("❌ NEWLY INTRODUCED COMPROMISED PACKAGES\n", "red", noColor)
+		for _, changes := range [][]PackageChange{diff.Added, diff.Changed} {
+			for _, change := range changes {
+				if change.IsAffected {
+					colorPrint(fmt.Sprintf("  %s (%s)\n", change.Name, change.NewVersion), "red", noColor)
+				}
+			}
+		}
+		fmt.Println()
+	}
+
+	colorPrint(fmt.Sprintf("Added (%d):\n", len(diff.Added)), "green", noColor)
+	for _, change := range diff.Added {
+		fmt.Printf("  + %s@%s\n", change.Name, change.NewVersion)
+	}
+	colorPrint(fmt.Sprintf("Removed (%d):\n", len(diff.Removed)), "yellow", noColor)
+	for _, change := range diff.Removed {
+		fmt.Printf("  - %s@%s\n", change.Name, change.OldVersion)
+	}
+	colorPrint(fmt.Sprintf("Changed (%d):\n", len(diff.Changed)), "cyan", noColor)
+	for _, change := range diff.Changed {
+		fmt.Printf("  ~ %s %s -> %s\n", change.Name, change.OldVersion, change.NewVersion)
+	}
+}
+
+// enumerateLockfile lists every package name and version in a lockfile, collected from the
+// same parser a scan uses. A file whose name is not a known lockfile is parsed by extension,
+// so renamed copies such as old-yarn.lock still compare
+func enumerateLockfile(lockfile string) (map[string]map[string]bool, error) {
+	content, err := readLockfile(lockfile)
+	if err != nil {
+		return nil, err
+	}
+
+	format := lockfileFormat(filepath.Base(lockfile), nil)
+	switch {
+	case format != "":
+	case strings.HasSuffix(lockfile, ".yaml") || strings.HasSuffix(lockfile, ".yml"):
+		format = "pnpm"
+	case strings.HasSuffix(lockfile, ".json"):
+		format = "npm"
+	default:
+		format = "yarn"
+	}
+	if format == "npm" && !json.Valid(content) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+
+	stats := &scanStats{enumerated: make(map[string]map[string]bool)}
+	parseRecovered(lockfile, func() ([]Package, []string, bool, bool) {
+		return parseLockfileAs(lockfile, format, map[string]map[string]bool{}, scanOptions{}, stats)
+	})
+	return stats.enumerated, nil
+}
+
+// sortedVersions returns the versions in a set in ascending order
+func sortedVersions(versions map[string]bool) []string {
+	result := make([]string, 0, len(versions))
+	for version := range versions {
+		result = append(result, version)
+	}
+	sortAffectedVersions(result)
+	return result
+}
+
+// sameVersions reports whether two version sets are identical
+func sameVersions(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for version := range a {
+		if !b[version] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test comparing a clean lockfile with one that introduces a compromised package
+func TestDiffLockfiles(t *testing.T) {
+	oldContent := `{
+		"lockfileVersion": 2,
+		"packages": {
+			"": {"version": "1.0.0"},
+			"node_modules/left-pad": {"version": "1.2.0"},
+			"node_modules/removed-package": {"version": "0.1.0"},
+			"node_modules/stable": {"version": "3.0.0"}
+		}
+	}`
+	newContent := `{
+		"lockfileVersion": 2,
+		"packages": {
+			"": {"version": "1.0.0"},
+			"node_modules/left-pad": {"version": "1.3.0"},
+			"node_modules/@scoped/package": {"version": "2.0.0"},
+			"node_modules/stable": {"version": "3.0.0"}
+		}
+	}`
+
+	oldDir := t.TempDir()
+	newDir := t.TempDir()
+	oldPath := filepath.Join(oldDir, "package-lock.json")
+	newPath := filepath.Join(newDir, "package-lock.json")
+	if err := os.WriteFile(oldPath, []byte(oldContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte(newContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := diffLockfiles(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].Name != "@scoped/package" || diff.Added[0].NewVersion != "2.0.0" {
+		t.Errorf("Expected @scoped/package@2.0.0 to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "removed-package" {
+		t.Errorf("Expected removed-package to be removed, got %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].OldVersion != "1.2.0" || diff.Changed[0].NewVersion != "1.3.0" {
+		t.Errorf("Expected left-pad 1.2.0 -> 1.3.0, got %+v", diff.Changed)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+		"stable":          {"3.0.0": true},
+	}
+	diff.markAffected(affected)
+
+	if !diff.AnyAffected {
+		t.Error("Expected newly introduced compromised versions to be flagged")
+	}
+	if !diff.Added[0].IsAffected || !diff.Changed[0].IsAffected {
+		t.Error("Expected added and changed compromised packages to be flagged")
+	}
+}
+
+// Test that an unchanged compromised package is not reported as newly introduced
+func TestDiffLockfilesUnchanged(t *testing.T) {
+	content := `left-pad@^1.3.0:
+  version "1.3.0"
+`
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old-yarn.lock")
+	newPath := filepath.Join(dir, "yarn.lock")
+	for _, path := range []string{oldPath, newPath} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	packages, err := enumerateLockfile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !packages["left-pad"]["1.3.0"] {
+		t.Fatalf("Expected left-pad@1.3.0 to be enumerated, got %v", packages)
+	}
+
+	diff, err := diffLockfiles(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.markAffected(map[string]map[string]bool{"left-pad": {"1.3.0": true}})
+
+	if diff.AnyAffected || len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 {
+		t.Errorf("Expected no differences, got %+v", diff)
+	}
+}
+
+// Test that pnpm v9 lockfiles, whose packages keys have no leading slash, are enumerated
+func TestDiffLockfilesPnpmV9(t *testing.T) {
+	oldContent := `lockfileVersion: '9.0'
+
+packages:
+
+  left-pad@1.2.0:
+    resolution: {integrity: sha512-abc==}
+`
+	newContent := `lockfileVersion: '9.0'
+
+packages:
+
+  left-pad@1.3.0:
+    resolution: {integrity: sha512-def==}
+
+  '@scoped/package@2.0.0':
+    resolution: {integrity: sha512-ghi==}
+`
+	oldPath := filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+	newPath := filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+	if err := os.WriteFile(oldPath, []byte(oldContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte(newContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := diffLockfiles(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Name != "@scoped/package" || diff.Added[0].NewVersion != "2.0.0" {
+		t.Errorf("Expected @scoped/package@2.0.0 to be added, got %+v", diff.Added)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].OldVersion != "1.2.0" || diff.Changed[0].NewVersion != "1.3.0" {
+		t.Errorf("Expected left-pad 1.2.0 -> 1.3.0, got %+v", diff.Changed)
+	}
+}
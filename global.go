@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// globalNodeModulesDirs returns the candidate global install directories for npm, pnpm and
+// yarn on this machine, asking npm first and falling back to per-OS defaults
+func globalNodeModulesDirs() []string {
+	var dirs []string
+	if output, err := exec.Command("npm", "root", "-g").Output(); err == nil {
+		if dir := strings.TrimSpace(string(output)); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	if pnpmHome := os.Getenv("PNPM_HOME"); pnpmHome != "" {
+		dirs = append(dirs, filepath.Join(pnpmHome, "global", "5", "node_modules"))
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		appData := os.Getenv("APPDATA")
+		localAppData := os.Getenv("LOCALAPPDATA")
+		dirs = append(dirs,
+			filepath.Join(appData, "npm", "node_modules"),
+			filepath.Join(localAppData, "pnpm", "global", "5", "node_modules"),
+			filepath.Join(localAppData, "Yarn", "Data", "global", "node_modules"),
+		)
+	case "darwin":
+		dirs = append(dirs,
+			"/usr/local/lib/node_modules",
+			"/opt/homebrew/lib/node_modules",
+			filepath.Join(home, "Library", "pnpm", "global", "5", "node_modules"),
+			filepath.Join(home, ".config", "yarn", "global", "node_modules"),
+		)
+	default:
+		dirs = append(dirs,
+			"/usr/local/lib/node_modules",
+			"/usr/lib/node_modules",
+			filepath.Join(home, ".npm-global", "lib", "node_modules"),
+			filepath.Join(home, ".local", "share", "pnpm", "global", "5", "node_modules"),
+			filepath.Join(home, ".config", "yarn", "global", "node_modules"),
+		)
+	}
+
+	// Drop duplicates and directories that don't exist
+	seen := make(map[string]bool)
+	var existing []string
+	for _, dir := range dirs {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			existing = append(existing, dir)
+		}
+	}
+	return existing
+}
+
+// scanGlobal scans installed packages in global node_modules directories
+func scanGlobal(dirs []string, affected map[string]map[string]bool) ([]Result, bool, bool) {
+	var results []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, dir := range dirs {
+		packages := scanNodeModules(dir, affected)
+		if len(packages) == 0 {
+			continue
+		}
+		for _, pkg := range packages {
+			anyAffected = anyAffected || pkg.IsAffected
+			anyWarnings = anyWarnings || pkg.IsWarning
+		}
+		results = append(results, Result{LockFile: dir, Packages: packages})
+	}
+
+	return results, anyAffected, anyWarnings
+}
+
+// scanNodeModules checks the package.json of every package installed under a node_modules
+// directory, including scoped and nested packages
+func scanNodeModules(dir string, affected map[string]map[string]bool) []Package {
+	comparator := versionComparatorFor("npm")
+	var packages []Package
+	seen := make(map[string]bool)
+
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != "package.json" {
+			return nil
+		}
+
+		// Only package roots: node_modules/name or node_modules/@scope/name
+		pkgDir := filepath.Dir(path)
+		parent := filepath.Dir(pkgDir)
+		if strings.HasPrefix(filepath.Base(parent), "@") {
+			parent = filepath.Dir(parent)
+		}
+		if parent != dir && filepath.Base(parent) != "node_modules" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var manifest struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(content, &manifest) != nil || manifest.Name == "" || manifest.Version == "" {
+			return nil
+		}
+
+		key := manifest.Name + "@" + manifest.Version
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		if pkg, ok := checkPackage(comparator, manifest.Name, manifest.Version, affected); ok {
+			packages = append(packages, pkg)
+		}
+		return nil
+	})
+
+	return packages
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a compromised package in a fake global install directory is flagged
+func TestScanGlobal(t *testing.T) {
+	globalDir := filepath.Join(t.TempDir(), "lib", "node_modules")
+	manifests := map[string]string{
+		"left-pad/package.json":                     `{"name": "left-pad", "version": "1.3.0"}`,
+		"@scoped/package/package.json":              `{"name": "@scoped/package", "version": "2.0.0"}`,
+		"some-cli/package.json":                     `{"name": "some-cli", "version": "1.0.0"}`,
+		"some-cli/node_modules/is-odd/package.json": `{"name": "is-odd", "version": "3.0.1"}`,
+		"some-cli/lib/fixtures/package.json":        `{"name": "left-pad", "version": "9.9.9"}`,
+	}
+	for rel, content := range manifests {
+		path := filepath.Join(globalDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+		"is-odd":          {"3.0.1": true},
+	}
+
+	results, anyAffected, anyWarnings := scanGlobal([]string{globalDir, filepath.Join(globalDir, "missing")}, affected)
+	if !anyAffected || anyWarnings {
+		t.Errorf("Expected affected findings without warnings, got affected=%v warnings=%v", anyAffected, anyWarnings)
+	}
+	if len(results) != 1 || results[0].LockFile != globalDir {
+		t.Fatalf("Expected one result for the global dir, got %+v", results)
+	}
+
+	found := make(map[string]bool)
+	for _, pkg := range results[0].Packages {
+		found[pkg.Name+"@"+pkg.Version] = true
+	}
+	for _, expected := range []string{"left-pad@1.3.0", "@scoped/package@2.0.0", "is-odd@3.0.1"} {
+		if !found[expected] {
+			t.Errorf("Expected %s to be flagged, got %v", expected, found)
+		}
+	}
+	if len(found) != 3 {
+		t.Errorf("Expected non-package manifests to be ignored, got %v", found)
+	}
+}
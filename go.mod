@@ -3,5 +3,6 @@ module github.com/jpmckearin/shai-hulud-scanner
 go 1.21
 
 require (
-	// No external dependencies - pure Go standard library
-)
\ No newline at end of file
+	github.com/klauspost/compress v1.17.11
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Sections of the --group-by severity view, most urgent first
+const (
+	groupCompromised = "Compromised"
+	groupWarnings    = "Warnings"
+	groupSuspicious  = "Suspicious"
+	groupNotices     = "Notices"
+)
+
+// groupedFinding is one package finding, or one notice about the file, together with the
+// result it was reported in
+type groupedFinding struct {
+	Result  Result
+	Package Package
+	Notice  string
+}
+
+// findingGroup is one section of the --group-by severity view
+type findingGroup struct {
+	Title    string
+	Findings []groupedFinding
+}
+
+// groupFindingsBySeverity splits findings into compromised, warning, suspicious and notice
+// sections, in that order, leaving out empty ones. Compromised packages are ordered from the
+// most severe listed severity down, everything else by name, version and lockfile
+func groupFindingsBySeverity(results []Result) []findingGroup {
+	sections := make(map[string][]groupedFinding)
+	for _, res := range results {
+		for _, notice := range res.Notices {
+			sections[groupNotices] = append(sections[groupNotices], groupedFinding{Result: res, Notice: notice})
+		}
+		for _, pkg := range res.Packages {
+			var title string
+			switch {
+			case pkg.IsAffected:
+				title = groupCompromised
+			case pkg.IsWarning:
+				title = groupWarnings
+			case pkg.IsSuspicious:
+				title = groupSuspicious
+			case pkg.Notice != "":
+				title = groupNotices
+			default:
+				continue
+			}
+			sections[title] = append(sections[title], groupedFinding{Result: res, Package: pkg, Notice: pkg.Notice})
+		}
+	}
+
+	var groups []findingGroup
+	for _, title := range []string{groupCompromised, groupWarnings, groupSuspicious, groupNotices} {
+		findings := sections[title]
+		if len(findings) == 0 {
+			continue
+		}
+		sort.SliceStable(findings, func(i, j int) bool {
+			a, b := findings[i], findings[j]
+			if rankA, rankB := severityRank[a.Package.Severity], severityRank[b.Package.Severity]; rankA != rankB {
+				return rankA > rankB
+			}
+			if a.Package.Name != b.Package.Name {
+				return a.Package.Name < b.Package.Name
+			}
+			if a.Package.Version != b.Package.Version {
+				return compareVersions(a.Package.Version, b.Package.Version) < 0
+			}
+			return a.Result.LockFile < b.Result.LockFile
+		})
+		groups = append(groups, findingGroup{Title: title, Findings: findings})
+	}
+	return groups
+}
+
+// printSeverityGroups prints the --group-by severity view as a prioritized worklist
+func printSeverityGroups(groups []findingGroup, noColor bool) {
+	for _, group := range groups {
+		switch group.Title {
+		case groupCompromised:
+			colorPrint(fmt.Sprintf("Compromised (%d):\n", len(group.Findings)), "red", noColor)
+			for _, finding := range group.Findings {
+				printAffectedFinding(os.Stdout, finding.Result, finding.Package, noColor)
+			}
+		case groupWarnings:
+			colorPrint(fmt.Sprintf("Warnings (%d):\n", len(group.Findings)), "yellow", noColor)
+			for _, finding := range group.Findings {
+				printWarningFinding(os.Stdout, finding.Result, finding.Package, noColor)
+			}
+		default:
+			colorPrint(fmt.Sprintf("%s (%d):\n", group.Title, len(group.Findings)), "cyan", noColor)
+			for _, finding := range group.Findings {
+				printNoticeFinding(os.Stdout, finding.Result, finding.Notice, noColor)
+			}
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test that --group-by severity orders its sections and files each finding under the right one
+func TestGroupFindingsBySeverity(t *testing.T) {
+	results := []Result{
+		{LockFile: "a/package-lock.json", Packages: []Package{
+			{Name: "yarn.lock", Notice: "lockfile version 4 is newer than supported"},
+			{Name: "left-pad", Version: "1.3.0", IsWarning: true},
+			{Name: "chalk", Version: "5.6.1", IsAffected: true, Severity: severityLow},
+		}},
+		{LockFile: "b/yarn.lock", Packages: []Package{
+			{Name: "debug", Version: "4.4.2", IsSuspicious: true, Notice: "debug@4.4.2 looks tampered"},
+			{Name: "ansi-styles", Version: "6.2.2", IsAffected: true, Severity: severityCritical},
+			{Name: "chalk", Version: "5.6.1", IsAffected: true},
+		}},
+	}
+
+	groups := groupFindingsBySeverity(results)
+	got := make(map[string][]string)
+	var titles []string
+	for _, group := range groups {
+		titles = append(titles, group.Title)
+		for _, finding := range group.Findings {
+			got[group.Title] = append(got[group.Title], finding.Package.Name+"@"+finding.Package.Version+" in "+finding.Result.LockFile)
+		}
+	}
+
+	if expected := []string{groupCompromised, groupWarnings, groupSuspicious, groupNotices}; !reflect.DeepEqual(titles, expected) {
+		t.Fatalf("Expected sections %v, got %v", expected, titles)
+	}
+	expected := map[string][]string{
+		groupCompromised: {"ansi-styles@6.2.2 in b/yarn.lock", "chalk@5.6.1 in a/package-lock.json", "chalk@5.6.1 in b/yarn.lock"},
+		groupWarnings:    {"left-pad@1.3.0 in a/package-lock.json"},
+		groupSuspicious:  {"debug@4.4.2 in b/yarn.lock"},
+		groupNotices:     {"yarn.lock@ in a/package-lock.json"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// installScripts are the lifecycle scripts npm-compatible managers run on install
+var installScripts = []string{"preinstall", "install", "postinstall"}
+
+// findPnpmVirtualStores finds pnpm's symlinked virtual stores (node_modules/.pnpm) under rootDir
+func findPnpmVirtualStores(rootDir string) ([]string, error) {
+	var stores []string
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip inaccessible files
+		}
+		if !d.IsDir() || d.Name() != "node_modules" {
+			return nil
+		}
+
+		store := filepath.Join(path, ".pnpm")
+		if info, err := os.Stat(store); err == nil && info.IsDir() {
+			stores = append(stores, store)
+		}
+		return filepath.SkipDir
+	})
+	return stores, err
+}
+
+// parsePnpmStoreEntry splits a virtual store directory name such as
+// @scope+name@1.0.0_peer@2.0.0 into the package name and version it claims to hold
+func parsePnpmStoreEntry(entry string) (string, string) {
+	entry, _, _ = strings.Cut(entry, "_")
+	offset := 0
+	if strings.HasPrefix(entry, "@") {
+		offset = 1
+	}
+	atIndex := strings.Index(entry[offset:], "@")
+	if atIndex == -1 {
+		return "", ""
+	}
+	atIndex += offset
+	return strings.Replace(entry[:atIndex], "+", "/", 1), entry[atIndex+1:]
+}
+
+// scanPnpmVirtualStore reads the package.json physically stored at
+// .pnpm/<name>@<version>/node_modules/<name> for every entry of a virtual store. Besides
+// compromised versions it reports manifests that disagree with their store path, and
+// install scripts on packages with compromised versions, as suspicious
+func scanPnpmVirtualStore(store string, affected map[string]map[string]bool) []Package {
+	comparator := versionComparatorFor("pnpm")
+	var packages []Package
+
+	entries, err := os.ReadDir(store)
+	if err != nil {
+		return packages
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name, version := parsePnpmStoreEntry(entry.Name())
+		if name == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(store, entry.Name(), "node_modules", filepath.FromSlash(name), "package.json"))
+		if err != nil {
+			continue
+		}
+		var manifest struct {
+			Name    string            `json:"name"`
+			Version string            `json:"version"`
+			Scripts map[string]string `json:"scripts"`
+		}
+		if json.Unmarshal(content, &manifest) != nil || manifest.Name == "" || manifest.Version == "" {
+			continue
+		}
+
+		// The lockfile only knows the store path, so a manifest that disagrees was edited on disk
+		if manifest.Name != name || normalizeVersion(manifest.Version) != normalizeVersion(version) {
+			packages = append(packages, Package{
+				Name:         manifest.Name,
+				Version:      manifest.Version,
+				IsSuspicious: true,
+				Notice: fmt.Sprintf("%s@%s is stored as %s@%s, the installed package does not match its store entry",
+					manifest.Name, manifest.Version, name, version),
+			})
+		}
+
+		pkg, ok := checkPackage(comparator, manifest.Name, manifest.Version, affected)
+		if !ok {
+			continue
+		}
+		packages = append(packages, pkg)
+
+		var scripts []string
+		for _, script := range installScripts {
+			if command := manifest.Scripts[script]; command != "" {
+				scripts = append(scripts, fmt.Sprintf("%s: %s", script, command))
+			}
+		}
+		if len(scripts) > 0 {
+			sort.Strings(scripts)
+			packages = append(packages, Package{
+				Name:         manifest.Name,
+				Version:      manifest.Version,
+				IsSuspicious: true,
+				Notice: fmt.Sprintf("%s@%s has install scripts (%s), check whether they ran",
+					manifest.Name, manifest.Version, strings.Join(scripts, "; ")),
+			})
+		}
+	}
+
+	return packages
+}
+
+// scanInstalled scans the packages physically installed in pnpm virtual stores under rootDir
+func scanInstalled(rootDir string, affected map[string]map[string]bool) ([]Result, bool, bool) {
+	var results []Result
+	anyAffected := false
+	anyWarnings := false
+
+	stores, err := findPnpmVirtualStores(rootDir)
+	if err != nil {
+		return results, anyAffected, anyWarnings
+	}
+
+	for _, store := range stores {
+		packages := scanPnpmVirtualStore(store, affected)
+		if len(packages) == 0 {
+			continue
+		}
+		for _, pkg := range packages {
+			anyAffected = anyAffected || pkg.IsAffected
+			anyWarnings = anyWarnings || pkg.IsWarning
+		}
+		results = append(results, Result{LockFile: store, Packages: packages})
+	}
+
+	return results, anyAffected, anyWarnings
+}
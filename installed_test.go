@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that a compromised package in a pnpm virtual store is read from its stored package.json
+func TestScanInstalledPnpmStore(t *testing.T) {
+	root := t.TempDir()
+	store := filepath.Join(root, "node_modules", ".pnpm")
+	manifests := map[string]string{
+		"@ctrl+tinycolor@4.1.1/node_modules/@ctrl/tinycolor/package.json": `{"name": "@ctrl/tinycolor", "version": "4.1.1",
+			"scripts": {"postinstall": "node bundle.js"}}`,
+		"left-pad@1.3.0_react@18.2.0/node_modules/left-pad/package.json": `{"name": "left-pad", "version": "1.3.0"}`,
+		// Tampered on disk: the store entry says 1.2.0, the installed manifest says 1.3.1
+		"is-odd@1.2.0/node_modules/is-odd/package.json":   `{"name": "is-odd", "version": "1.3.1"}`,
+		"is-odd@1.2.0/node_modules/left-pad/package.json": `{"name": "left-pad", "version": "9.9.9"}`,
+	}
+	for rel, content := range manifests {
+		path := filepath.Join(store, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected := map[string]map[string]bool{
+		"@ctrl/tinycolor": {"4.1.1": true},
+		"is-odd":          {"1.3.1": true},
+	}
+	results, anyAffected, _ := scanInstalled(root, affected)
+	if !anyAffected || len(results) != 1 || results[0].LockFile != store {
+		t.Fatalf("Expected findings from %s, got %+v", store, results)
+	}
+
+	var compromised, notices []string
+	for _, pkg := range results[0].Packages {
+		if pkg.IsAffected {
+			compromised = append(compromised, pkg.Name+"@"+pkg.Version)
+		}
+		if pkg.IsSuspicious {
+			notices = append(notices, pkg.Notice)
+		}
+	}
+	if strings.Join(compromised, ",") != "@ctrl/tinycolor@4.1.1,is-odd@1.3.1" {
+		t.Errorf("Expected stored compromised packages to be flagged, got %v", compromised)
+	}
+	joined := strings.Join(notices, "\n")
+	if !strings.Contains(joined, "postinstall: node bundle.js") {
+		t.Errorf("Expected the install script to be reported, got %v", notices)
+	}
+	if !strings.Contains(joined, "is stored as is-odd@1.2.0") {
+		t.Errorf("Expected the store/manifest mismatch to be reported, got %v", notices)
+	}
+}
+
+func TestParsePnpmStoreEntry(t *testing.T) {
+	tests := map[string][2]string{
+		"left-pad@1.3.0":                {"left-pad", "1.3.0"},
+		"@babel+core@7.20.0_supports@1": {"@babel/core", "7.20.0"},
+		"node_modules":                  {"", ""},
+	}
+	for entry, expected := range tests {
+		if name, version := parsePnpmStoreEntry(entry); name != expected[0] || version != expected[1] {
+			t.Errorf("parsePnpmStoreEntry(%q) = %q, %q, want %q, %q", entry, name, version, expected[0], expected[1])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IOC export formats accepted by --export-iocs-format
+const (
+	iocFormatText = "text"
+	iocFormatJSON = "json"
+)
+
+// IOCEntry is one package of a JSON exploited packages list: its compromised versions
+// sharing one severity, and the advisory, such as a CVE id or URL, describing them
+type IOCEntry struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+	Severity string   `json:"severity,omitempty"`
+	Advisory string   `json:"advisory,omitempty"`
+}
+
+// isJSONList reports whether an exploited packages list is a JSON array of entries rather
+// than package@version lines, going by its first non-blank byte
+func isJSONList(content []byte) bool {
+	trimmed := bytes.TrimLeft(content, " \t\r\n\ufeff")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// jsonListLines decodes a JSON exploited packages list into the package@version lines of the
+// text format, so both go through the same validation. A severity the text format does not
+// know is dropped rather than dropping the entry
+func jsonListLines(content []byte) (string, error) {
+	var entries []IOCEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return "", fmt.Errorf("invalid JSON list: %w", err)
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		severity := strings.ToLower(entry.Severity)
+		if _, known := severityRank[severity]; !known {
+			severity = ""
+		}
+		for _, version := range entry.Versions {
+			line := strings.TrimSpace(entry.Name) + "@" + strings.TrimSpace(version)
+			if severity != "" {
+				line += " " + severity
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// parseListAdvisories collects the advisories of a JSON exploited packages list, keyed as the
+// list's versions are stored. A text list carries none
+func parseListAdvisories(content []byte) map[string]string {
+	advisories := make(map[string]string)
+	var entries []IOCEntry
+	if !isJSONList(content) || json.Unmarshal(content, &entries) != nil {
+		return advisories
+	}
+	for _, entry := range entries {
+		if entry.Advisory == "" {
+			continue
+		}
+		for _, version := range entry.Versions {
+			affected, _, err := parseExploitedList(strings.NewReader(entry.Name + "@" + version))
+			if err != nil {
+				continue
+			}
+			for name, versions := range affected {
+				for listed := range versions {
+					advisories[name+"@"+listed] = entry.Advisory
+				}
+			}
+		}
+	}
+	return advisories
+}
+
+// applyAdvisories sets the list's advisory on every compromised package it describes
+func applyAdvisories(results []Result, affected map[string]map[string]bool, advisories map[string]string) {
+	for i := range results {
+		comparator := versionComparatorFor(lockfileFormat(filepath.Base(results[i].LockFile), nil))
+		for j := range results[i].Packages {
+			pkg := &results[i].Packages[j]
+			if !pkg.IsAffected {
+				continue
+			}
+			if listed, ok := listedVersion(comparator, affected[pkg.Name], pkg.Version); ok {
+				pkg.Advisory = advisories[pkg.Name+"@"+listed]
+			}
+		}
+	}
+}
+
+// effectiveIOCs returns the list a scan matched against as JSON list entries, one per package
+// and severity, sorted by name with versions in semantic order
+func effectiveIOCs(affected map[string]map[string]bool, severities map[string]string) []IOCEntry {
+	names := make([]string, 0, len(affected))
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := []IOCEntry{}
+	for _, name := range names {
+		bySeverity := make(map[string][]string)
+		var order []string
+		for _, version := range sortedVersions(affected[name]) {
+			severity := severities[name+"@"+version]
+			if _, ok := bySeverity[severity]; !ok {
+				order = append(order, severity)
+			}
+			bySeverity[severity] = append(bySeverity[severity], version)
+		}
+		for _, severity := range order {
+			entries = append(entries, IOCEntry{Name: name, Versions: bySeverity[severity], Severity: severity})
+		}
+	}
+	return entries
+}
+
+// formatIOCs renders the effective list in the plain package@version format, with a trailing
+// severity where the list assigned one, or as a JSON array of entries
+func formatIOCs(entries []IOCEntry, format, indent string) ([]byte, error) {
+	if format == iocFormatJSON {
+		return json.MarshalIndent(entries, "", indent)
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		for _, version := range entry.Versions {
+			line := entry.Name + "@" + version
+			if entry.Severity != "" {
+				line += " " + entry.Severity
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// exportIOCs writes the effective exploited packages list to path
+func exportIOCs(path, format, indent string, affected map[string]map[string]bool, severities map[string]string) error {
+	output, err := formatIOCs(effectiveIOCs(affected, severities), format, indent)
+	if err != nil {
+		return fmt.Errorf("formatting IOCs: %w", err)
+	}
+	return os.WriteFile(path, output, 0644)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test that the exported list is the normalized, deduplicated list the scan matches against
+// and parses back to the same entries
+func TestExportIOCs(t *testing.T) {
+	list := `# duplicates and spellings collapse into one entry each
+chalk@5.6.1 critical
+chalk@v5.6.1 critical
+ctrl/tinycolor@4.1.2
+@ctrl/tinycolor@4.1.1
+debug@4.4.2 high
+debug@4.4.10
+`
+	affected, severities, err := parseExploitedList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	textPath := filepath.Join(dir, "iocs.txt")
+	if err := exportIOCs(textPath, iocFormatText, "  ", affected, severities); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(textPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@ctrl/tinycolor@4.1.1\n@ctrl/tinycolor@4.1.2\nchalk@5.6.1 critical\ndebug@4.4.2 high\ndebug@4.4.10\n"
+	if string(content) != expected {
+		t.Errorf("Expected text export:\n%s\ngot:\n%s", expected, content)
+	}
+	reparsed, reparsedSeverities, err := parseExploitedList(strings.NewReader(string(content)))
+	if err != nil || !reflect.DeepEqual(reparsed, affected) || !reflect.DeepEqual(reparsedSeverities, severities) {
+		t.Errorf("Expected the text export to parse back to the same list, got %v %v (%v)", reparsed, reparsedSeverities, err)
+	}
+
+	jsonPath := filepath.Join(dir, "iocs.json")
+	if err := exportIOCs(jsonPath, iocFormatJSON, "  ", affected, severities); err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []IOCEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		t.Fatal(err)
+	}
+	expectedEntries := []IOCEntry{
+		{Name: "@ctrl/tinycolor", Versions: []string{"4.1.1", "4.1.2"}},
+		{Name: "chalk", Versions: []string{"5.6.1"}, Severity: "critical"},
+		{Name: "debug", Versions: []string{"4.4.2"}, Severity: "high"},
+		{Name: "debug", Versions: []string{"4.4.10"}},
+	}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("Expected JSON export %+v, got %+v", expectedEntries, entries)
+	}
+}
+
+// Test that a JSON list loads like the text format and its advisories reach the findings
+func TestJSONExploitedList(t *testing.T) {
+	list := `
+[
+  {"name": "chalk", "versions": ["5.6.1"], "severity": "Critical", "advisory": "https://github.com/advisories/GHSA-2v46-p5h4-248w"},
+  {"name": "ctrl/tinycolor", "versions": ["4.1.1", ">=4.1.2 <4.1.4"], "advisory": "CVE-2025-0001"},
+  {"name": "debug", "versions": ["4.4.2"], "severity": "urgent"},
+  {"name": "broken", "versions": ["latest"]}
+]`
+	path := filepath.Join(t.TempDir(), "exploited.json")
+	if err := os.WriteFile(path, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	affected, err := loadExploitedPackages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]bool{
+		"chalk":           {"5.6.1": true},
+		"@ctrl/tinycolor": {"4.1.1": true, ">=4.1.2 <4.1.4": true},
+		"debug":           {"4.4.2": true},
+	}
+	if !reflect.DeepEqual(affected, expected) {
+		t.Errorf("Expected %v, got %v", expected, affected)
+	}
+	_, severities, err := parseExploitedList(strings.NewReader(list))
+	if err != nil || !reflect.DeepEqual(severities, map[string]string{"chalk@5.6.1": severityCritical}) {
+		t.Errorf("Expected only the known severity to be kept, got %v (%v)", severities, err)
+	}
+
+	advisories := parseListAdvisories([]byte(list))
+	results := []Result{{LockFile: "yarn.lock", Packages: []Package{
+		{Name: "chalk", Version: "5.6.1", IsAffected: true},
+		{Name: "@ctrl/tinycolor", Version: "4.1.3", IsAffected: true},
+		{Name: "debug", Version: "4.4.2", IsAffected: true},
+	}}}
+	applyAdvisories(results, affected, advisories)
+	packages := results[0].Packages
+	if packages[0].Advisory != "https://github.com/advisories/GHSA-2v46-p5h4-248w" || packages[1].Advisory != "CVE-2025-0001" || packages[2].Advisory != "" {
+		t.Errorf("Expected advisories from the matching entries, got %+v", packages)
+	}
+	output, err := json.Marshal(packages[1])
+	if err != nil || !strings.Contains(string(output), `"advisory":"CVE-2025-0001"`) {
+		t.Errorf("Expected the advisory in JSON output, got %s (%v)", output, err)
+	}
+
+	if _, _, err := parseExploitedList(strings.NewReader(`[{"name": "chalk"`)); err == nil {
+		t.Error("Expected an error for a truncated JSON list")
+	}
+	if advisories := parseListAdvisories([]byte("chalk@5.6.1 critical\n")); len(advisories) != 0 {
+		t.Errorf("Expected a text list to carry no advisories, got %v", advisories)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Job is one entry of a --jobs file: a root scanned with its own managers, patterns and
+// exploited packages list. Unset fields fall back to the command-line flags
+type Job struct {
+	Root     string   `yaml:"root"`
+	Managers []string `yaml:"managers"`
+	Include  []string `yaml:"include"`
+	Exclude  []string `yaml:"exclude"`
+	ListPath string   `yaml:"list-path"`
+	ListURL  string   `yaml:"list-url"`
+}
+
+// loadJobs reads a jobs file of the form `jobs: [{root: ..., managers: [...]}, ...]`,
+// filling unset fields from defaults
+func loadJobs(path string, defaults Job) ([]Job, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Jobs []Job `yaml:"jobs"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(file.Jobs) == 0 {
+		return nil, fmt.Errorf("%s defines no jobs", path)
+	}
+
+	for i := range file.Jobs {
+		job := &file.Jobs[i]
+		if job.Root == "" {
+			return nil, fmt.Errorf("job %d in %s has no root", i+1, path)
+		}
+		for _, manager := range job.Managers {
+			switch manager {
+			case "yarn", "npm", "pnpm", "bun":
+			default:
+				return nil, fmt.Errorf("job %d in %s has invalid manager '%s'", i+1, path, manager)
+			}
+		}
+		if job.Managers == nil {
+			job.Managers = defaults.Managers
+		}
+		if job.Include == nil {
+			job.Include = defaults.Include
+		}
+		if job.Exclude == nil {
+			job.Exclude = defaults.Exclude
+		}
+	}
+	return file.Jobs, nil
+}
+
+// runJobs scans every job with up to concurrency jobs at once and merges their reports.
+// Jobs without a list of their own are checked against affected, its severities and the list
+// metadata in opts, all with the settings in opts; loadList reads a job's list. A job that
+// fails is reported and left out of the combined report
+func runJobs(jobs []Job, concurrency int, affected map[string]map[string]bool, severities map[string]string, opts scanOptions, loadList func(Job) ([]byte, error)) (ScanResult, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	reports := make([]*ScanResult, len(jobs))
+	errs := make([]error, len(jobs))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, job := range jobs {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, job Job) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			report, err := runJob(job, affected, severities, opts, loadList)
+			if err != nil {
+				errs[i] = fmt.Errorf("job %d (%s): %w", i+1, job.Root, err)
+				return
+			}
+			reports[i] = &report
+		}(i, job)
+	}
+	wg.Wait()
+
+	var completed []ScanResult
+	var failed []error
+	for i := range jobs {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		completed = append(completed, *reports[i])
+	}
+	return mergeResults(completed), failed
+}
+
+// runJob scans a single job's root with its own settings. A job with its own list takes its
+// severities, known-bad integrities and advisories from that list alone
+func runJob(job Job, affected map[string]map[string]bool, severities map[string]string, opts scanOptions, loadList func(Job) ([]byte, error)) (ScanResult, error) {
+	if job.ListPath != "" || job.ListURL != "" {
+		content, err := loadList(job)
+		if err != nil {
+			return ScanResult{}, err
+		}
+		affected, severities, err = parseExploitedList(bytes.NewReader(content))
+		if err != nil {
+			return ScanResult{}, err
+		}
+		if len(affected) == 0 {
+			return ScanResult{}, fmt.Errorf("list has no valid package@version entries")
+		}
+		opts.knownBad, _ = parseKnownBadIntegrities(bytes.NewReader(content))
+		opts.advisories = parseListAdvisories(content)
+	}
+
+	if _, err := os.Stat(job.Root); err != nil {
+		return ScanResult{}, err
+	}
+	lockfiles, err := findLockfiles(job.Root, job.Managers, job.Include, job.Exclude, nil, opts.warn, nil)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	results, anyAffected, anyWarnings := scanLockfiles(lockfiles, affected, nil, opts, nil)
+	applySeverities(results, affected, severities)
+	applyAdvisories(results, affected, opts.advisories)
+
+	root, _ := filepath.Abs(job.Root)
+	return ScanResult{
+		Root:          root,
+		Results:       results,
+		AnyAffected:   anyAffected,
+		AnyWarnings:   anyWarnings,
+		AnySuspicious: anySuspicious(results),
+		Summary:       summarizeResults(results, len(lockfiles)),
+	}, nil
+}
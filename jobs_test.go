@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that each job is scanned with its own managers and list, taking severities and
+// known-bad integrities from that list rather than the shared one
+func TestRunJobs(t *testing.T) {
+	dir := t.TempDir()
+	api := filepath.Join(dir, "api")
+	web := filepath.Join(dir, "web")
+	for _, root := range []string{api, web} {
+		if err := os.MkdirAll(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	npmLock := `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`
+	yarnLock := "left-pad@^1.3.0:\n  version \"1.3.0\"\n  integrity sha512-shared==\n\nis-odd@^3.0.1:\n  version \"3.0.1\"\n"
+	files := map[string]string{
+		filepath.Join(api, "package-lock.json"): npmLock,
+		filepath.Join(api, "yarn.lock"):         yarnLock,
+		filepath.Join(web, "package-lock.json"): npmLock,
+		filepath.Join(web, "yarn.lock"):         yarnLock,
+		filepath.Join(dir, "web-list.txt"):      "is-odd@3.0.1 high\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobsFile := filepath.Join(dir, "jobs.yaml")
+	jobsContent := fmt.Sprintf(`jobs:
+  - root: %s
+    managers: [npm]
+  - root: %s
+    managers: [yarn]
+    list-path: %s
+`, api, web, filepath.Join(dir, "web-list.txt"))
+	if err := os.WriteFile(jobsFile, []byte(jobsContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := loadJobs(jobsFile, Job{Managers: []string{"yarn", "npm", "pnpm", "bun"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	severities := map[string]string{"left-pad@1.3.0": severityCritical}
+	opts := scanOptions{knownBad: map[string]string{"sha512-shared==": "left-pad@1.3.0"}}
+	result, errs := runJobs(jobs, 2, affected, severities, opts, func(job Job) ([]byte, error) {
+		return os.ReadFile(job.ListPath)
+	})
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected job errors: %v", errs)
+	}
+
+	found := make(map[string][]Package)
+	for _, res := range result.Results {
+		for _, pkg := range res.Packages {
+			if pkg.IsAffected {
+				found[res.LockFile] = append(found[res.LockFile], pkg)
+			}
+		}
+	}
+
+	if len(found) != 2 {
+		t.Fatalf("Expected one finding per job, got %v", found)
+	}
+	if api := found[filepath.Join(api, "package-lock.json")]; len(api) != 1 || api[0].Name != "left-pad" || api[0].Severity != severityCritical {
+		t.Errorf("Expected the npm job to flag left-pad with the shared list's severity, got %+v", api)
+	}
+	// The shared list's known-bad hash must not flag left-pad in a job with its own list
+	if web := found[filepath.Join(web, "yarn.lock")]; len(web) != 1 || web[0].Name != "is-odd" || web[0].Severity != severityHigh {
+		t.Errorf("Expected the yarn job to flag only is-odd with its own list's severity, got %+v", web)
+	}
+	if result.Summary.TotalLockfiles != 2 {
+		t.Errorf("Expected each job to scan only its own manager's lockfile, got %d lockfiles", result.Summary.TotalLockfiles)
+	}
+}
+
+// Test that a jobs file entry without a root is rejected
+func TestLoadJobsRequiresRoot(t *testing.T) {
+	jobsFile := filepath.Join(t.TempDir(), "jobs.yaml")
+	if err := os.WriteFile(jobsFile, []byte("jobs:\n  - managers: [npm]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadJobs(jobsFile, Job{}); err == nil {
+		t.Error("Expected an error for a job without a root")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ScanMetadata describes the scan that produced a report
+type ScanMetadata struct {
+	Version    string
+	ListSource string
+	Root       string
+	Timestamp  time.Time
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite represents a single lockfile in a JUnit XML report
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+// junitProperty is a name/value pair attached to a test suite
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase represents a single flagged package
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+// junitFailure marks a compromised package
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSkipped marks a package that only has a warning
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the scan result as a JUnit XML report
+func writeJUnit(w io.Writer, result ScanResult, meta ScanMetadata) error {
+	timestamp := meta.Timestamp.UTC().Format(time.RFC3339)
+	properties := []junitProperty{
+		{Name: "scanner.version", Value: meta.Version},
+		{Name: "scanner.listSource", Value: meta.ListSource},
+		{Name: "scanner.root", Value: meta.Root},
+		{Name: "scanner.timestamp", Value: timestamp},
+	}
+
+	report := junitTestSuites{}
+	for _, res := range result.Results {
+		suite := junitTestSuite{
+			Name:       res.LockFile,
+			Timestamp:  timestamp,
+			Properties: properties,
+		}
+
+		for _, pkg := range res.Packages {
+			testCase := junitTestCase{
+				Name:      fmt.Sprintf("%s@%s", pkg.Name, pkg.Version),
+				ClassName: res.LockFile,
+			}
+			if pkg.IsAffected {
+				testCase.Failure = &junitFailure{
+					Message: fmt.Sprintf("compromised package %s@%s", pkg.Name, pkg.Version),
+					Type:    "CompromisedPackage",
+					Text:    fmt.Sprintf("%s@%s in %s", pkg.Name, pkg.Version, res.LockFile),
+				}
+				suite.Failures++
+			} else if pkg.IsWarning {
+				testCase.Skipped = &junitSkipped{
+					Message: fmt.Sprintf("current version is safe, but vulnerable versions of %s exist", pkg.Name),
+				}
+				suite.Skipped++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Tests++
+		}
+
+		report.TestSuites = append(report.TestSuites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+	}
+
+	// Always emit a suite so the metadata is present even for clean scans
+	if len(report.TestSuites) == 0 {
+		report.TestSuites = append(report.TestSuites, junitTestSuite{
+			Name:       meta.Root,
+			Timestamp:  timestamp,
+			Properties: properties,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
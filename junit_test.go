@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+// Test that JUnit reports carry scan metadata as testsuite properties
+func TestWriteJUnitProperties(t *testing.T) {
+	result := ScanResult{
+		Root: "/repo",
+		Results: []Result{
+			{
+				LockFile: "/repo/package-lock.json",
+				Packages: []Package{
+					{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+					{Name: "@scoped/package", Version: "2.1.0", IsWarning: true},
+				},
+			},
+		},
+	}
+	meta := ScanMetadata{
+		Version:    "1.2.3",
+		ListSource: "security/packages.txt",
+		Root:       "/repo",
+		Timestamp:  time.Date(2025, 9, 16, 12, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := writeJUnit(&buf, result, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v\n%s", err, buf.String())
+	}
+
+	if len(parsed.TestSuites) != 1 {
+		t.Fatalf("Expected 1 testsuite, got %d", len(parsed.TestSuites))
+	}
+	suite := parsed.TestSuites[0]
+	if suite.Tests != 2 || suite.Failures != 1 || suite.Skipped != 1 {
+		t.Errorf("Unexpected counts: tests=%d failures=%d skipped=%d", suite.Tests, suite.Failures, suite.Skipped)
+	}
+
+	properties := make(map[string]string)
+	for _, prop := range suite.Properties {
+		properties[prop.Name] = prop.Value
+	}
+
+	expected := map[string]string{
+		"scanner.version":    "1.2.3",
+		"scanner.listSource": "security/packages.txt",
+		"scanner.root":       "/repo",
+		"scanner.timestamp":  "2025-09-16T12:00:00Z",
+	}
+	for name, value := range expected {
+		if properties[name] != value {
+			t.Errorf("Property %s = %q, want %q", name, properties[name], value)
+		}
+	}
+}
+
+// Test that a clean scan still produces a testsuite with metadata
+func TestWriteJUnitCleanScan(t *testing.T) {
+	meta := ScanMetadata{Version: "1.0.0", ListSource: "embedded", Root: "/repo", Timestamp: time.Now()}
+
+	var buf bytes.Buffer
+	if err := writeJUnit(&buf, ScanResult{Root: "/repo"}, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.TestSuites) != 1 || len(parsed.TestSuites[0].Properties) != 4 {
+		t.Errorf("Expected one testsuite with 4 properties, got %+v", parsed.TestSuites)
+	}
+}
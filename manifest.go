@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// manifestDependencySections are the package.json fields --scan-manifests reads, in report order
+var manifestDependencySections = []string{"dependencies", "devDependencies", "optionalDependencies", "peerDependencies"}
+
+// manifestFormat is the pseudo lockfile format --scan-manifests adds to the selected managers,
+// so findLockfiles discovers package.json files and scanLockfile checks their declared ranges
+const manifestFormat = "manifest"
+
+// parseManifest checks the declared dependency ranges of a package.json found alongside the
+// lockfiles. Its findings are warnings only
+func parseManifest(manifest string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	packages, err := scanManifestRanges(manifest, affected)
+	if err != nil {
+		opts.warn.warnf("scanning manifests failed: %v", err)
+		return nil, nil, false, false
+	}
+	stats.fileParsed()
+	return packages, nil, false, len(packages) > 0
+}
+
+// scanManifestRanges reports each dependency of a package.json whose declared range admits a
+// compromised version. Nothing pins what a range installs, so these are warnings, never
+// compromised findings
+func scanManifestRanges(manifest string, affected map[string]map[string]bool) ([]Package, error) {
+	content, err := readLockfile(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(content, &sections); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", manifest, err)
+	}
+
+	locked := false
+	for _, name := range lockfileNames {
+		if fileExists(filepath.Join(filepath.Dir(manifest), name)) {
+			locked = true
+			break
+		}
+	}
+
+	var packages []Package
+	seen := make(map[string]bool)
+	for _, section := range manifestDependencySections {
+		var deps map[string]string
+		if raw, ok := sections[section]; !ok || json.Unmarshal(raw, &deps) != nil {
+			continue
+		}
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, installedAs := range names {
+			spec := deps[installedAs]
+			name, rng, ok := registryDependency(installedAs, spec)
+			if !ok || seen[name+"@"+spec] {
+				continue
+			}
+			seen[name+"@"+spec] = true
+
+			var admitted []string
+			for listed := range affected[name] {
+				if rangesIntersect(listed, rng) {
+					admitted = append(admitted, listed)
+				}
+			}
+			if len(admitted) == 0 {
+				continue
+			}
+			sortAffectedVersions(admitted)
+
+			pkg := Package{
+				Name:             name,
+				Version:          spec,
+				IsWarning:        true,
+				AffectedVersions: admitted,
+				Confidence:       confidenceLow,
+				Notice:           fmt.Sprintf("%s range could install a compromised version", section),
+			}
+			if !locked {
+				pkg.Notice += ", no lockfile pins the installed version"
+			}
+			if name != installedAs {
+				pkg.Alias = installedAs
+			}
+			packages = append(packages, pkg)
+		}
+	}
+	return packages, nil
+}
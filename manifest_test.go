@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Test that --scan-manifests discovers package.json files with the lockfiles and reports
+// declared ranges admitting a compromised version as warnings
+func TestScanManifests(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app/package.json": `{
+  "dependencies": {"chalk": "^5.6.0", "debug": "~4.3.0", "local-lib": "file:../lib"},
+  "devDependencies": {"my-tinycolor": "npm:@ctrl/tinycolor@4.1.1"},
+  "optionalDependencies": {"left-pad": "1.2.x"},
+  "peerDependencies": {"chalk": "^5.6.0", "react": "*"}
+}`,
+		"locked/package.json":      `{"dependencies": {"chalk": "^5.6.0"}}`,
+		"locked/package-lock.json": `{"lockfileVersion": 3}`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected := map[string]map[string]bool{
+		"chalk":           {"5.6.1": true},
+		"debug":           {"4.4.2": true},
+		"@ctrl/tinycolor": {"4.1.1": true, "4.1.2": true},
+		"left-pad":        {">=1.2.5 <1.3.0": true},
+	}
+	lockfiles, err := findLockfiles(root, []string{"npm", manifestFormat}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockfiles) != 3 {
+		t.Fatalf("Expected both manifests and the lockfile to be discovered, got %v", lockfiles)
+	}
+	results, anyAffected, anyWarnings := scanLockfiles(lockfiles, affected, nil, scanOptions{}, nil)
+	if anyAffected || !anyWarnings {
+		t.Errorf("Expected warnings only, got affected=%v warnings=%v", anyAffected, anyWarnings)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].LockFile < results[j].LockFile })
+	if len(results) != 2 || results[0].LockFile != filepath.Join(root, "app", "package.json") || results[1].LockFile != filepath.Join(root, "locked", "package.json") {
+		t.Fatalf("Expected findings for both manifests, got %+v", results)
+	}
+
+	// A lockfile next to the manifest pins what is actually installed
+	if locked := results[1].Packages; len(locked) != 1 || strings.Contains(locked[0].Notice, "no lockfile") {
+		t.Errorf("Expected the locked manifest's warning not to claim a missing lockfile, got %+v", locked)
+	}
+
+	found := make(map[string]Package)
+	for _, pkg := range results[0].Packages {
+		if !pkg.IsWarning || pkg.IsAffected {
+			t.Errorf("Expected manifest findings to be warnings only, got %+v", pkg)
+		}
+		found[pkg.Name] = pkg
+	}
+	if len(results[0].Packages) != 3 {
+		t.Errorf("Expected chalk, the aliased tinycolor and left-pad once each, got %+v", results[0].Packages)
+	}
+	chalk := found["chalk"]
+	if chalk.Version != "^5.6.0" || strings.Join(chalk.AffectedVersions, ",") != "5.6.1" || !strings.Contains(chalk.Notice, "could install a compromised version, no lockfile") {
+		t.Errorf("Expected chalk's range to admit 5.6.1, got %+v", chalk)
+	}
+	tinycolor := found["@ctrl/tinycolor"]
+	if tinycolor.Alias != "my-tinycolor" || strings.Join(tinycolor.AffectedVersions, ",") != "4.1.1" || !strings.HasPrefix(tinycolor.Notice, "devDependencies") {
+		t.Errorf("Expected the aliased devDependency pinned to 4.1.1, got %+v", tinycolor)
+	}
+	if _, ok := found["left-pad"]; !ok {
+		t.Errorf("Expected left-pad's range to overlap the listed range, got %+v", found)
+	}
+	if _, ok := found["debug"]; ok {
+		t.Errorf("Expected debug ~4.3.0 not to admit 4.4.2, got %+v", found["debug"])
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// loadScanResult reads a ScanResult JSON report written by --json or --json-path
+func loadScanResult(path string) (ScanResult, error) {
+	var result ScanResult
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(content, &result)
+	return result, err
+}
+
+// mergeResults combines partial reports, such as those from parallel CI jobs scanning
+// subtrees, into one report. Findings for the same lockfile are merged and deduplicated,
+// and the summary is recomputed, counting a lockfile reported by several jobs once
+func mergeResults(results []ScanResult) ScanResult {
+	merged := ScanResult{Results: []Result{}}
+	index := make(map[string]int)
+	seen := make(map[string]map[string]bool)
+	totalLockfiles := 0
+
+	for i, result := range results {
+		if i == 0 {
+			merged.Root = result.Root
+		} else if merged.Root != result.Root {
+			merged.Root = ""
+		}
+		totalLockfiles += result.Summary.TotalLockfiles
+
+		for _, res := range result.Results {
+			position, exists := index[res.LockFile]
+			if !exists {
+				position = len(merged.Results)
+				index[res.LockFile] = position
+				seen[res.LockFile] = make(map[string]bool)
+				merged.Results = append(merged.Results, Result{LockFile: res.LockFile})
+			} else {
+				// The same lockfile was scanned by more than one job
+				totalLockfiles--
+			}
+
+			merged.Results[position].Incomplete = merged.Results[position].Incomplete || res.Incomplete
+			merged.AnyWarnings = merged.AnyWarnings || res.Incomplete
+			for _, notice := range res.Notices {
+				key := "\x00notice\x00" + notice
+				if seen[res.LockFile][key] {
+					continue
+				}
+				seen[res.LockFile][key] = true
+				merged.Results[position].Notices = append(merged.Results[position].Notices, notice)
+			}
+
+			for _, pkg := range res.Packages {
+				key := pkg.Name + "@" + pkg.Version + "\x00" + pkg.Notice
+				if seen[res.LockFile][key] {
+					continue
+				}
+				seen[res.LockFile][key] = true
+				merged.Results[position].Packages = append(merged.Results[position].Packages, pkg)
+				merged.AnyAffected = merged.AnyAffected || pkg.IsAffected
+				merged.AnyWarnings = merged.AnyWarnings || pkg.IsWarning
+				merged.AnySuspicious = merged.AnySuspicious || pkg.IsSuspicious
+			}
+		}
+	}
+
+	merged.Summary = summarizeResults(merged.Results, totalLockfiles)
+	return merged
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that two partial reports merge into a consistent whole
+func TestMergeResults(t *testing.T) {
+	first := ScanResult{
+		Root: "/repo",
+		Results: []Result{
+			{LockFile: "/repo/a/yarn.lock", Packages: []Package{
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			}},
+			{LockFile: "/repo/shared/package-lock.json", Packages: []Package{
+				{Name: "is-odd", Version: "3.0.0", IsWarning: true},
+			}},
+		},
+		AnyAffected: true,
+		AnyWarnings: true,
+		Summary:     Summary{TotalLockfiles: 5, TotalPackages: 2, TotalCompromised: 1, TotalWarnings: 1},
+	}
+	second := ScanResult{
+		Root: "/repo",
+		Results: []Result{
+			{LockFile: "/repo/b/pnpm-lock.yaml", Packages: []Package{
+				{Name: "@scoped/package", Version: "2.0.0", IsAffected: true},
+			}},
+			{LockFile: "/repo/shared/package-lock.json", Packages: []Package{
+				{Name: "is-odd", Version: "3.0.0", IsWarning: true},
+			}},
+		},
+		AnyAffected: true,
+		AnyWarnings: true,
+		Summary:     Summary{TotalLockfiles: 3, TotalPackages: 2, TotalCompromised: 1, TotalWarnings: 1},
+	}
+
+	// Round-trip one report through disk as the CLI does
+	path := filepath.Join(t.TempDir(), "second.json")
+	content, err := json.Marshal(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadScanResult(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := mergeResults([]ScanResult{first, loaded})
+
+	if merged.Root != "/repo" || !merged.AnyAffected || !merged.AnyWarnings {
+		t.Errorf("Expected merged root and flags, got %+v", merged)
+	}
+	if len(merged.Results) != 3 {
+		t.Fatalf("Expected 3 distinct lockfiles, got %d", len(merged.Results))
+	}
+
+	expected := Summary{TotalLockfiles: 7, TotalPackages: 3, TotalCompromised: 2, TotalWarnings: 1}
+	if merged.Summary != expected {
+		t.Errorf("Expected summary %+v, got %+v", expected, merged.Summary)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// OrgSnapshot records organization-wide exposure at one point in time
+type OrgSnapshot struct {
+	Date          string   `json:"date"`
+	Repos         int      `json:"repos"`
+	AffectedRepos int      `json:"affectedRepos"`
+	Summary       Summary  `json:"summary"`
+	Compromised   []string `json:"compromised"`
+}
+
+// OrgHistory is the --history file, snapshots ordered oldest first
+type OrgHistory struct {
+	Snapshots []OrgSnapshot `json:"snapshots"`
+}
+
+// OrgReport is the current organization-wide exposure and how it changed since the
+// previous snapshot
+type OrgReport struct {
+	Current  OrgSnapshot  `json:"current"`
+	Previous *OrgSnapshot `json:"previous,omitempty"`
+	New      []string     `json:"new"`
+	Resolved []string     `json:"resolved"`
+}
+
+// buildOrgSnapshot combines per-repo scan reports into one snapshot. Totals are summed, since
+// lockfile paths of different repos may coincide, and compromised packages are collected
+// org-wide as distinct name@version pairs
+func buildOrgSnapshot(reports []ScanResult, date time.Time) OrgSnapshot {
+	snapshot := OrgSnapshot{Date: date.UTC().Format(time.RFC3339), Repos: len(reports), Compromised: []string{}}
+	compromised := make(map[string]bool)
+	for _, report := range reports {
+		snapshot.Summary.TotalLockfiles += report.Summary.TotalLockfiles
+		snapshot.Summary.TotalPackages += report.Summary.TotalPackages
+		snapshot.Summary.TotalWarnings += report.Summary.TotalWarnings
+		snapshot.Summary.TotalCompromised += report.Summary.TotalCompromised
+		snapshot.Summary.TotalSuspicious += report.Summary.TotalSuspicious
+		if report.AnyAffected {
+			snapshot.AffectedRepos++
+		}
+		for _, res := range report.Results {
+			for _, pkg := range res.Packages {
+				if pkg.IsAffected {
+					compromised[pkg.Name+"@"+pkg.Version] = true
+				}
+			}
+		}
+	}
+	for pkg := range compromised {
+		snapshot.Compromised = append(snapshot.Compromised, pkg)
+	}
+	sort.Strings(snapshot.Compromised)
+	return snapshot
+}
+
+// loadOrgHistory reads a history file; a missing file is an empty history
+func loadOrgHistory(path string) (OrgHistory, error) {
+	var history OrgHistory
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return history, err
+	}
+	err = json.Unmarshal(content, &history)
+	return history, err
+}
+
+// appendOrgSnapshot adds snapshot to the history and writes it back to path
+func appendOrgSnapshot(path string, history OrgHistory, snapshot OrgSnapshot, indent string) error {
+	history.Snapshots = append(history.Snapshots, snapshot)
+	output, err := json.MarshalIndent(history, "", indent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, output, 0644)
+}
+
+// buildOrgReport compares current against the latest snapshot in history, listing the
+// compromised packages that appeared and those that were resolved since
+func buildOrgReport(history OrgHistory, current OrgSnapshot) OrgReport {
+	report := OrgReport{Current: current, New: []string{}, Resolved: []string{}}
+	if len(history.Snapshots) == 0 {
+		report.New = append(report.New, current.Compromised...)
+		return report
+	}
+
+	previous := history.Snapshots[len(history.Snapshots)-1]
+	report.Previous = &previous
+	before := make(map[string]bool)
+	for _, pkg := range previous.Compromised {
+		before[pkg] = true
+	}
+	now := make(map[string]bool)
+	for _, pkg := range current.Compromised {
+		now[pkg] = true
+		if !before[pkg] {
+			report.New = append(report.New, pkg)
+		}
+	}
+	for _, pkg := range previous.Compromised {
+		if !now[pkg] {
+			report.Resolved = append(report.Resolved, pkg)
+		}
+	}
+	return report
+}
+
+// printOrgReport prints the organization report in human-readable form
+func printOrgReport(report OrgReport, noColor bool) {
+	current := report.Current
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	colorPrint("🏢 ORGANIZATION REPORT\n", "cyan", noColor)
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	colorPrint(fmt.Sprintf("   Repositories: %d (%d affected)\n", current.Repos, current.AffectedRepos), "white", noColor)
+	colorPrint(fmt.Sprintf("   Lockfiles scanned: %d\n", current.Summary.TotalLockfiles), "white", noColor)
+	if current.Summary.TotalCompromised > 0 {
+		colorPrint(fmt.Sprintf("   Compromised findings: ❌ %d (%d distinct packages)\n", current.Summary.TotalCompromised, len(current.Compromised)), "red", noColor)
+	} else {
+		colorPrint("   Compromised findings: ✅ 0\n", "green", noColor)
+	}
+	colorPrint(fmt.Sprintf("   Warning findings: %d\n\n", current.Summary.TotalWarnings), "white", noColor)
+
+	if report.Previous == nil {
+		colorPrint("First snapshot, every compromised package counts as new:\n", "cyan", noColor)
+	} else {
+		colorPrint(fmt.Sprintf("Since %s (%+d compromised findings):\n", report.Previous.Date,
+			current.Summary.TotalCompromised-report.Previous.Summary.TotalCompromised), "cyan", noColor)
+		if len(report.New) == 0 && len(report.Resolved) == 0 {
+			colorPrint("  no change in compromised packages\n", "gray", noColor)
+		}
+	}
+	for _, pkg := range report.New {
+		colorPrint(fmt.Sprintf("  + %s\n", pkg), "red", noColor)
+	}
+	for _, pkg := range report.Resolved {
+		colorPrint(fmt.Sprintf("  - %s\n", pkg), "green", noColor)
+	}
+}
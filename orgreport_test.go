@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Test that the second snapshot reports the compromised packages that appeared and were resolved
+func TestOrgReportDelta(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.json")
+	report := func(root string, packages ...Package) ScanResult {
+		result := ScanResult{Root: root, Results: []Result{{LockFile: "package-lock.json", Packages: packages}}}
+		result.Summary = summarizeResults(result.Results, 1)
+		result.AnyAffected = result.Summary.TotalCompromised > 0
+		return result
+	}
+
+	first := buildOrgSnapshot([]ScanResult{
+		report("api", Package{Name: "chalk", Version: "5.6.1", IsAffected: true}),
+		report("web", Package{Name: "chalk", Version: "5.6.1", IsAffected: true}, Package{Name: "debug", Version: "4.4.2", IsAffected: true}),
+	}, time.Date(2025, 9, 9, 0, 0, 0, 0, time.UTC))
+	history, err := loadOrgHistory(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := appendOrgSnapshot(historyPath, history, first, "  "); err != nil {
+		t.Fatal(err)
+	}
+
+	second := buildOrgSnapshot([]ScanResult{
+		report("api"),
+		report("web", Package{Name: "debug", Version: "4.4.2", IsAffected: true}, Package{Name: "ansi-styles", Version: "6.2.2", IsAffected: true}),
+	}, time.Date(2025, 9, 16, 0, 0, 0, 0, time.UTC))
+	history, err = loadOrgHistory(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta := buildOrgReport(history, second)
+
+	if delta.Previous == nil || delta.Previous.Date != "2025-09-09T00:00:00Z" || delta.Previous.Summary.TotalCompromised != 3 {
+		t.Fatalf("Expected the first snapshot as the baseline, got %+v", delta.Previous)
+	}
+	if !reflect.DeepEqual(delta.New, []string{"ansi-styles@6.2.2"}) {
+		t.Errorf("Expected ansi-styles@6.2.2 to be new, got %v", delta.New)
+	}
+	if !reflect.DeepEqual(delta.Resolved, []string{"chalk@5.6.1"}) {
+		t.Errorf("Expected chalk@5.6.1 to be resolved, got %v", delta.Resolved)
+	}
+	if second.Repos != 2 || second.AffectedRepos != 1 || second.Summary.TotalCompromised != 2 {
+		t.Errorf("Expected 2 repos, 1 affected, 2 compromised findings, got %+v", second)
+	}
+}
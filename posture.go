@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseNpmrcPosture returns a description of each .npmrc setting that weakens install-time
+// verification: disabled TLS certificate checks and registries reached over plain http
+func parseNpmrcPosture(content string) []string {
+	var settings []string
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+		key, value, found := strings.Cut(trimmed, "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch {
+		case key == "strict-ssl" && value == "false":
+			settings = append(settings, fmt.Sprintf("line %d: strict-ssl=false disables TLS certificate verification", i+1))
+		case (key == "registry" || strings.HasSuffix(key, ":registry")) && strings.HasPrefix(value, "http://"):
+			settings = append(settings, fmt.Sprintf("line %d: %s uses plain http, so tarballs can be swapped in transit", i+1, key))
+		}
+	}
+	return settings
+}
+
+// npmLockIntegrityGap counts the registry entries of an npm lockfile and how many of them lack
+// an integrity hash. Linked, bundled and file: entries never carry one and are not counted
+func npmLockIntegrityGap(content []byte) (total, missing int) {
+	var lockfileData struct {
+		Packages map[string]struct {
+			Version   string `json:"version"`
+			Resolved  string `json:"resolved"`
+			Integrity string `json:"integrity"`
+			Link      bool   `json:"link"`
+			Bundled   bool   `json:"inBundle"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(content, &lockfileData); err != nil {
+		return 0, 0
+	}
+	for key, entry := range lockfileData.Packages {
+		if key == "" || entry.Link || entry.Bundled || entry.Version == "" || strings.HasPrefix(entry.Resolved, "file:") {
+			continue
+		}
+		total++
+		if entry.Integrity == "" {
+			missing++
+		}
+	}
+	return total, missing
+}
+
+// checkPosture reports weakened supply-chain settings as notices: .npmrc files under the roots
+// that disable verification, and npm lockfiles where most entries have no integrity hash
+func checkPosture(roots, lockfiles []string) []Result {
+	var results []Result
+	report := func(path string, settings []string) {
+		if len(settings) == 0 {
+			return
+		}
+		var notices []string
+		for _, setting := range settings {
+			notices = append(notices, "weakened supply-chain posture: "+setting)
+		}
+		results = append(results, Result{LockFile: path, Notices: notices})
+	}
+
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Skip inaccessible files
+			}
+			if d.IsDir() {
+				if d.Name() == "node_modules" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Name() != ".npmrc" {
+				return nil
+			}
+			if content, err := os.ReadFile(path); err == nil {
+				report(path, parseNpmrcPosture(string(content)))
+			}
+			return nil
+		})
+	}
+
+	for _, lockfile := range lockfiles {
+		if lockfileFormat(filepath.Base(lockfile), nil) != "npm" {
+			continue
+		}
+		content, err := readLockfile(lockfile)
+		if err != nil {
+			continue
+		}
+		// A few entries without integrity are normal for git and tarball URL dependencies
+		if total, missing := npmLockIntegrityGap(content); missing > 0 && missing*2 >= total {
+			report(lockfile, []string{fmt.Sprintf("%d of %d entries have no integrity hash, so tampered tarballs install unnoticed", missing, total)})
+		}
+	}
+
+	return results
+}
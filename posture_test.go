@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that an .npmrc turning off strict-ssl is reported as a posture notice
+func TestCheckPostureNpmrc(t *testing.T) {
+	root := t.TempDir()
+	npmrc := filepath.Join(root, "apps", "web", ".npmrc")
+	if err := os.MkdirAll(filepath.Dir(npmrc), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "# internal mirror\nregistry=https://registry.npmjs.org/\nstrict-ssl=false\n"
+	if err := os.WriteFile(npmrc, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkPosture([]string{root}, nil)
+	if len(results) != 1 || results[0].LockFile != npmrc || len(results[0].Packages) != 0 || len(results[0].Notices) != 1 {
+		t.Fatalf("Expected one posture notice for %s, got %+v", npmrc, results)
+	}
+	if notice := results[0].Notices[0]; !strings.Contains(notice, "line 3: strict-ssl=false") {
+		t.Errorf("Expected an informational strict-ssl notice, got %q", notice)
+	}
+}
+
+func TestParseNpmrcPosture(t *testing.T) {
+	content := "strict-ssl=true\n; strict-ssl=false\n@corp:registry=http://npm.corp.example/\n"
+	settings := parseNpmrcPosture(content)
+	if len(settings) != 1 || !strings.Contains(settings[0], "@corp:registry uses plain http") {
+		t.Errorf("Expected only the plain http scoped registry to be reported, got %v", settings)
+	}
+}
+
+// Test that a lockfile with no integrity hashes is reported but one with occasional gaps is not
+func TestCheckPostureMissingIntegrity(t *testing.T) {
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "package-lock.json")
+	write := func(content string) {
+		if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"lockfileVersion": 3, "packages": {
+		"": {"name": "app"},
+		"node_modules/chalk": {"version": "5.6.0", "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.0.tgz"},
+		"node_modules/debug": {"version": "4.4.0", "resolved": "https://registry.npmjs.org/debug/-/debug-4.4.0.tgz"}
+	}}`)
+	results := checkPosture(nil, []string{lockfile})
+	if len(results) != 1 || len(results[0].Notices) != 1 || !strings.Contains(results[0].Notices[0], "2 of 2 entries have no integrity hash") {
+		t.Errorf("Expected the missing integrity to be reported, got %+v", results)
+	}
+
+	write(`{"lockfileVersion": 3, "packages": {
+		"node_modules/chalk": {"version": "5.6.0", "integrity": "sha512-AAAA"},
+		"node_modules/debug": {"version": "4.4.0", "integrity": "sha512-BBBB"},
+		"node_modules/local": {"version": "1.0.0", "resolved": "git+ssh://git@github.com/acme/local.git"}
+	}}`)
+	if results := checkPosture(nil, []string{lockfile}); len(results) != 0 {
+		t.Errorf("Expected a single git dependency without integrity to pass, got %+v", results)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRegistryURL is the public npm registry
+const defaultRegistryURL = "https://registry.npmjs.org"
+
+// defaultDownloadsURL is the npm download counts API, which only covers the public registry
+const defaultDownloadsURL = "https://api.npmjs.org/downloads"
+
+// registryCacheTTL controls how long fetched registry metadata is reused from disk
+const registryCacheTTL = 24 * time.Hour
+
+// registryPackument is the subset of npm registry package metadata we use
+type registryPackument struct {
+	DistTags map[string]string `json:"dist-tags"`
+	Time     map[string]string `json:"time"`
+	Versions map[string]struct {
+		Deprecated           string            `json:"deprecated"`
+		Dependencies         map[string]string `json:"dependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+		Dist                 struct {
+			Integrity string `json:"integrity"`
+			Shasum    string `json:"shasum"`
+		} `json:"dist"`
+	} `json:"versions"`
+}
+
+// registryClient fetches and caches package metadata from an npm-compatible registry
+type registryClient struct {
+	baseURL      string
+	downloadsURL string
+	httpClient   *http.Client
+	cacheDir     string
+	memo         map[string]*registryPackument
+	downloads    map[string]int
+}
+
+// newRegistryClient creates a registry client; an empty cacheDir disables the disk cache.
+// Download counts are only looked up for the public registry, mirrors have no counts API
+func newRegistryClient(baseURL, cacheDir string) *registryClient {
+	client := &registryClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		cacheDir:   cacheDir,
+		memo:       make(map[string]*registryPackument),
+		downloads:  make(map[string]int),
+	}
+	if client.baseURL == defaultRegistryURL {
+		client.downloadsURL = defaultDownloadsURL
+	}
+	return client
+}
+
+// defaultRegistryCacheDir returns the on-disk cache location for registry metadata
+func defaultRegistryCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shai-hulud-scanner", "registry")
+}
+
+// fetch returns the packument for a package, using the memory and disk caches when possible
+func (c *registryClient) fetch(name string) (*registryPackument, error) {
+	if doc, ok := c.memo[name]; ok {
+		return doc, nil
+	}
+
+	cachePath := ""
+	if c.cacheDir != "" {
+		cachePath = filepath.Join(c.cacheDir, url.PathEscape(name)+".json")
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < registryCacheTTL {
+			if content, err := os.ReadFile(cachePath); err == nil {
+				var doc registryPackument
+				if err := json.Unmarshal(content, &doc); err == nil {
+					c.memo[name] = &doc
+					return &doc, nil
+				}
+			}
+		}
+	}
+
+	// Scoped packages keep the leading @ but escape the slash
+	resp, err := c.httpClient.Get(c.baseURL + "/" + strings.Replace(name, "/", "%2f", 1))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// The whole package is gone from the registry
+		doc := &registryPackument{}
+		c.memo[name] = doc
+		return doc, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, name)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc registryPackument
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	c.memo[name] = &doc
+
+	if cachePath != "" {
+		if err := os.MkdirAll(c.cacheDir, 0755); err == nil {
+			os.WriteFile(cachePath, content, 0644)
+		}
+	}
+
+	return &doc, nil
+}
+
+// weeklyDownloads returns a package's download count over the last week. ok is false when
+// the client has no download counts API to ask
+func (c *registryClient) weeklyDownloads(name string) (count int, ok bool, err error) {
+	if c.downloadsURL == "" {
+		return 0, false, nil
+	}
+	if count, found := c.downloads[name]; found {
+		return count, true, nil
+	}
+
+	resp, err := c.httpClient.Get(c.downloadsURL + "/point/last-week/" + name)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	// Packages the counts API has never seen have no downloads yet
+	if resp.StatusCode == http.StatusNotFound {
+		c.downloads[name] = 0
+		return 0, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("download counts API returned %s for %s", resp.Status, name)
+	}
+
+	var point struct {
+		Downloads int `json:"downloads"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&point); err != nil {
+		return 0, false, err
+	}
+	c.downloads[name] = point.Downloads
+	return point.Downloads, true, nil
+}
+
+// enrichPackage annotates an affected package with deprecation status, a suggested safe
+// version, its weekly downloads and first-publish date
+func (c *registryClient) enrichPackage(pkg *Package, affectedVersions map[string]bool) error {
+	doc, err := c.fetch(pkg.Name)
+	if err != nil {
+		return err
+	}
+
+	pkg.FirstPublished = doc.Time["created"]
+	if count, ok, err := c.weeklyDownloads(pkg.Name); err != nil {
+		return err
+	} else if ok {
+		pkg.WeeklyDownloads = count
+	}
+
+	// A version missing from the packument has been unpublished
+	if meta, ok := doc.Versions[pkg.Version]; !ok || meta.Deprecated != "" {
+		pkg.Deprecated = true
+	}
+
+	comparator := versionComparatorFor("npm")
+	isCandidate := func(version string) bool {
+		meta, ok := doc.Versions[version]
+		_, listed := listedVersion(comparator, affectedVersions, version)
+		return ok && meta.Deprecated == "" && !listed && !strings.Contains(version, "-")
+	}
+
+	if latest := doc.DistTags["latest"]; isCandidate(latest) {
+		pkg.SuggestedVersion = latest
+		return nil
+	}
+
+	for version := range doc.Versions {
+		if isCandidate(version) && (pkg.SuggestedVersion == "" || compareVersions(version, pkg.SuggestedVersion) > 0) {
+			pkg.SuggestedVersion = version
+		}
+	}
+
+	return nil
+}
+
+// enrichResults annotates every affected package in results using registry metadata
+func enrichResults(results []Result, affected map[string]map[string]bool, client *registryClient) []error {
+	var errs []error
+	for i := range results {
+		for j := range results[i].Packages {
+			pkg := &results[i].Packages[j]
+			if !pkg.IsAffected {
+				continue
+			}
+			if err := client.enrichPackage(pkg, affected[pkg.Name]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// flagUnpopularPackages adds a suspicious finding for each listed package in results with
+// fewer than threshold weekly downloads, since freshly planted malware has few installs
+func flagUnpopularPackages(results []Result, threshold int, client *registryClient) []error {
+	var errs []error
+	for i := range results {
+		var flagged []Package
+		seen := make(map[string]bool)
+		for _, pkg := range results[i].Packages {
+			if (!pkg.IsAffected && !pkg.IsWarning) || seen[pkg.Name] {
+				continue
+			}
+			seen[pkg.Name] = true
+
+			count, ok, err := client.weeklyDownloads(pkg.Name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !ok || count >= threshold {
+				continue
+			}
+
+			reason := fmt.Sprintf("only %d weekly downloads", count)
+			if doc, err := client.fetch(pkg.Name); err == nil && doc.Time["created"] != "" {
+				reason += ", first published " + doc.Time["created"]
+			}
+			flagged = append(flagged, suspiciousPackage(pkg.Name, pkg.Version, reason))
+		}
+		results[i].Packages = append(results[i].Packages, flagged...)
+	}
+	return errs
+}
+
+// flagNewerThanCompromisePackages adds a suspicious finding for each warning in results whose
+// installed version was published after the earliest compromised version of its package,
+// since an attacker holding publish rights may have kept releasing. Versions without a
+// recorded publish date are skipped
+func flagNewerThanCompromisePackages(results []Result, affected map[string]map[string]bool, client *registryClient) []error {
+	var errs []error
+	for i := range results {
+		var flagged []Package
+		seen := make(map[string]bool)
+		for _, pkg := range results[i].Packages {
+			if !pkg.IsWarning || seen[pkg.Name+"@"+pkg.Version] {
+				continue
+			}
+			seen[pkg.Name+"@"+pkg.Version] = true
+
+			doc, err := client.fetch(pkg.Name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			installed, err := time.Parse(time.RFC3339, doc.Time[pkg.Version])
+			if err != nil {
+				continue
+			}
+
+			var firstCompromised time.Time
+			firstVersion := ""
+			for _, version := range compromisedVersions(doc, affected[pkg.Name]) {
+				published, err := time.Parse(time.RFC3339, doc.Time[version])
+				if err == nil && (firstVersion == "" || published.Before(firstCompromised)) {
+					firstCompromised, firstVersion = published, version
+				}
+			}
+			if firstVersion == "" || !installed.After(firstCompromised) {
+				continue
+			}
+
+			flagged = append(flagged, suspiciousPackage(pkg.Name, pkg.Version,
+				fmt.Sprintf("published %s, after the first compromised version %s (%s)",
+					doc.Time[pkg.Version], firstVersion, doc.Time[firstVersion])))
+		}
+		results[i].Packages = append(results[i].Packages, flagged...)
+	}
+	return errs
+}
+
+// compromisedVersions lists the published versions the affected entries cover. Exact entries
+// are kept as listed, since compromised releases are often unpublished, and range entries are
+// expanded against the versions in the packument
+func compromisedVersions(doc *registryPackument, affectedVersions map[string]bool) []string {
+	var versions []string
+	var ranges []string
+	for entry := range affectedVersions {
+		if _, exact := parseSemver(entry); exact || exactVersionRegex.MatchString(entry) {
+			versions = append(versions, entry)
+		} else {
+			ranges = append(ranges, entry)
+		}
+	}
+	for version := range doc.Versions {
+		for _, rng := range ranges {
+			if satisfiesRange(version, rng) {
+				versions = append(versions, version)
+				break
+			}
+		}
+	}
+	return versions
+}
+
+// verifyChecksums flags findings whose recorded hash disagrees with the published artifact.
+// npm and Yarn classic integrity values are compared against the registry's dist hashes.
+// Yarn Berry checksums hash Yarn's own zip archive rather than the registry tarball, so they
+// are verified against the archive in the project's .yarn/cache when one is present.
+func verifyChecksums(results []Result, client *registryClient) []error {
+	var errs []error
+	for i := range results {
+		for j := range results[i].Packages {
+			pkg := &results[i].Packages[j]
+			if pkg.Integrity == "" || (!pkg.IsAffected && !pkg.IsWarning) {
+				continue
+			}
+
+			if algorithm, _, _ := strings.Cut(pkg.Integrity, "-"); algorithm == "sha512" || algorithm == "sha1" {
+				doc, err := client.fetch(pkg.Name)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				meta, ok := doc.Versions[pkg.Version]
+				if ok && !integrityMatchesDist(pkg.Integrity, meta.Dist.Integrity, meta.Dist.Shasum) {
+					pkg.ChecksumMismatch = true
+				}
+				continue
+			}
+
+			if _, checksum, found := strings.Cut(pkg.Integrity, "/"); found {
+				if archive := findYarnCacheArchive(filepath.Dir(results[i].LockFile), pkg.Name, pkg.Version); archive != "" {
+					if !yarnCacheChecksumMatches(archive, checksum) {
+						pkg.ChecksumMismatch = true
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// integrityMatchesDist compares a lockfile integrity value against registry dist hashes,
+// preferring sha512; hashes the registry does not publish are treated as matching
+func integrityMatchesDist(integrity, distIntegrity, distShasum string) bool {
+	hashes := make(map[string]string)
+	for _, token := range strings.Fields(integrity) {
+		if algorithm, value, found := strings.Cut(token, "-"); found {
+			hashes[algorithm] = value
+		}
+	}
+
+	if local, ok := hashes["sha512"]; ok {
+		for _, token := range strings.Fields(distIntegrity) {
+			if algorithm, value, _ := strings.Cut(token, "-"); algorithm == "sha512" {
+				return local == value
+			}
+		}
+	}
+	if local, ok := hashes["sha1"]; ok && distShasum != "" {
+		decoded, err := base64.StdEncoding.DecodeString(local)
+		return err == nil && hex.EncodeToString(decoded) == distShasum
+	}
+	return true
+}
+
+// findYarnCacheArchive locates the .yarn/cache archive for name@version next to a lockfile
+func findYarnCacheArchive(projectDir, name, version string) string {
+	pattern := filepath.Join(projectDir, ".yarn", "cache", strings.Replace(name, "/", "-", 1)+"-npm-"+version+"-*.zip")
+	matches, _ := filepath.Glob(pattern)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[0]
+}
+
+// compareVersions compares two dotted numeric versions, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	partsA := strings.Split(strings.SplitN(a, "-", 2)[0], ".")
+	partsB := strings.Split(strings.SplitN(b, "-", 2)[0], ".")
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numA, numB int
+		if i < len(partsA) {
+			numA, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			numB, _ = strconv.Atoi(partsB[i])
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test enrichment against a mock registry returning deprecation info
+func TestEnrichResultsDeprecated(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.EscapedPath() {
+		case "/left-pad":
+			w.Write([]byte(`{
+				"dist-tags": {"latest": "1.3.0"},
+				"versions": {
+					"1.1.0": {},
+					"1.2.0": {},
+					"1.3.0": {"deprecated": "compromised release"}
+				}
+			}`))
+		case "/@scoped%2fpackage":
+			w.Write([]byte(`{
+				"dist-tags": {"latest": "2.1.0"},
+				"versions": {"2.1.0": {}}
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+				{Name: "@scoped/package", Version: "2.0.0", IsAffected: true},
+				{Name: "safe-warning", Version: "1.0.0", IsWarning: true},
+			},
+		},
+	}
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+	}
+
+	client := newRegistryClient(server.URL, "")
+	if errs := enrichResults(results, affected, client); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	leftPad := results[0].Packages[0]
+	if !leftPad.Deprecated {
+		t.Error("Expected left-pad@1.3.0 to be marked deprecated")
+	}
+	if leftPad.SuggestedVersion != "1.2.0" {
+		t.Errorf("Expected suggested version 1.2.0, got %q", leftPad.SuggestedVersion)
+	}
+
+	scoped := results[0].Packages[2]
+	if !scoped.Deprecated {
+		t.Error("Expected unpublished @scoped/package@2.0.0 to be marked deprecated")
+	}
+	if scoped.SuggestedVersion != "2.1.0" {
+		t.Errorf("Expected suggested version 2.1.0, got %q", scoped.SuggestedVersion)
+	}
+
+	if results[0].Packages[3].SuggestedVersion != "" {
+		t.Error("Expected warning-only packages to be left alone")
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 registry requests thanks to caching, got %d", requests)
+	}
+}
+
+// Test that a suggested version never falls inside a listed range, even when it is latest
+func TestEnrichSuggestionSkipsListedRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"dist-tags": {"latest": "2.0.3"},
+			"versions": {
+				"1.9.0": {},
+				"2.0.0": {},
+				"2.0.3": {}
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{{Name: "chalk", Version: "2.0.0", IsAffected: true}},
+		},
+	}
+	affected := map[string]map[string]bool{"chalk": {">=2.0.0 <2.1.0": true}}
+
+	if errs := enrichResults(results, affected, newRegistryClient(server.URL, "")); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if suggested := results[0].Packages[0].SuggestedVersion; suggested != "1.9.0" {
+		t.Errorf("Expected 1.9.0, outside the listed range, got %q", suggested)
+	}
+}
+
+// Test that a lockfile integrity differing from the registry's dist hash is flagged
+func TestVerifyChecksumsMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/left-pad":
+			w.Write([]byte(`{"versions": {"1.3.0": {"dist": {"integrity": "sha512-published"}}}}`))
+		case "/is-odd":
+			w.Write([]byte(`{"versions": {"3.0.1": {"dist": {"integrity": "sha512-matching"}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true, Integrity: "sha512-tampered"},
+				{Name: "is-odd", Version: "3.0.1", IsWarning: true, Integrity: "sha512-matching"},
+				{Name: "clean", Version: "1.0.0", Integrity: "sha512-anything"},
+			},
+		},
+	}
+
+	client := newRegistryClient(server.URL, "")
+	if errs := verifyChecksums(results, client); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	if !results[0].Packages[0].ChecksumMismatch {
+		t.Error("Expected left-pad@1.3.0 to be flagged as a checksum mismatch")
+	}
+	if results[0].Packages[1].ChecksumMismatch {
+		t.Error("Expected is-odd@3.0.1 with a matching integrity to pass")
+	}
+	if results[0].Packages[2].ChecksumMismatch {
+		t.Error("Expected unflagged packages to be skipped")
+	}
+}
+
+// Test that Berry lockfile checksums are carried onto findings
+func TestParseYarnBerryChecksum(t *testing.T) {
+	content := `__metadata:
+  version: 6
+  cacheKey: 8
+
+"left-pad@npm:^1.3.0":
+  version: 1.3.0
+  resolution: "left-pad@npm:1.3.0"
+  checksum: 8/abcdef
+  languageName: node
+  linkType: hard
+`
+	path := filepath.Join(t.TempDir(), "yarn.lock")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	packages, _, hasAffected, _ := parseYarnLock(path, affected, scanOptions{}, nil)
+	if !hasAffected || len(packages) != 1 {
+		t.Fatalf("Expected left-pad@1.3.0 to be flagged, got %+v", packages)
+	}
+	if packages[0].Integrity != "8/abcdef" {
+		t.Errorf("Expected Berry checksum 8/abcdef, got %q", packages[0].Integrity)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.9.9", 1},
+		{"1.0.0.1", "1.0.0", 1},
+	}
+
+	for _, test := range tests {
+		if result := compareVersions(test.a, test.b); result != test.expected {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", test.a, test.b, result, test.expected)
+		}
+	}
+}
+
+// Test that enrichment records popularity and low-download packages are flagged
+func TestFlagUnpopularPackages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/planted-pkg":
+			w.Write([]byte(`{
+				"dist-tags": {"latest": "1.0.1"},
+				"time": {"created": "2025-09-14T10:00:00.000Z"},
+				"versions": {"1.0.0": {}, "1.0.1": {}}
+			}`))
+		case "/downloads/point/last-week/planted-pkg":
+			w.Write([]byte(`{"downloads": 12, "package": "planted-pkg"}`))
+		case "/downloads/point/last-week/lodash":
+			w.Write([]byte(`{"downloads": 50000000, "package": "lodash"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newRegistryClient(server.URL, "")
+	client.downloadsURL = server.URL + "/downloads"
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{
+				{Name: "planted-pkg", Version: "1.0.0", IsAffected: true},
+				{Name: "lodash", Version: "4.17.21", IsWarning: true},
+			},
+		},
+	}
+	affected := map[string]map[string]bool{"planted-pkg": {"1.0.0": true}}
+
+	if errs := enrichResults(results, affected, client); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	planted := results[0].Packages[0]
+	if planted.WeeklyDownloads != 12 || planted.FirstPublished != "2025-09-14T10:00:00.000Z" {
+		t.Errorf("Expected 12 downloads and a first-publish date, got %d and %q", planted.WeeklyDownloads, planted.FirstPublished)
+	}
+
+	if errs := flagUnpopularPackages(results, 1000, client); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results[0].Packages) != 3 {
+		t.Fatalf("Expected one suspicious finding to be added, got %+v", results[0].Packages)
+	}
+	flagged := results[0].Packages[2]
+	if !flagged.IsSuspicious || flagged.Name != "planted-pkg" || !strings.Contains(flagged.Notice, "only 12 weekly downloads") {
+		t.Errorf("Expected planted-pkg to be flagged as unpopular, got %+v", flagged)
+	}
+}
+
+// Test that an install published after the earliest compromised version is flagged
+func TestFlagNewerThanCompromisePackages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"time": {
+				"created": "2020-01-01T00:00:00.000Z",
+				"1.0.0": "2024-05-01T00:00:00.000Z",
+				"1.1.0": "2025-09-08T13:00:00.000Z",
+				"1.1.1": "2025-09-08T15:00:00.000Z",
+				"1.2.0": "2025-09-10T09:00:00.000Z"
+			},
+			"versions": {"1.0.0": {}, "1.2.0": {}}
+		}`))
+	}))
+	defer server.Close()
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{
+				{Name: "debug", Version: "1.2.0", IsWarning: true},
+			},
+		},
+		{
+			LockFile: "apps/legacy/package-lock.json",
+			Packages: []Package{
+				{Name: "debug", Version: "1.0.0", IsWarning: true},
+			},
+		},
+	}
+	affected := map[string]map[string]bool{"debug": {"1.1.1": true, "1.1.0": true}}
+
+	if errs := flagNewerThanCompromisePackages(results, affected, newRegistryClient(server.URL, "")); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results[0].Packages) != 2 {
+		t.Fatalf("Expected debug@1.2.0 to be flagged, got %+v", results[0].Packages)
+	}
+	flagged := results[0].Packages[1]
+	if !flagged.IsSuspicious || flagged.Version != "1.2.0" || !strings.Contains(flagged.Notice, "after the first compromised version 1.1.0") {
+		t.Errorf("Expected debug@1.2.0 to be flagged against 1.1.0, got %+v", flagged)
+	}
+	if len(results[1].Packages) != 1 {
+		t.Errorf("Expected debug@1.0.0, published before the compromise, to pass, got %+v", results[1].Packages)
+	}
+}
+
+// Test that a listed range is expanded to the versions it covers before comparing publish times
+func TestFlagNewerThanCompromiseRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"time": {
+				"1.0.0": "2024-05-01T00:00:00.000Z",
+				"1.1.0": "2025-09-08T13:00:00.000Z",
+				"1.1.1": "2025-09-08T15:00:00.000Z",
+				"1.2.0": "2025-09-10T09:00:00.000Z"
+			},
+			"versions": {"1.0.0": {}, "1.1.0": {}, "1.1.1": {}, "1.2.0": {}}
+		}`))
+	}))
+	defer server.Close()
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{{Name: "debug", Version: "1.2.0", IsWarning: true}},
+		},
+	}
+	affected := map[string]map[string]bool{"debug": {">=1.1.0 <1.2.0": true}}
+
+	if errs := flagNewerThanCompromisePackages(results, affected, newRegistryClient(server.URL, "")); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results[0].Packages) != 2 || !strings.Contains(results[0].Packages[1].Notice, "after the first compromised version 1.1.0") {
+		t.Errorf("Expected debug@1.2.0 to be flagged against 1.1.0 from the range, got %+v", results[0].Packages)
+	}
+}
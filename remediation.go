@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// noRemediation marks a compromised package with no known safe upgrade
+const noRemediation = "no remediation available"
+
+// RemediationStep is the planned upgrade for one compromised package version
+type RemediationStep struct {
+	Name      string   `json:"package"`
+	Version   string   `json:"version"`
+	Target    string   `json:"target,omitempty"`
+	Note      string   `json:"note,omitempty"`
+	LockFiles []string `json:"lockFiles"`
+}
+
+// nearestSafeVersion returns the lowest stable candidate above version that is not
+// compromised, or "" when every newer release is compromised or none exists
+func nearestSafeVersion(version string, candidates []string, affectedVersions map[string]bool) string {
+	comparator := versionComparatorFor("npm")
+	nearest := ""
+	for _, candidate := range candidates {
+		if _, listed := listedVersion(comparator, affectedVersions, candidate); listed || isPrerelease(candidate) {
+			continue
+		}
+		if compareVersions(candidate, version) <= 0 {
+			continue
+		}
+		if nearest == "" || compareVersions(candidate, nearest) < 0 {
+			nearest = candidate
+		}
+	}
+	return nearest
+}
+
+// planRemediation works out, without changing anything, which version each compromised
+// package would be upgraded to, using the registry's published, non-deprecated versions
+func planRemediation(results []Result, affected map[string]map[string]bool, client *registryClient) ([]RemediationStep, []error) {
+	var steps []RemediationStep
+	var errs []error
+	index := make(map[string]int)
+
+	for _, result := range results {
+		for _, pkg := range result.Packages {
+			if !pkg.IsAffected {
+				continue
+			}
+			key := pkg.Name + "@" + pkg.Version
+			if position, seen := index[key]; seen {
+				steps[position].LockFiles = append(steps[position].LockFiles, result.LockFile)
+				continue
+			}
+
+			step := RemediationStep{Name: pkg.Name, Version: pkg.Version, LockFiles: []string{result.LockFile}}
+			doc, err := client.fetch(pkg.Name)
+			if err != nil {
+				errs = append(errs, err)
+				step.Note = fmt.Sprintf("could not list versions: %v", err)
+			} else {
+				var candidates []string
+				for version, meta := range doc.Versions {
+					if meta.Deprecated == "" {
+						candidates = append(candidates, version)
+					}
+				}
+				step.Target = nearestSafeVersion(pkg.Version, candidates, affected[pkg.Name])
+				if step.Target == "" {
+					step.Note = noRemediation
+				}
+			}
+
+			index[key] = len(steps)
+			steps = append(steps, step)
+		}
+	}
+
+	return steps, errs
+}
+
+// printRemediationPlan prints the remediation plan in human-readable form
+func printRemediationPlan(steps []RemediationStep, noColor bool) {
+	colorPrint("🩹 Remediation plan (nothing was changed):\n", "cyan", noColor)
+	if len(steps) == 0 {
+		colorPrint("   No compromised packages to remediate\n", "green", noColor)
+		return
+	}
+	for _, step := range steps {
+		if step.Target != "" {
+			colorPrint(fmt.Sprintf("  %s@%s -> %s\n", step.Name, step.Version, step.Target), "green", noColor)
+		} else {
+			colorPrint(fmt.Sprintf("  %s@%s: %s\n", step.Name, step.Version, step.Note), "red", noColor)
+		}
+		colorPrint(fmt.Sprintf("    in: %s\n", strings.Join(step.LockFiles, ", ")), "gray", noColor)
+	}
+}
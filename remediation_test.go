@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test that the plan picks the nearest safe version above the compromised one
+func TestNearestSafeVersion(t *testing.T) {
+	candidates := []string{"1.0.0", "1.3.0", "1.3.1", "1.3.2", "1.4.0-rc.1", "1.4.0", "2.0.0"}
+	affectedVersions := map[string]bool{"1.3.0": true, "1.3.1": true}
+
+	if target := nearestSafeVersion("1.3.0", candidates, affectedVersions); target != "1.3.2" {
+		t.Errorf("Expected 1.3.2, got %q", target)
+	}
+	if target := nearestSafeVersion("2.0.0", candidates, affectedVersions); target != "" {
+		t.Errorf("Expected no safe version above the latest release, got %q", target)
+	}
+}
+
+// Test the plan against a mock registry, including a package with no safe upgrade
+func TestPlanRemediation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/left-pad":
+			w.Write([]byte(`{"versions": {"1.3.0": {}, "1.3.1": {"deprecated": "compromised"}, "1.4.0": {}, "1.5.0": {}}}`))
+		case "/is-odd":
+			w.Write([]byte(`{"versions": {"3.0.0": {}, "3.0.1": {}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	results := []Result{
+		{LockFile: "a/package-lock.json", Packages: []Package{
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			{Name: "is-odd", Version: "3.0.1", IsAffected: true},
+		}},
+		{LockFile: "b/yarn.lock", Packages: []Package{
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			{Name: "is-even", Version: "1.0.0", IsWarning: true},
+		}},
+	}
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+		"is-odd":   {"3.0.1": true},
+		"is-even":  {"0.9.0": true},
+	}
+
+	steps, errs := planRemediation(results, affected, newRegistryClient(server.URL, ""))
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("Expected one step per compromised package version, got %+v", steps)
+	}
+	if steps[0].Target != "1.4.0" || len(steps[0].LockFiles) != 2 {
+		t.Errorf("Expected left-pad 1.3.0 -> 1.4.0 in both lockfiles, got %+v", steps[0])
+	}
+	if steps[1].Target != "" || steps[1].Note != noRemediation {
+		t.Errorf("Expected is-odd to have no remediation available, got %+v", steps[1])
+	}
+}
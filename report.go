@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// reportRetries is how many times a failed --report-uri upload is retried
+const reportRetries = 3
+
+// reportRetryDelay is the initial delay between --report-uri retries, doubled on each attempt
+var reportRetryDelay = time.Second
+
+// parseAuthHeader splits a "Name: value" header as given to --report-auth-header
+func parseAuthHeader(s string) (string, string, error) {
+	name, value, found := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return "", "", fmt.Errorf("invalid auth header %q, expected 'Name: value'", s)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// postReport POSTs the scan result as JSON to uri, retrying network errors and
+// 429 or 5xx responses. authHeader, if set, is a "Name: value" header sent with the request
+func postReport(client *http.Client, uri, authHeader string, result ScanResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	var headerName, headerValue string
+	if authHeader != "" {
+		if headerName, headerValue, err = parseAuthHeader(authHeader); err != nil {
+			return err
+		}
+	}
+
+	delay := reportRetryDelay
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "shai-hulud-scanner/"+Version)
+		if headerName != "" {
+			req.Header.Set(headerName, headerValue)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("report endpoint returned %s", resp.Status)
+			if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+				return err
+			}
+		}
+
+		if attempt == reportRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
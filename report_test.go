@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test that the scan result is posted as JSON with the auth header, retrying transient failures
+func TestPostReport(t *testing.T) {
+	defer func(delay time.Duration) { reportRetryDelay = delay }(reportRetryDelay)
+	reportRetryDelay = time.Millisecond
+
+	attempts := 0
+	var posted ScanResult
+	var auth, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &posted); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	result := ScanResult{
+		Root:        "/repo",
+		Results:     []Result{{LockFile: "yarn.lock", Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}}},
+		AnyAffected: true,
+		Summary:     Summary{TotalLockfiles: 1, TotalPackages: 1, TotalCompromised: 1},
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	if err := postReport(client, server.URL, "Authorization: Bearer secret", result); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("Expected a retry after the transient failure, got %d attempts", attempts)
+	}
+	if auth != "Bearer secret" || contentType != "application/json" {
+		t.Errorf("Expected auth and content type headers, got %q and %q", auth, contentType)
+	}
+	if posted.Root != "/repo" || posted.Summary.TotalCompromised != 1 || len(posted.Results) != 1 {
+		t.Errorf("Expected the full scan result to be posted, got %+v", posted)
+	}
+}
+
+// Test that client errors are not retried
+func TestPostReportClientError(t *testing.T) {
+	defer func(delay time.Duration) { reportRetryDelay = delay }(reportRetryDelay)
+	reportRetryDelay = time.Millisecond
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if err := postReport(&http.Client{}, server.URL, "", ScanResult{}); err == nil {
+		t.Error("Expected an error for a rejected report")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no retries for a client error, got %d attempts", attempts)
+	}
+	if _, _, err := parseAuthHeader("no-colon"); err == nil {
+		t.Error("Expected an error for a malformed auth header")
+	}
+}
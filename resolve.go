@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxResolvedPackages bounds how many package versions --resolve-transitive visits per manifest
+const maxResolvedPackages = 2000
+
+// lockfileNames are the lockfiles whose presence means a manifest needs no resolution
+var lockfileNames = []string{"yarn.lock", "package-lock.json", "npm-shrinkwrap.json", "pnpm-lock.yaml", "shrinkwrap.yaml", "bun.lock", "bun.lockb"}
+
+// findManifestsWithoutLockfile finds package.json files under rootDir with no lockfile in
+// their own directory or any ancestor up to rootDir, so workspace members are skipped
+func findManifestsWithoutLockfile(rootDir string) ([]string, error) {
+	var manifests []string
+	locked := make(map[string]bool)
+
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip inaccessible files
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" || d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			for _, name := range lockfileNames {
+				if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+					locked[path] = true
+					break
+				}
+			}
+			if locked[filepath.Dir(path)] && path != rootDir {
+				locked[path] = true
+			}
+			return nil
+		}
+		if d.Name() == "package.json" && !locked[filepath.Dir(path)] {
+			manifests = append(manifests, path)
+		}
+		return nil
+	})
+
+	return manifests, err
+}
+
+// registryDependency turns a manifest dependency into the registry package and range to
+// resolve, following npm: aliases; non-registry specs like git URLs and local paths are skipped
+func registryDependency(name, spec string) (string, string, bool) {
+	if target, found := strings.CutPrefix(spec, "npm:"); found {
+		name, spec = splitBunPackageKey(target)
+	}
+	if spec == "" || spec == "latest" {
+		return name, "*", true
+	}
+	if _, ok := parseRange(spec); !ok {
+		return "", "", false
+	}
+	return name, spec, true
+}
+
+// resolveTransitive resolves dependencies through the registry to the highest versions
+// matching their ranges, breadth-first, visiting at most limit package versions. It returns
+// every resolved version keyed by package name
+func resolveTransitive(deps map[string]string, client *registryClient, limit int) (map[string]map[string]bool, []error) {
+	type request struct{ name, spec string }
+
+	resolved := make(map[string]map[string]bool)
+	var errs []error
+	seen := make(map[string]bool)
+	queue := make([]request, 0, len(deps))
+
+	enqueue := func(deps map[string]string) {
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			queue = append(queue, request{name, deps[name]})
+		}
+	}
+	enqueue(deps)
+
+	visited := 0
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		name, spec, ok := registryDependency(next.name, next.spec)
+		if !ok || seen[name+"@"+spec] {
+			continue
+		}
+		seen[name+"@"+spec] = true
+
+		doc, err := client.fetch(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		versions := make([]string, 0, len(doc.Versions))
+		for version := range doc.Versions {
+			versions = append(versions, version)
+		}
+		version := maxSatisfying(versions, spec)
+		if version == "" || resolved[name][version] {
+			continue
+		}
+
+		if visited >= limit {
+			errs = append(errs, fmt.Errorf("stopped resolving after %d packages", limit))
+			break
+		}
+		visited++
+		if resolved[name] == nil {
+			resolved[name] = make(map[string]bool)
+		}
+		resolved[name][version] = true
+
+		meta := doc.Versions[version]
+		enqueue(meta.Dependencies)
+		enqueue(meta.OptionalDependencies)
+	}
+
+	return resolved, errs
+}
+
+// scanManifestTransitive resolves a manifest's dependency tree through the registry and
+// checks every resolved version, approximating what a fresh install would bring in
+func scanManifestTransitive(manifest string, affected map[string]map[string]bool, client *registryClient) ([]Package, []error) {
+	comparator := versionComparatorFor("npm")
+	content, err := os.ReadFile(manifest)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var pkg struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, []error{fmt.Errorf("parsing %s: %w", manifest, err)}
+	}
+
+	direct := make(map[string]string)
+	for _, deps := range []map[string]string{pkg.DevDependencies, pkg.OptionalDependencies, pkg.Dependencies} {
+		for name, spec := range deps {
+			direct[name] = spec
+		}
+	}
+
+	resolved, errs := resolveTransitive(direct, client, maxResolvedPackages)
+
+	names := make([]string, 0, len(resolved))
+	for name := range resolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var packages []Package
+	for _, name := range names {
+		versions := make([]string, 0, len(resolved[name]))
+		for version := range resolved[name] {
+			versions = append(versions, version)
+		}
+		sortAffectedVersions(versions)
+		for _, version := range versions {
+			if found, ok := checkPackage(comparator, name, version, affected); ok {
+				found.Notice = "resolved from the registry, no lockfile pins this version"
+				packages = append(packages, found)
+			}
+		}
+	}
+	return packages, errs
+}
+
+// scanTransitive resolves and checks every lockfile-less manifest under rootDir
+func scanTransitive(rootDir string, affected map[string]map[string]bool, client *registryClient) ([]Result, []error) {
+	manifests, err := findManifestsWithoutLockfile(rootDir)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var results []Result
+	var errs []error
+	for _, manifest := range manifests {
+		packages, manifestErrs := scanManifestTransitive(manifest, affected, client)
+		errs = append(errs, manifestErrs...)
+		if len(packages) > 0 {
+			results = append(results, Result{LockFile: manifest, Packages: packages})
+		}
+	}
+	return results, errs
+}
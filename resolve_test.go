@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a compromised transitive dependency is found by resolving through a mock registry
+func TestScanTransitive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/web-framework":
+			w.Write([]byte(`{"versions": {
+				"2.0.0": {"dependencies": {"@ctrl/tinycolor": "^4.1.0"}},
+				"2.1.0": {"dependencies": {"@ctrl/tinycolor": "^4.1.0", "left-pad": "~1.3.0"}},
+				"3.0.0": {}
+			}}`))
+		case "/@ctrl%2ftinycolor":
+			w.Write([]byte(`{"versions": {"4.1.0": {}, "4.1.1": {}, "5.0.0": {}}}`))
+		case "/left-pad":
+			w.Write([]byte(`{"versions": {"1.3.0": {"dependencies": {"web-framework": "^2.0.0"}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"app/package.json":           `{"dependencies": {"web-framework": "^2.0.0", "local-lib": "file:../lib"}}`,
+		"locked/package.json":        `{"dependencies": {"web-framework": "^2.0.0"}}`,
+		"locked/package-lock.json":   `{"lockfileVersion": 3}`,
+		"locked/member/package.json": `{"dependencies": {"web-framework": "^2.0.0"}}`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected := map[string]map[string]bool{"@ctrl/tinycolor": {"4.1.1": true}}
+	results, errs := scanTransitive(root, affected, newRegistryClient(server.URL, ""))
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results) != 1 || results[0].LockFile != filepath.Join(root, "app", "package.json") {
+		t.Fatalf("Expected findings only for the lockfile-less manifest, got %+v", results)
+	}
+	pkg := results[0].Packages[0]
+	if len(results[0].Packages) != 1 || pkg.Name != "@ctrl/tinycolor" || pkg.Version != "4.1.1" || !pkg.IsAffected {
+		t.Errorf("Expected the transitive @ctrl/tinycolor@4.1.1 to be flagged, got %+v", results[0].Packages)
+	}
+}
+
+// Test that resolution stops at the package limit
+func TestResolveTransitiveLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"versions": {"1.0.0": {"dependencies": {"a": "1", "b": "1", "c": "1"}}}}`))
+	}))
+	defer server.Close()
+
+	resolved, errs := resolveTransitive(map[string]string{"root": "^1.0.0"}, newRegistryClient(server.URL, ""), 2)
+	if len(resolved) != 2 || len(errs) != 1 {
+		t.Errorf("Expected resolution to stop after 2 packages with an error, got %v, %v", resolved, errs)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checkSafeList reports every package in the lockfiles whose version is not on the safe list
+// as suspicious, the inverse of matching against the exploited packages list
+func checkSafeList(lockfiles []string, safe map[string]map[string]bool) ([]Result, []error) {
+	var results []Result
+	var errs []error
+
+	for _, lockfile := range lockfiles {
+		found, err := enumerateLockfile(lockfile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading %s: %w", lockfile, err))
+			continue
+		}
+
+		names := make([]string, 0, len(found))
+		for name := range found {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var packages []Package
+		for _, name := range names {
+			for _, version := range sortedVersions(found[name]) {
+				if safe[name][normalizeVersion(version)] {
+					continue
+				}
+				packages = append(packages, Package{
+					Name:         name,
+					Version:      version,
+					IsSuspicious: true,
+					Notice:       fmt.Sprintf("%s@%s is not on the safe list", name, version),
+				})
+			}
+		}
+		if len(packages) > 0 {
+			results = append(results, Result{LockFile: lockfile, Packages: packages})
+		}
+	}
+
+	return results, errs
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseSafeRanges parses --safe-above values like 'pkg@>=2.0.0' into semver ranges per package
+func parseSafeRanges(values []string) (map[string][]string, error) {
+	ranges := make(map[string][]string)
+	for _, value := range values {
+		offset := 0
+		if strings.HasPrefix(value, "@") {
+			offset = 1
+		}
+		atIndex := strings.Index(value[offset:], "@")
+		if atIndex == -1 {
+			return nil, fmt.Errorf("invalid safe range '%s', expected 'package@range'", value)
+		}
+		atIndex += offset
+
+		name, rng := strings.TrimSpace(value[:atIndex]), strings.TrimSpace(value[atIndex+1:])
+		if !versionComparatorFor("npm").ValidRange(rng) || name == "" || rng == "" {
+			return nil, fmt.Errorf("invalid safe range '%s', expected 'package@range'", value)
+		}
+		ranges[name] = append(ranges[name], rng)
+	}
+	return ranges, nil
+}
+
+// filterResultsBySafeRanges drops warnings for installed versions inside a range declared
+// safe for their package and recomputes the result flags. Compromised matches are always kept
+func filterResultsBySafeRanges(results []Result, ranges map[string][]string) ([]Result, bool, bool) {
+	var filtered []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, result := range results {
+		comparator := versionComparatorFor(lockfileFormat(filepath.Base(result.LockFile), nil))
+		var packages []Package
+		for _, pkg := range result.Packages {
+			if pkg.IsWarning && !pkg.IsAffected && inSafeRange(comparator, pkg.Version, ranges[pkg.Name]) {
+				continue
+			}
+			packages = append(packages, pkg)
+			if pkg.IsAffected {
+				anyAffected = true
+			}
+			if pkg.IsWarning {
+				anyWarnings = true
+			}
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			result.Packages = packages
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered, anyAffected, anyWarnings
+}
+
+// inSafeRange reports whether version satisfies any of the ranges under comparator's semantics
+func inSafeRange(comparator VersionComparator, version string, ranges []string) bool {
+	for _, rng := range ranges {
+		if comparator.Satisfies(version, rng) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that packages missing from the safe list are flagged while approved ones are not
+func TestCheckSafeList(t *testing.T) {
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "package-lock.json")
+	content := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/left-pad": {"version": "1.3.0"},
+    "node_modules/is-odd": {"version": "3.0.1"}
+  }
+}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	safe, err := parseExploitedPackages(strings.NewReader("left-pad@1.3.0\nis-odd@3.0.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, errs := checkSafeList([]string{lockfile}, safe)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results) != 1 || len(results[0].Packages) != 1 {
+		t.Fatalf("Expected one unapproved package, got %+v", results)
+	}
+
+	pkg := results[0].Packages[0]
+	if pkg.Name != "is-odd" || pkg.Version != "3.0.1" || !pkg.IsSuspicious || pkg.IsAffected {
+		t.Errorf("Expected is-odd@3.0.1 to be flagged as unapproved, got %+v", pkg)
+	}
+}
+
+// Test that a warning above the configured safe floor is suppressed
+func TestFilterResultsBySafeRanges(t *testing.T) {
+	ranges, err := parseSafeRanges([]string{"left-pad@>=2.0.0", "@scoped/package@^3.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSafeRanges([]string{"left-pad"}); err == nil {
+		t.Error("Expected a value without a range to be rejected")
+	}
+
+	results := []Result{
+		{
+			LockFile: "package-lock.json",
+			Packages: []Package{
+				{Name: "left-pad", Version: "2.1.0", IsWarning: true},
+				{Name: "left-pad", Version: "1.4.0", IsWarning: true},
+				{Name: "@scoped/package", Version: "3.2.0", IsWarning: true},
+				{Name: "@scoped/package", Version: "3.1.5", IsAffected: true},
+			},
+		},
+	}
+
+	filtered, anyAffected, anyWarnings := filterResultsBySafeRanges(results, ranges)
+	if !anyAffected || !anyWarnings {
+		t.Errorf("Expected compromised and warning findings to remain, got affected=%v warnings=%v", anyAffected, anyWarnings)
+	}
+	if len(filtered) != 1 || len(filtered[0].Packages) != 2 {
+		t.Fatalf("Expected two findings to remain, got %+v", filtered)
+	}
+	if kept := filtered[0].Packages[0]; kept.Version != "1.4.0" {
+		t.Errorf("Expected left-pad@1.4.0 below the safe floor to remain, got %+v", kept)
+	}
+	if kept := filtered[0].Packages[1]; !kept.IsAffected {
+		t.Errorf("Expected a compromised version inside a safe range to remain, got %+v", kept)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SARIF rule ids for compromised and vulnerable-but-safe packages
+const (
+	sarifRuleCompromised = "shai-hulud/compromised-package"
+	sarifRuleVulnerable  = "shai-hulud/vulnerable-versions"
+)
+
+// sarifLog is the root of a SARIF 2.1.0 document
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifRun holds the results of a single scan
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+// sarifTool describes the scanner and the rules it reports
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+// sarifDriver is the scanner component of a SARIF tool
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// sarifRule describes a kind of finding
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+// sarifMessage is a plain-text SARIF message
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifResult is a single flagged package
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+// sarifLocation points a result at the lockfile it was found in
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// toSARIF converts a scan result into a SARIF 2.1.0 document; compromised packages
+// are errors and packages with vulnerable versions elsewhere are warnings
+func toSARIF(result ScanResult) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "shai-hulud-scanner",
+			Version:        Version,
+			InformationURI: "https://github.com/jpmckearin/shai-hulud-scanner",
+			Rules: []sarifRule{
+				{ID: sarifRuleCompromised, ShortDescription: sarifMessage{Text: "Compromised package version in lockfile"}},
+				{ID: sarifRuleVulnerable, ShortDescription: sarifMessage{Text: "Package has compromised versions, but the locked version is safe"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, res := range result.Results {
+		// Report lockfiles relative to the scanned root so code scanning can link them
+		uri := res.LockFile
+		if rel, err := filepath.Rel(result.Root, res.LockFile); err == nil && filepath.IsAbs(res.LockFile) {
+			uri = rel
+		}
+		var location sarifLocation
+		location.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(uri)
+
+		for _, pkg := range res.Packages {
+			var sarif sarifResult
+			switch {
+			case pkg.IsAffected:
+				sarif = sarifResult{
+					RuleID:  sarifRuleCompromised,
+					Level:   "error",
+					Message: sarifMessage{Text: fmt.Sprintf("Compromised package %s@%s", pkg.Name, pkg.Version)},
+				}
+			case pkg.IsWarning:
+				sarif = sarifResult{
+					RuleID:  sarifRuleVulnerable,
+					Level:   "warning",
+					Message: sarifMessage{Text: fmt.Sprintf("%s@%s is safe, but compromised versions of %s exist", pkg.Name, pkg.Version, pkg.Name)},
+				}
+			default:
+				continue
+			}
+			sarif.Locations = []sarifLocation{location}
+			run.Results = append(run.Results, sarif)
+		}
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+// writeSARIF writes the scan result as a SARIF document, for upload to GitHub code scanning
+func writeSARIF(path string, result ScanResult) error {
+	document, err := toSARIF(result)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, document, 0644)
+}
+
+// writeSARIFGzip writes the scan result as a gzip-compressed SARIF document
+func writeSARIFGzip(path string, result ScanResult) error {
+	document, err := toSARIF(result)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write(document); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
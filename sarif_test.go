@@ -0,0 +1,98 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that gzipped SARIF decompresses to a well-formed SARIF 2.1.0 document
+func TestWriteSARIFGzip(t *testing.T) {
+	root := t.TempDir()
+	result := ScanResult{
+		Root: root,
+		Results: []Result{{
+			LockFile: filepath.Join(root, "app", "yarn.lock"),
+			Packages: []Package{
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+				{Name: "is-odd", Version: "3.0.1", IsWarning: true},
+			},
+			Notices: []string{"unsupported lockfileVersion 9, this file may be incompletely scanned"},
+		}},
+	}
+
+	path := filepath.Join(root, "results.sarif.gz")
+	if err := writeSARIFGzip(path, result); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Expected gzip output: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.NewDecoder(reader).Decode(&log); err != nil {
+		t.Fatalf("Expected well-formed SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a single SARIF 2.1.0 run, got %+v", log)
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 SARIF results, got %d", len(results))
+	}
+	if results[0].RuleID != sarifRuleCompromised || results[0].Level != "error" {
+		t.Errorf("Expected compromised package as an error, got %+v", results[0])
+	}
+	if results[1].RuleID != sarifRuleVulnerable || results[1].Level != "warning" {
+		t.Errorf("Expected vulnerable package as a warning, got %+v", results[1])
+	}
+	if uri := results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "app/yarn.lock" {
+		t.Errorf("Expected lockfile relative to root, got %s", uri)
+	}
+}
+
+// Test that --sarif-path writes the uncompressed SARIF document
+func TestWriteSARIF(t *testing.T) {
+	root := t.TempDir()
+	result := ScanResult{
+		Root: root,
+		Results: []Result{{
+			LockFile: filepath.Join(root, "package-lock.json"),
+			Packages: []Package{{Name: "@ctrl/tinycolor", Version: "4.1.1", IsAffected: true}},
+		}},
+	}
+
+	path := filepath.Join(root, "results.sarif")
+	if err := writeSARIF(path, result); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(content, &log); err != nil {
+		t.Fatalf("Expected well-formed SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("Expected one result in a single SARIF 2.1.0 run, got %+v", log)
+	}
+	sarif := log.Runs[0].Results[0]
+	if sarif.RuleID != sarifRuleCompromised || sarif.Level != "error" || sarif.Message.Text != "Compromised package @ctrl/tinycolor@4.1.1" {
+		t.Errorf("Expected the compromised package as an error, got %+v", sarif)
+	}
+	if uri := sarif.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "package-lock.json" {
+		t.Errorf("Expected the lockfile as the location, got %s", uri)
+	}
+}
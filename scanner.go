@@ -2,16 +2,29 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed exploited_packages.txt
@@ -19,34 +32,84 @@ var embeddedExploitedPackages string
 
 // Package represents a parsed package from the exploited packages list
 type Package struct {
-	Name        string `json:"package"`
-	Version     string `json:"version"`
-	IsAffected  bool   `json:"isAffected"`
-	IsWarning   bool   `json:"isWarning"`
-	AffectedVersions []string `json:"affectedVersions,omitempty"`
+	Name        string `json:"package" yaml:"package"`
+	Version     string `json:"version" yaml:"version"`
+	IsAffected  bool   `json:"isAffected" yaml:"isAffected"`
+	IsWarning   bool   `json:"isWarning" yaml:"isWarning"`
+	AffectedVersions []string `json:"affectedVersions,omitempty" yaml:"affectedVersions,omitempty"`
+	ChecksumMismatch bool     `json:"checksumMismatch,omitempty" yaml:"checksumMismatch,omitempty"`
+	Deprecated       bool     `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	SuggestedVersion string   `json:"suggestedVersion,omitempty" yaml:"suggestedVersion,omitempty"`
+	WeeklyDownloads  int      `json:"weeklyDownloads,omitempty" yaml:"weeklyDownloads,omitempty"`
+	FirstPublished   string   `json:"firstPublished,omitempty" yaml:"firstPublished,omitempty"`
+	Confidence       string   `json:"confidence,omitempty" yaml:"confidence,omitempty"`
+	Workspace        string   `json:"workspace,omitempty" yaml:"workspace,omitempty"`
+	Notice           string   `json:"notice,omitempty" yaml:"notice,omitempty"`
+	IsSuspicious     bool     `json:"isSuspicious,omitempty" yaml:"isSuspicious,omitempty"`
+	Severity         string   `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Integrity        string   `json:"integrity,omitempty" yaml:"integrity,omitempty"`
+	Optional         bool     `json:"optional,omitempty" yaml:"optional,omitempty"`
+	Peer             bool     `json:"peer,omitempty" yaml:"peer,omitempty"`
+	Context          *LockfileContext `json:"context,omitempty" yaml:"context,omitempty"`
+	Alias            string   `json:"alias,omitempty" yaml:"alias,omitempty"` // installed name of an aliased install, Name is the real package
+	Advisory         string   `json:"advisory,omitempty" yaml:"advisory,omitempty"` // from a JSON list entry, e.g. a CVE id or advisory URL
+}
+
+// LockfileContext is the raw lockfile content around a finding, shown by --context-lines
+type LockfileContext struct {
+	Line      int      `json:"line" yaml:"line"`           // 1-based line of the matched entry
+	StartLine int      `json:"startLine" yaml:"startLine"` // 1-based line of Lines[0]
+	Lines     []string `json:"lines" yaml:"lines"`
 }
 
 // Result represents scan results for a single lockfile
 type Result struct {
-	LockFile string    `json:"lockFile"`
-	Packages []Package `json:"packages"`
+	LockFile   string    `json:"lockFile" yaml:"lockFile"`
+	Submodule  string    `json:"submodule,omitempty" yaml:"submodule,omitempty"`
+	Packages   []Package `json:"packages" yaml:"packages"`
+	Notices    []string  `json:"notices,omitempty" yaml:"notices,omitempty"`       // informational messages about the file, such as a partial scan
+	Incomplete bool      `json:"incomplete,omitempty" yaml:"incomplete,omitempty"` // only part of the file could be scanned, which counts as a warning
+	Omitted    int       `json:"omitted,omitempty" yaml:"omitted,omitempty"`       // findings dropped by --max-findings-per-lockfile
 }
 
 // ScanResult represents the complete scan output
 type ScanResult struct {
-	Root        string   `json:"root"`
-	Results     []Result `json:"results"`
-	AnyAffected bool     `json:"anyAffected"`
-	AnyWarnings bool     `json:"anyWarnings"`
-	Summary     Summary  `json:"summary"`
+	Root        string   `json:"root" yaml:"root"`
+	Results     []Result `json:"results" yaml:"results"`
+	AnyAffected bool     `json:"anyAffected" yaml:"anyAffected"`
+	AnyWarnings bool     `json:"anyWarnings" yaml:"anyWarnings"`
+	AnySuspicious bool   `json:"anySuspicious,omitempty" yaml:"anySuspicious,omitempty"`
+	Summary     Summary  `json:"summary" yaml:"summary"`
+	Omitted     int      `json:"omitted,omitempty" yaml:"omitted,omitempty"`
+	Collapsed   int      `json:"collapsed,omitempty" yaml:"collapsed,omitempty"`
+	Unchanged   int      `json:"unchanged,omitempty" yaml:"unchanged,omitempty"` // findings hidden by --report-only-changed
+	Sample      *Sample  `json:"sample,omitempty" yaml:"sample,omitempty"`
+}
+
+// Sample describes a scan restricted to a random subset of the discovered lockfiles
+type Sample struct {
+	Scanned    int   `json:"scanned" yaml:"scanned"`
+	Discovered int   `json:"discovered" yaml:"discovered"`
+	Seed       int64 `json:"seed" yaml:"seed"`
+}
+
+// SummaryReport is the compact summary-only JSON output
+type SummaryReport struct {
+	Root        string  `json:"root" yaml:"root"`
+	AnyAffected bool    `json:"anyAffected" yaml:"anyAffected"`
+	AnyWarnings bool    `json:"anyWarnings" yaml:"anyWarnings"`
+	Summary     Summary `json:"summary" yaml:"summary"`
 }
 
 // Summary contains scan statistics
 type Summary struct {
-	TotalLockfiles   int `json:"totalLockfiles"`
-	TotalPackages    int `json:"totalPackages"`
-	TotalWarnings    int `json:"totalWarnings"`
-	TotalCompromised int `json:"totalCompromised"`
+	TotalLockfiles   int `json:"totalLockfiles" yaml:"totalLockfiles"`
+	TotalPackages    int `json:"totalPackages" yaml:"totalPackages"`
+	TotalWarnings    int `json:"totalWarnings" yaml:"totalWarnings"`
+	TotalCompromised int `json:"totalCompromised" yaml:"totalCompromised"`
+	TotalSuspicious  int `json:"totalSuspicious" yaml:"totalSuspicious"`
+	// SuppressedWarnings counts the operational warnings --quiet-errors kept off stderr
+	SuppressedWarnings int `json:"suppressedWarnings,omitempty" yaml:"suppressedWarnings,omitempty"`
 }
 
 func main() {
@@ -54,20 +117,112 @@ func main() {
 
 	// Command line flags - clean and simple
 	var (
-		listPath    = flag.String("list-path", "", "Path to exploited packages list file (optional if embedded)")
-		rootDir     = flag.String("root-dir", ".", "Root directory to scan")
+		listPath    = flag.String("list-path", "", "Path or http(s) URL of the exploited packages list (optional if embedded)")
+		listURL     = flag.String("list-url", "", "URL of an exploited packages list to fetch (cached for offline fallback)")
+		listCacheDir = flag.String("list-cache-dir", "", "Directory for the --list-url cache (default: the user cache directory)")
+		listCache   = flag.String("list-cache", "", "File to store the fetched list in and reuse when fetching it fails (default: a file under --list-cache-dir)")
+		noListCache = flag.Bool("no-list-cache", false, "Do not cache the --list-url list on disk")
+		listInline  = flag.String("list-inline", "", "Exploited packages as newline- or comma-separated package@version entries (or set "+listInlineEnv+")")
+		rootDir     = flag.String("root-dir", ".", "Root directory to scan (comma-separated for several roots)")
 		managersStr = flag.String("managers", "yarn,npm,pnpm,bun", "Package managers to scan (comma-separated)")
+		reportManagersStr = flag.String("report-managers", "", "Only report findings for these package managers (comma-separated); all managers are still scanned")
+		extraLockfileStr = flag.String("extra-lockfile", "", "Additional lockfile names as 'glob=format' mappings (comma-separated), e.g. 'frontend.lock=yarn'")
+		baselinePath = flag.String("baseline", "", "Suppress findings recorded in this baseline file (JSON or digests)")
+		validateParsersDir = flag.String("validate-parsers", "", "Parse every lockfile found in this directory without matching any list and report per-file success and package counts, exiting 1 if any failed")
+		annotateOut = flag.String("annotate-out", "", "Write copies of yarn.lock and pnpm-lock.yaml files with a '# SHAI-HULUD: compromised' comment above each compromised entry to this directory (originals are never modified)")
+		writeBaselinePath = flag.String("write-baseline", "", "Write the current findings to this baseline file")
+		exportIOCsPath = flag.String("export-iocs", "", "Write the exploited packages list the scan matches against, after fallback and normalization, to this file")
+		exportIOCsFormat = flag.String("export-iocs-format", iocFormatText, "Format for --export-iocs: text (package@version lines) or json")
+		baselineFormat = flag.String("baseline-format", baselineFormatJSON, "Format for --write-baseline: json, or digests for one fingerprint per line")
+		safeListPath = flag.String("safe-list", "", "Path to an allowlist of approved package@version entries; anything not on it is reported as suspicious")
 		includeStr  = flag.String("include", "", "Include patterns (comma-separated)")
 		excludeStr  = flag.String("exclude", "**/node_modules/**,**/.pnpm-store/**,**/dist/**,**/build/**,**/tmp/**,**/.turbo/**", "Exclude patterns (comma-separated)")
 		onlyAffected = flag.Bool("only-affected", false, "Show only affected packages")
 		summary     = flag.Bool("summary", false, "Show only summary")
+		noSummary   = flag.Bool("no-summary", false, "Omit the summary and timing footer from human output")
 		quiet       = flag.Bool("quiet", false, "Suppress non-essential output")
-		noColor     = flag.Bool("no-color", false, "Disable colored output")
+		quietErrors = flag.Bool("quiet-errors", false, "Suppress non-fatal operational warnings on stderr, such as unreadable directories and failed lookups, and count them in the summary instead")
+		checkOnly   = flag.Bool("check", false, "Print nothing and report only through the exit code; a one-line summary goes to stderr on failure")
+		colorMode   = flag.String("color", "auto", "Color output: auto (only when stdout is a terminal), always, never")
+		noColorFlag = flag.Bool("no-color", false, "Disable colored output (alias for --color=never)")
+		repoRelative = flag.Bool("repo-relative", false, "Report lockfile paths relative to the enclosing git repository root")
+		collapsePaths = flag.Bool("collapse-paths", false, "Abbreviate middle segments of deep lockfile paths in human-readable output")
 		jsonFlag    = flag.Bool("json", false, "Output JSON")
+		yamlFlag    = flag.Bool("yaml", false, "Output YAML")
+		format      = flag.String("format", "text", "Output format: text, json, junit, yaml")
+		yamlPath    = flag.String("yaml-path", "", "Write YAML to file")
+		templateText = flag.String("template", "", "Render output with a Go text/template; the scan result is the context")
+		templateFile = flag.String("template-file", "", "Render output with the Go text/template in this file")
 		jsonPath    = flag.String("json-path", "", "Write JSON to file")
+		jsonIndentStr = flag.String("json-indent", "2", "JSON indentation: number of spaces or 'tab'")
+		jsonZstdPath = flag.String("json-zstd", "", "Write zstd-compressed JSON to file (e.g. results.json.zst)")
+		reportURI   = flag.String("report-uri", "", "POST the full JSON scan result to this URL after scanning")
+		reportAuthHeader = flag.String("report-auth-header", "", "Header sent with --report-uri as 'Name: value', e.g. 'Authorization: Bearer TOKEN'")
+		reportTimeout = flag.Duration("report-timeout", 30*time.Second, "Timeout for each --report-uri request")
+		sarifPath   = flag.String("sarif-path", "", "Write SARIF 2.1.0 for GitHub code scanning to file (e.g. results.sarif)")
+		sarifGzipPath = flag.String("sarif-gzip", "", "Write gzip-compressed SARIF to file (e.g. results.sarif.gz)")
+		coveragePath = flag.String("coverage-path", "", "Write a JSON list of every discovered lockfile and manifest, whether it was scanned, and why not")
+		summaryJSONPath = flag.String("summary-json-path", "", "Write only the summary and result flags as JSON to file")
+		jobsPath    = flag.String("jobs", "", "Run the scan jobs in this YAML file, each with its own root, managers, patterns and list, and report them combined")
+		jobsConcurrency = flag.Int("jobs-concurrency", 1, "Number of --jobs entries scanned at once")
+		orgReport    = flag.Bool("org-report", false, "Aggregate per-repo JSON reports given as arguments and report the change since the last --history snapshot: --org-report reports/*.json")
+		historyPath  = flag.String("history", "", "History file --org-report compares against and appends a dated snapshot to")
+		mergeReports = flag.Bool("merge", false, "Merge JSON reports given as arguments into one: --merge a.json b.json ...")
+		compareLockfiles = flag.Bool("compare-lockfiles", false, "Compare two lockfiles given as arguments: --compare-lockfiles old new")
+		scanTarballsFlag = flag.Bool("scan-tarballs", false, "Also sweep the filesystem for *.tgz package tarballs")
+		scanYarnPluginsFlag = flag.Bool("scan-yarn-plugins", false, "Check Yarn plugins declared in .yarnrc.yml files and flag plugins fetched from unofficial URLs")
+		checkPostureFlag   = flag.Bool("check-posture", false, "Report .npmrc settings that disable TLS verification and npm lockfiles mostly lacking integrity hashes as informational notices")
+		checkAutomergeFlag = flag.Bool("check-automerge", false, "Report Renovate/Dependabot auto-merge settings as informational notices")
+		scanInstalledFlag = flag.Bool("scan-installed", false, "Also read the package.json of each package installed in pnpm virtual stores (node_modules/.pnpm)")
+		verifyInstalledFlag = flag.Bool("verify-installed", false, "Compare installed node_modules files against the tarballs pinned by npm lockfile integrity and flag drift (downloads tarballs, slow)")
+		resolveTransitiveFlag = flag.Bool("resolve-transitive", false, "Resolve the dependency trees of package.json files without a lockfile through the registry and check them (network, slow)")
+		scanManifestsFlag = flag.Bool("scan-manifests", false, "Also warn about package.json files without a lockfile whose declared dependency ranges could install a compromised version")
+		scanGlobalFlag = flag.Bool("scan-global", false, "Also scan global npm, pnpm and yarn install directories")
+		scanCache   = flag.Bool("scan-cache", false, "Also scan .yarn/cache archives and the pnpm store")
+		enrichRegistry = flag.Bool("enrich-registry", false, "Annotate compromised packages with registry deprecation info, a suggested version, weekly downloads and first-publish date")
+		checkUpdate = flag.Bool("check-update", false, "Check GitHub for a newer scanner release and print an upgrade notice to stderr before scanning (never installs)")
+		bunBin      = flag.String("bun-bin", "", "bun executable used to export binary bun.lockb files for scanning, e.g. 'bun'; it is run on a copy of each bun.lockb in an empty temporary directory (default: check only the registry tarballs bun.lockb records)")
+		includeRoot = flag.Bool("include-root", false, "Also check an npm lockfile's root package, named by the sibling package.json, e.g. when auditing a published package's own lockfile")
+		flagNewerThanCompromise = flag.Bool("flag-newer-than-compromise", false, "Flag installed versions of listed packages published after their first compromised version as suspicious (uses registry publish dates)")
+		flagUnpopularBelow = flag.Int("flag-unpopular-below", 0, "Flag listed packages with fewer than this many weekly npm downloads as suspicious (0 disables)")
+		verifyChecksumsFlag = flag.Bool("verify-checksums", false, "Verify lockfile integrity of flagged packages against the registry (Yarn Berry checksums against .yarn/cache)")
+		planRemediationFlag = flag.Bool("plan-remediation", false, "Print the nearest safe upgrade for each compromised package from the registry's versions instead of the scan report")
+		registryURL = flag.String("registry-url", defaultRegistryURL, "npm registry used by --enrich-registry, --flag-unpopular-below, --verify-checksums, --plan-remediation and --resolve-transitive")
+		exitCodeAffected = flag.Int("exit-code-affected", 2, "Exit code when compromised packages are found (0-125)")
+		failOnSuspicious = flag.Bool("fail-on-suspicious", false, "Also use the affected exit code when only suspicious findings (tampered metadata, typosquats, ...) are found")
+		failThreshold = flag.Int("fail-threshold", 0, "Only use the affected exit code when more than this many compromised packages are found")
+		exitCodeWarning = flag.Int("exit-code-warning", 0, "Exit code when only warnings are found (0-125)")
+		minConfidence = flag.String("min-confidence", "", "Only report findings at or above this confidence: low, medium, high")
+		flagPrereleases = flag.Bool("flag-prereleases-of", false, "Flag prerelease versions (-rc, -alpha, -canary, ...) of packages with compromised stable versions as suspicious")
+		scopeFilter = flag.String("scope", "", "Only report findings in this npm scope, e.g. @babel")
+		ignorePeer  = flag.Bool("ignore-peer", false, "Ignore findings for npm lockfile entries flagged as peer dependencies, which are often not installed")
+		ignoreOptional = flag.Bool("ignore-optional", false, "Ignore findings for npm lockfile entries flagged as optional dependencies")
+		scopedOnly  = flag.Bool("scoped-only", false, "Only report findings for scoped (@scope/name) packages")
+		unscopedOnly = flag.Bool("unscoped-only", false, "Only report findings for unscoped packages")
+		sinceCommit = flag.String("since-commit", "", "Scan only lockfiles that changed, or whose package.json changed, since this git ref; scans everything outside a git repository")
+		sampleSize  = flag.Int("sample", 0, "Scan only a random sample of this many discovered lockfiles (0 scans all)")
+		sampleSeed  = flag.Int64("sample-seed", 0, "Seed for --sample so the same lockfiles are picked again (default: random)")
+		contextLines = flag.Int("context-lines", 0, "Show this many lines of raw lockfile content around each finding in yarn.lock and pnpm-lock.yaml files")
+		groupBy     = flag.String("group-by", "", "Group human-readable findings: severity (compromised, warnings, then suspicious); default groups by finding kind in lockfile order")
+		sortBy      = flag.String("sort", "", "Order findings: severity (most severe first); default keeps lockfile order")
+		latestOnly  = flag.Bool("latest-only", false, "Show only the newest compromised and the newest warning version of each package; summary counts are unaffected")
+		maxPerLockfile = flag.Int("max-findings-per-lockfile", 0, "Show at most this many findings per lockfile, most urgent first (0 for no cap); summary counts are unaffected")
+		limit       = flag.Int("limit", 0, "Show at most this many findings, highest priority first (0 for no limit); summary counts are unaffected")
+		minimalMemory = flag.Bool("minimal-memory", false, "Stream findings as each lockfile completes instead of holding all results in memory (text and json formats only)")
+		reportOnlyChanged = flag.Bool("report-only-changed", false, "Show findings only for lockfiles whose content changed since the last run with --verdict-cache; the summary and exit code still cover every lockfile")
+		verdictCachePath = flag.String("verdict-cache", "", "Remember per-package verdicts by integrity hash in this file to speed up repeated scans; reset when the list changes")
+		traceName   = flag.String("trace", "", "Explain to stderr every version of this package found in the lockfiles and why it did or did not match")
+		statsFlag   = flag.Bool("stats", false, "Print internal scan counters and phase timings to stderr")
+		statusFd    = flag.Int("status-fd", -1, "Write a one-line machine-readable status summary to this file descriptor")
+		watch       = flag.Bool("watch", false, "Keep running and re-scan when lockfiles or the --list-url list change")
+		watchInterval = flag.Duration("watch-interval", 2*time.Second, "How often --watch checks lockfiles for changes")
+		listRefresh = flag.Duration("list-refresh", 15*time.Minute, "How often --watch re-fetches the --list-url list")
 		version     = flag.Bool("version", false, "Show version information")
 	)
 
+	var safeAbove stringList
+	flag.Var(&safeAbove, "safe-above", "Suppress warnings for installed versions in a range known to be safe, as 'package@range' (repeatable), e.g. 'left-pad@>=2.0.0'")
+
 	flag.Parse()
 
 	// Handle version flag
@@ -77,6 +232,25 @@ func main() {
 		fmt.Printf("Build Time: %s\n", BuildTime)
 		os.Exit(0)
 	}
+	warnings := &warner{quiet: *quietErrors}
+	opts := scanOptions{includeRoot: *includeRoot, warn: warnings}
+	if *bunBin != "" {
+		if path, err := exec.LookPath(*bunBin); err != nil {
+			warnings.warnf("--bun-bin: %v, checking only the registry tarballs bun.lockb files record", err)
+		} else {
+			opts.bunBinary = path
+		}
+	}
+
+	// An old binary also carries an old embedded list, so point at the newer release
+	if *checkUpdate {
+		notice, err := checkForUpdate(&http.Client{Timeout: listFetchTimeout}, latestReleaseURL, Version)
+		if err != nil {
+			warnings.warnf("update check failed: %v", err)
+		} else if notice != "" {
+			fmt.Fprintf(os.Stderr, "Note: %s\n", notice)
+		}
+	}
 
 	// Validate required parameters
 	if *listPath == "" && embeddedExploitedPackages == "" {
@@ -85,6 +259,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	// A URL list path is fetched like --list-url
+	if isListURL(*listPath) {
+		if *listURL != "" {
+			fmt.Fprintf(os.Stderr, "Error: --list-path is a URL, it cannot be combined with --list-url\n")
+			os.Exit(1)
+		}
+		*listURL, *listPath = *listPath, ""
+	}
+	if *listCache != "" && *noListCache {
+		fmt.Fprintf(os.Stderr, "Error: --list-cache and --no-list-cache cannot be used together\n")
+		os.Exit(1)
+	}
+
 	if *listPath != "" {
 		if _, err := os.Stat(*listPath); os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "Error: list file not found: %s\n", *listPath)
@@ -92,8 +279,208 @@ func main() {
 		}
 	}
 
-	if _, err := os.Stat(*rootDir); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: root directory not found: %s\n", *rootDir)
+	roots := parseCommaSeparated(*rootDir)
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	for _, root := range roots {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: root directory not found: %s\n", root)
+			os.Exit(1)
+		}
+	}
+
+	// Validate exit codes
+	for name, code := range map[string]int{"exit-code-affected": *exitCodeAffected, "exit-code-warning": *exitCodeWarning} {
+		if code < 0 || code > 125 {
+			fmt.Fprintf(os.Stderr, "Error: --%s must be between 0 and 125, got %d\n", name, code)
+			os.Exit(1)
+		}
+	}
+
+	if *failThreshold < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --fail-threshold must not be negative, got %d\n", *failThreshold)
+		os.Exit(1)
+	}
+
+	// Parse JSON indentation
+	jsonIndent, err := parseJSONIndent(*jsonIndentStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate output format
+	switch *format {
+	case "text", "json", "junit", "yaml":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid format '%s'. Valid options: text, json, junit, yaml\n", *format)
+		os.Exit(1)
+	}
+	if *noColorFlag {
+		*colorMode = "never"
+	}
+	switch *colorMode {
+	case "auto", "always", "never":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid color '%s'. Valid options: auto, always, never\n", *colorMode)
+		os.Exit(1)
+	}
+	noColor := !colorEnabled(*colorMode, os.Stdout)
+	if *jsonFlag {
+		*format = "json"
+	}
+	if *yamlFlag {
+		*format = "yaml"
+	}
+	outputTemplate, err := loadOutputTemplate(*templateText, *templateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading template: %v\n", err)
+		os.Exit(1)
+	}
+	if outputTemplate != "" && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --template cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	machineOutput := *format != "text" || outputTemplate != ""
+	if *minimalMemory && (*format == "junit" || *format == "yaml" || *yamlPath != "") {
+		fmt.Fprintf(os.Stderr, "Error: --minimal-memory supports only the text and json formats\n")
+		os.Exit(1)
+	}
+	if *groupBy != "" && *groupBy != "severity" {
+		fmt.Fprintf(os.Stderr, "Error: invalid group-by '%s'. Valid options: severity\n", *groupBy)
+		os.Exit(1)
+	}
+	if *sortBy != "" && *sortBy != "severity" {
+		fmt.Fprintf(os.Stderr, "Error: invalid sort '%s'. Valid options: severity\n", *sortBy)
+		os.Exit(1)
+	}
+	if *summary && *noSummary {
+		fmt.Fprintf(os.Stderr, "Error: --summary and --no-summary cannot be used together\n")
+		os.Exit(1)
+	}
+	if *exportIOCsFormat != iocFormatText && *exportIOCsFormat != iocFormatJSON {
+		fmt.Fprintf(os.Stderr, "Error: invalid export-iocs format '%s'. Valid options: text, json\n", *exportIOCsFormat)
+		os.Exit(1)
+	}
+	if *baselineFormat != baselineFormatJSON && *baselineFormat != baselineFormatDigests {
+		fmt.Fprintf(os.Stderr, "Error: invalid baseline format '%s'. Valid options: json, digests\n", *baselineFormat)
+		os.Exit(1)
+	}
+	if *coveragePath != "" && (*minimalMemory || *watch) {
+		fmt.Fprintf(os.Stderr, "Error: --coverage-path cannot be combined with --minimal-memory or --watch\n")
+		os.Exit(1)
+	}
+	if *annotateOut != "" && (*minimalMemory || *watch) {
+		fmt.Fprintf(os.Stderr, "Error: --annotate-out cannot be combined with --minimal-memory or --watch\n")
+		os.Exit(1)
+	}
+	if *writeBaselinePath != "" && (*minimalMemory || *watch) {
+		fmt.Fprintf(os.Stderr, "Error: --write-baseline cannot be combined with --minimal-memory or --watch\n")
+		os.Exit(1)
+	}
+	if *flagUnpopularBelow < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --flag-unpopular-below must not be negative, got %d\n", *flagUnpopularBelow)
+		os.Exit(1)
+	}
+	if *sampleSize < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --sample must not be negative, got %d\n", *sampleSize)
+		os.Exit(1)
+	}
+	if *limit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --limit must not be negative, got %d\n", *limit)
+		os.Exit(1)
+	}
+	if *maxPerLockfile < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --max-findings-per-lockfile must not be negative, got %d\n", *maxPerLockfile)
+		os.Exit(1)
+	}
+	if *contextLines < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --context-lines must not be negative, got %d\n", *contextLines)
+		os.Exit(1)
+	}
+	opts.contextLines = *contextLines
+	if *historyPath != "" && !*orgReport {
+		fmt.Fprintf(os.Stderr, "Error: --history requires --org-report\n")
+		os.Exit(1)
+	}
+	if *checkOnly && (*watch || *minimalMemory || *mergeReports || *orgReport || *jobsPath != "" || *compareLockfiles) {
+		fmt.Fprintf(os.Stderr, "Error: --check cannot be combined with --watch, --minimal-memory, --merge, --org-report, --jobs or --compare-lockfiles\n")
+		os.Exit(1)
+	}
+	// Jobs are reported as scanned, so flags that filter, reorder or enrich findings would be ignored
+	if *jobsPath != "" {
+		for _, conflict := range []struct {
+			set  bool
+			name string
+		}{
+			{*baselinePath != "", "--baseline"},
+			{*reportManagersStr != "", "--report-managers"},
+			{*minConfidence != "", "--min-confidence"},
+			{*scopeFilter != "" || *scopedOnly || *unscopedOnly, "--scope, --scoped-only and --unscoped-only"},
+			{*ignorePeer || *ignoreOptional, "--ignore-peer and --ignore-optional"},
+			{len(safeAbove) > 0, "--safe-above"},
+			{*flagPrereleases, "--flag-prereleases-of"},
+			{*enrichRegistry || *flagUnpopularBelow > 0 || *flagNewerThanCompromise || *verifyChecksumsFlag,
+				"--enrich-registry, --flag-unpopular-below, --flag-newer-than-compromise and --verify-checksums"},
+			{*sortBy != "", "--sort"},
+			{*latestOnly, "--latest-only"},
+			{*limit > 0 || *maxPerLockfile > 0, "--limit and --max-findings-per-lockfile"},
+		} {
+			if conflict.set {
+				fmt.Fprintf(os.Stderr, "Error: %s cannot be combined with --jobs, whose findings are reported as scanned\n", conflict.name)
+				os.Exit(1)
+			}
+		}
+	}
+	if *watch && (*minimalMemory || *format == "junit" || *format == "yaml") {
+		fmt.Fprintf(os.Stderr, "Error: --watch supports only the text and json formats and cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	if *reportOnlyChanged && *verdictCachePath == "" {
+		fmt.Fprintf(os.Stderr, "Error: --report-only-changed requires --verdict-cache to remember lockfiles between runs\n")
+		os.Exit(1)
+	}
+	if *reportOnlyChanged && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --report-only-changed cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	if *verdictCachePath != "" && *watch {
+		fmt.Fprintf(os.Stderr, "Error: --verdict-cache cannot be combined with --watch\n")
+		os.Exit(1)
+	}
+	if *reportAuthHeader != "" {
+		if _, _, err := parseAuthHeader(*reportAuthHeader); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *reportURI != "" && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --report-uri cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	if (*sarifPath != "" || *sarifGzipPath != "") && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --sarif-path and --sarif-gzip cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	if *jsonZstdPath != "" && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --json-zstd cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	if *repoRelative && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --repo-relative cannot be combined with --minimal-memory\n")
+		os.Exit(1)
+	}
+	if *latestOnly && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --latest-only cannot be combined with --minimal-memory, findings must be collected before they are collapsed\n")
+		os.Exit(1)
+	}
+	if *limit > 0 && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --limit cannot be combined with --minimal-memory, findings must be sorted before they are shown\n")
+		os.Exit(1)
+	}
+	if *maxPerLockfile > 0 && *minimalMemory {
+		fmt.Fprintf(os.Stderr, "Error: --max-findings-per-lockfile cannot be combined with --minimal-memory, findings must be sorted before they are shown\n")
 		os.Exit(1)
 	}
 
@@ -119,6 +506,56 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	// Manifests are discovered and scanned alongside the lockfiles
+	if *scanManifestsFlag {
+		managers = append(managers, manifestFormat)
+	}
+
+	// Parse and validate report managers
+	reportManagers := parseCommaSeparated(*reportManagersStr)
+	for _, manager := range reportManagers {
+		valid := false
+		for _, vm := range validManagers {
+			if manager == vm {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			fmt.Fprintf(os.Stderr, "Error: invalid report manager '%s'. Valid options: %s\n", manager, strings.Join(validManagers, ", "))
+			os.Exit(1)
+		}
+	}
+
+	// Validate minimum confidence
+	if *minConfidence != "" && confidenceRank[*minConfidence] == 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid confidence '%s'. Valid options: low, medium, high\n", *minConfidence)
+		os.Exit(1)
+	}
+
+	// Validate scope filters
+	if *scopedOnly && *unscopedOnly {
+		fmt.Fprintf(os.Stderr, "Error: --scoped-only and --unscoped-only cannot be used together\n")
+		os.Exit(1)
+	}
+	if *scopeFilter != "" && *unscopedOnly {
+		fmt.Fprintf(os.Stderr, "Error: --scope and --unscoped-only cannot be used together\n")
+		os.Exit(1)
+	}
+
+	// Parse ranges of versions known to be safe
+	safeRanges, err := parseSafeRanges(safeAbove)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Parse additional lockfile mappings
+	extraLockfiles, err := parseLockfileMappings(*extraLockfileStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Parse include/exclude patterns
 	var include, exclude []string
@@ -129,717 +566,3363 @@ func main() {
 		exclude = parseCommaSeparated(*excludeStr)
 	}
 
+	// Check that the parsers understand a directory's lockfiles, without any list
+	if *validateParsersDir != "" {
+		lockfiles, err := findLockfiles(*validateParsersDir, managers, include, exclude, extraLockfiles, warnings, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding lockfiles: %v\n", err)
+			os.Exit(1)
+		}
+		checks := validateParsers(lockfiles, extraLockfiles, opts)
+		if machineOutput {
+			checksOutput, err := json.MarshalIndent(checks, "", jsonIndent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(checksOutput))
+		} else {
+			printParserChecks(checks, noColor)
+		}
+		for _, check := range checks {
+			if check.Error != "" {
+				os.Exit(1)
+			}
+		}
+		os.Exit(0)
+	}
+
+	// Track exposure across the organization's repositories over time
+	if *orgReport {
+		if flag.NArg() == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --org-report requires at least one JSON report path\n")
+			os.Exit(1)
+		}
+
+		var reports []ScanResult
+		for _, path := range flag.Args() {
+			report, err := loadScanResult(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading report %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			reports = append(reports, report)
+		}
+		snapshot := buildOrgSnapshot(reports, time.Now())
+
+		var history OrgHistory
+		if *historyPath != "" {
+			history, err = loadOrgHistory(*historyPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading history %s: %v\n", *historyPath, err)
+				os.Exit(1)
+			}
+		}
+		report := buildOrgReport(history, snapshot)
+		if *historyPath != "" {
+			if err := appendOrgSnapshot(*historyPath, history, snapshot, jsonIndent); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing history %s: %v\n", *historyPath, err)
+				os.Exit(1)
+			}
+		}
+
+		if machineOutput {
+			reportOutput, err := json.MarshalIndent(report, "", jsonIndent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(reportOutput))
+		} else {
+			printOrgReport(report, noColor)
+		}
+
+		os.Exit(determineExitCode(snapshot.Summary.TotalCompromised > *failThreshold, snapshot.Summary.TotalWarnings > 0, false, *exitCodeAffected, *exitCodeWarning))
+	}
+
+	// Merge partial JSON reports from parallel scans
+	if *mergeReports {
+		if flag.NArg() == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --merge requires at least one JSON report path\n")
+			os.Exit(1)
+		}
+
+		var reports []ScanResult
+		for _, path := range flag.Args() {
+			report, err := loadScanResult(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading report %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			reports = append(reports, report)
+		}
+		merged := mergeResults(reports)
+
+		if machineOutput {
+			mergedOutput, err := json.MarshalIndent(merged, "", jsonIndent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(mergedOutput))
+		} else {
+			printResults(merged, *groupBy, *summary, *noSummary, *quiet, *onlyAffected, noColor, startTime)
+		}
+		if err := writeJSONReports(merged, *jsonPath, *summaryJSONPath, jsonIndent); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON file: %v\n", err)
+			os.Exit(1)
+		}
+
+		os.Exit(scanExitCode(merged, *failThreshold, *exitCodeAffected, *exitCodeWarning, *failOnSuspicious))
+	}
+
 	// Load exploited packages
-	affected, err := loadExploitedPackages(*listPath)
+	listSource := *listPath
+	inlineList := resolveInlineList(*listInline, *listPath != "" || *listURL != "")
+	var affected map[string]map[string]bool
+	var severities map[string]string
+	var listContent []byte
+	listClient := &http.Client{Timeout: listFetchTimeout}
+	cacheDir := resolveListCacheDir(*listCacheDir, *noListCache)
+	fetchListFrom := func(url string) ([]byte, error) {
+		if *listCache != "" {
+			return fetchExploitedListCached(listClient, url, *listCache)
+		}
+		return fetchExploitedList(listClient, url, cacheDir)
+	}
+	if inlineList != "" {
+		listSource = "inline"
+		listContent = []byte(strings.ReplaceAll(inlineList, ",", "\n"))
+	} else if *listURL != "" {
+		listSource = *listURL
+		listContent, err = fetchListFrom(*listURL)
+	} else {
+		listContent, err = readExploitedListFile(*listPath)
+	}
+	if err == nil {
+		affected, severities, err = parseExploitedList(bytes.NewReader(listContent))
+	}
 	if err != nil {
+		listSource = "embedded"
 		// If external file fails to load, try embedded file as fallback
-		if *listPath != "" {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to load external packages file '%s': %v\n", *listPath, err)
+		if *listURL != "" && inlineList == "" {
+			warnings.warnf("Failed to fetch packages list '%s': %v", *listURL, err)
+			fmt.Fprintf(os.Stderr, "Falling back to embedded package list\n")
+		} else if *listPath != "" && inlineList == "" {
+			warnings.warnf("Failed to load external packages file '%s': %v", *listPath, err)
 			fmt.Fprintf(os.Stderr, "Falling back to embedded package list\n")
 		}
-		affected, err = loadEmbeddedExploitedPackages()
+		listContent = []byte(embeddedExploitedPackages)
+		affected, severities, err = parseExploitedList(bytes.NewReader(listContent))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading embedded packages: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
+	opts.knownBad, _ = parseKnownBadIntegrities(bytes.NewReader(listContent))
+	opts.advisories = parseListAdvisories(listContent)
+
+	// Load the approved packages allowlist
+	var safeList map[string]map[string]bool
+	if *safeListPath != "" {
+		safeList, err = loadExploitedPackages(*safeListPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading safe list: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if len(affected) == 0 {
-		source := *listPath
-		if source == "" {
+		source := listSource
+		if source == "embedded" || source == "" {
 			source = "embedded package list"
 		}
 		fmt.Fprintf(os.Stderr, "Error: no valid package@version entries found in %s\n", source)
 		os.Exit(1)
 	}
 
-	// Find lockfiles
-	lockfiles, err := findLockfiles(*rootDir, managers, include, exclude)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error finding lockfiles: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(lockfiles) == 0 {
-		if !*jsonFlag {
-			fmt.Printf("No lockfiles found under: %s\n", *rootDir)
+	// Record exactly what this scan matches against, so its inputs can be audited and replayed
+	if *exportIOCsPath != "" {
+		if err := exportIOCs(*exportIOCsPath, *exportIOCsFormat, jsonIndent, affected, severities); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting IOCs: %v\n", err)
+			os.Exit(1)
 		}
-		os.Exit(0)
 	}
 
-	// Scan lockfiles
-	results, anyAffected, anyWarnings := scanLockfiles(lockfiles, affected)
+	// Compare two lockfiles directly
+	if *compareLockfiles {
+		if flag.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "Error: --compare-lockfiles requires exactly two lockfile paths\n")
+			os.Exit(1)
+		}
 
-	// Build summary
-	totalPackages := 0
-	totalCompromised := 0
-	totalWarnings := 0
+		diff, err := diffLockfiles(flag.Arg(0), flag.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing lockfiles: %v\n", err)
+			os.Exit(1)
+		}
+		diff.markAffected(affected)
 
-	for _, result := range results {
-		totalPackages += len(result.Packages)
-		for _, pkg := range result.Packages {
-			if pkg.IsAffected {
-				totalCompromised++
-			}
-			if pkg.IsWarning {
-				totalWarnings++
+		if machineOutput {
+			diffOutput, err := json.MarshalIndent(diff, "", jsonIndent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+				os.Exit(1)
 			}
+			fmt.Println(string(diffOutput))
+		} else {
+			printLockfileDiff(diff, noColor)
 		}
-	}
 
-	// Create output
-	rootAbs, _ := filepath.Abs(*rootDir)
-	scanResult := ScanResult{
-		Root:        rootAbs,
-		Results:     results,
-		AnyAffected: anyAffected,
-		AnyWarnings: anyWarnings,
-		Summary: Summary{
-			TotalLockfiles:   len(lockfiles),
-			TotalPackages:    totalPackages,
-			TotalWarnings:    totalWarnings,
-			TotalCompromised: totalCompromised,
-		},
+		os.Exit(determineExitCode(diff.AnyAffected, false, false, *exitCodeAffected, *exitCodeWarning))
 	}
 
-	// JSON output
-	jsonOutput, err := json.MarshalIndent(scanResult, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
-		os.Exit(1)
-	}
+	// Scan several projects, each with its own settings
+	if *jobsPath != "" {
+		jobs, err := loadJobs(*jobsPath, Job{Managers: managers, Include: include, Exclude: exclude})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading jobs: %v\n", err)
+			os.Exit(1)
+		}
 
-	if *jsonFlag {
-		fmt.Println(string(jsonOutput))
-	}
+		combined, jobErrs := runJobs(jobs, *jobsConcurrency, affected, severities, opts, func(job Job) ([]byte, error) {
+			if job.ListURL != "" {
+				return fetchExploitedList(listClient, job.ListURL, cacheDir)
+			}
+			return readExploitedListFile(job.ListPath)
+		})
+		for _, err := range jobErrs {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 
-	if *jsonPath != "" {
-		if err := os.WriteFile(*jsonPath, jsonOutput, 0644); err != nil {
+		if machineOutput {
+			jobsOutput, err := json.MarshalIndent(combined, "", jsonIndent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jobsOutput))
+		} else {
+			printResults(combined, *groupBy, *summary, *noSummary, *quiet, *onlyAffected, noColor, startTime)
+		}
+		if err := writeJSONReports(combined, *jsonPath, *summaryJSONPath, jsonIndent); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing JSON file: %v\n", err)
 			os.Exit(1)
 		}
-	}
 
-	// Human-readable output
-	if !*jsonFlag {
-		printResults(scanResult, *summary, *quiet, *onlyAffected, *noColor, startTime)
+		if len(jobErrs) > 0 && !combined.AnyAffected {
+			os.Exit(1)
+		}
+		os.Exit(scanExitCode(combined, *failThreshold, *exitCodeAffected, *exitCodeWarning, *failOnSuspicious))
 	}
 
-	// Exit code based on findings
-	if anyAffected {
-		os.Exit(2)
+	var stats *scanStats
+	if *statsFlag {
+		stats = &scanStats{}
 	}
-	os.Exit(0)
-}
 
-// parseCommaSeparated parses a comma-separated string into a slice
-func parseCommaSeparated(s string) []string {
-	if s == "" {
-		return []string{}
+	// Reuse verdicts for artifacts already checked against this list
+	if *verdictCachePath != "" {
+		opts.verdicts = loadVerdictCache(*verdictCachePath, hashExploitedList(listContent))
 	}
-	parts := strings.Split(s, ",")
-	result := make([]string, 0, len(parts))
-	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			result = append(result, trimmed)
+	if *traceName != "" {
+		opts.trace = newPackageTracer(*traceName, os.Stderr)
+	}
+	saveVerdicts := func() {
+		if err := opts.verdicts.save(*verdictCachePath); err != nil {
+			warnings.warnf("writing verdict cache failed: %v", err)
 		}
 	}
-	return result
-}
 
-// loadExploitedPackages loads and parses the exploited packages list
-func loadExploitedPackages(path string) (map[string]map[string]bool, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	// Wrap the status descriptor once and reuse the file for every write, since each
+	// collected wrapper would close the descriptor even though we never opened it
+	var statusFile *os.File
+	if *statusFd >= 0 {
+		statusFile = os.NewFile(uintptr(*statusFd), fmt.Sprintf("fd%d", *statusFd))
 	}
-	defer file.Close()
 
-	affected := make(map[string]map[string]bool)
-	scanner := bufio.NewScanner(file)
+	// Find lockfiles
+	phaseStart := time.Now()
+	lockfiles, err := findLockfilesInRoots(roots, managers, include, exclude, extraLockfiles, warnings, stats)
+	stats.phase("discovery", phaseStart)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding lockfiles: %v\n", err)
+		os.Exit(1)
+	}
 
-	for scanner.Scan() {
+	// Gate pull requests on just the lockfiles they touch
+	unchangedSince := false
+	if *sinceCommit != "" {
+		changed, err := changedLockfiles(lockfiles, *sinceCommit)
+		if err != nil {
+			warnings.warnf("--since-commit: %v, scanning all lockfiles", err)
+		} else {
+			unchangedSince = len(lockfiles) > 0 && len(changed) == 0
+			lockfiles = changed
+		}
+	}
+
+	if len(lockfiles) == 0 && !*scanCache && !*scanTarballsFlag && !*checkAutomergeFlag && !*checkPostureFlag && !*scanYarnPluginsFlag && !*scanGlobalFlag && !*scanInstalledFlag && !*resolveTransitiveFlag {
+		warning := ""
+		for _, root := range roots {
+			if unchangedSince {
+				break // the lockfiles exist, they just did not change
+			}
+			if warning = emptyDiscoveryWarning(root, managers, include, exclude, extraLockfiles); warning != "" {
+				break
+			}
+		}
+		if warning != "" {
+			warnings.warnf("%s", warning)
+		} else if unchangedSince && !machineOutput && !*checkOnly {
+			fmt.Printf("No lockfiles changed since %s\n", *sinceCommit)
+		} else if !unchangedSince && !machineOutput && !*checkOnly {
+			fmt.Printf("No lockfiles found under: %s\n", strings.Join(roots, ", "))
+		}
+		if statusFile != nil {
+			if err := writeStatusLine(statusFile, Summary{}); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing status to fd %d: %v\n", *statusFd, err)
+			}
+		}
+		os.Exit(0)
+	}
+
+	discovered := lockfiles
+
+	// Spot-check a random subset of a large tree
+	var sample *Sample
+	if *sampleSize > 0 && *sampleSize < len(lockfiles) {
+		seed := *sampleSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		sample = &Sample{Scanned: *sampleSize, Discovered: len(lockfiles), Seed: seed}
+		lockfiles = sampleLockfiles(lockfiles, *sampleSize, seed)
+		if *minimalMemory || *watch {
+			fmt.Fprintf(os.Stderr, "Note: scanning a sample of %d of %d lockfiles (seed %d)\n", sample.Scanned, sample.Discovered, seed)
+		}
+	}
+
+	rootAbs := commonRoot(roots)
+
+	// Load findings accepted as known
+	var baseline map[string]bool
+	if *baselinePath != "" {
+		baseline, err = loadBaseline(*baselinePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Filter and annotate results before they are reported
+	var registry *registryClient
+	postProcess := func(results []Result) ([]Result, bool, bool) {
+		applySeverities(results, affected, severities)
+		applyAdvisories(results, affected, opts.advisories)
+
+		// Prereleases of compromised packages may be attacker-published canaries
+		if *flagPrereleases {
+			flagPrereleaseFindings(results, affected)
+		}
+
+		// Limit reported findings to selected managers
+		if len(reportManagers) > 0 {
+			results, _, _ = filterResultsByManagers(results, reportManagers, extraLockfiles)
+		}
+
+		// Drop findings below the requested confidence
+		if *minConfidence != "" {
+			results, _, _ = filterResultsByConfidence(results, *minConfidence)
+		}
+
+		// Narrow findings to a scope, or to scoped or unscoped packages
+		if *scopeFilter != "" || *scopedOnly || *unscopedOnly {
+			results, _, _ = filterResultsByScope(results, *scopeFilter, *scopedOnly, *unscopedOnly)
+		}
+
+		// Drop peer-only and optional-only findings
+		if *ignorePeer || *ignoreOptional {
+			results, _, _ = filterResultsByDependencyType(results, *ignorePeer, *ignoreOptional)
+		}
+
+		// Drop warnings for versions declared safe
+		if len(safeRanges) > 0 {
+			results, _, _ = filterResultsBySafeRanges(results, safeRanges)
+		}
+
+		// Hide findings already accepted in the baseline
+		if baseline != nil {
+			results, _, _ = filterResultsByBaseline(results, baseline, rootAbs)
+		}
+
+		anyAffected := false
+		anyWarnings := false
+		for _, result := range results {
+			anyWarnings = anyWarnings || result.Incomplete
+			for _, pkg := range result.Packages {
+				anyAffected = anyAffected || pkg.IsAffected
+				anyWarnings = anyWarnings || pkg.IsWarning
+			}
+		}
+
+		// Annotate findings with registry metadata
+		if *enrichRegistry && anyAffected {
+			if registry == nil {
+				registry = newRegistryClient(*registryURL, defaultRegistryCacheDir())
+			}
+			for _, err := range enrichResults(results, affected, registry) {
+				warnings.warnf("registry lookup failed: %v", err)
+			}
+		}
+
+		// Little-used packages on the list may be newly planted malware
+		if *flagUnpopularBelow > 0 && (anyAffected || anyWarnings) {
+			if registry == nil {
+				registry = newRegistryClient(*registryURL, defaultRegistryCacheDir())
+			}
+			for _, err := range flagUnpopularPackages(results, *flagUnpopularBelow, registry) {
+				warnings.warnf("download count lookup failed: %v", err)
+			}
+		}
+
+		// The attacker may have kept publishing after the listed versions
+		if *flagNewerThanCompromise && anyWarnings {
+			if registry == nil {
+				registry = newRegistryClient(*registryURL, defaultRegistryCacheDir())
+			}
+			for _, err := range flagNewerThanCompromisePackages(results, affected, registry) {
+				warnings.warnf("publish date lookup failed: %v", err)
+			}
+		}
+
+		// Compare recorded hashes against the published artifacts
+		if *verifyChecksumsFlag && (anyAffected || anyWarnings) {
+			if registry == nil {
+				registry = newRegistryClient(*registryURL, defaultRegistryCacheDir())
+			}
+			for _, err := range verifyChecksums(results, registry) {
+				warnings.warnf("checksum verification failed: %v", err)
+			}
+		}
+
+		if *sortBy == "severity" {
+			sortResultsBySeverity(results)
+		}
+
+		// Name the submodule each lockfile belongs to so responders know what to update
+		annotateSubmodules(results, rootAbs)
+
+		return results, anyAffected, anyWarnings
+	}
+
+	// resolveTransitiveRoots checks the registry-resolved trees of lockfile-less manifests
+	resolveTransitiveRoots := func() []Result {
+		if registry == nil {
+			registry = newRegistryClient(*registryURL, defaultRegistryCacheDir())
+		}
+		return forEachRoot(roots, func(root string) []Result {
+			transitiveResults, errs := scanTransitive(root, affected, registry)
+			for _, err := range errs {
+				warnings.warnf("resolving dependencies failed: %v", err)
+			}
+			return transitiveResults
+		})
+	}
+
+	// scanYarnPluginsRoots checks the .yarnrc.yml plugins under every root
+	scanYarnPluginsRoots := func() []Result {
+		return forEachRoot(roots, func(root string) []Result {
+			pluginResults, errs := scanYarnPlugins(root, affected)
+			for _, err := range errs {
+				warnings.warnf("Yarn plugin check failed: %v", err)
+			}
+			return pluginResults
+		})
+	}
+
+	// Anchor reported paths at the repository root so they match from any subdirectory
+	repoRoot := ""
+	if *repoRelative {
+		repoRoot = findRepoRoot(rootAbs)
+		if repoRoot == "" {
+			warnings.warnf("no git repository found above %s, reporting paths as scanned", rootAbs)
+		}
+	}
+
+	// Keep re-scanning as lockfiles or the remote list change
+	if *watch {
+		scan := func(current map[string]map[string]bool, list []byte) {
+			affected = current
+			opts.knownBad, _ = parseKnownBadIntegrities(bytes.NewReader(list))
+			opts.advisories = parseListAdvisories(list)
+			// Verdicts only hold for the list they were computed against
+			if listHash := hashExploitedList(list); opts.verdicts != nil && opts.verdicts.ListHash != listHash {
+				opts.verdicts = newVerdictCache(listHash)
+			}
+			results, _, _ := scanLockfiles(lockfiles, affected, extraLockfiles, opts, nil)
+			results, anyAffected, anyWarnings := postProcess(results)
+			scanResult := ScanResult{
+				Root:          rootAbs,
+				Results:       results,
+				AnyAffected:   anyAffected,
+				AnyWarnings:   anyWarnings,
+				AnySuspicious: anySuspicious(results),
+				Summary:       summarizeResults(results, len(lockfiles)),
+			}
+			scanResult.Summary.SuppressedWarnings = warnings.suppressed()
+			if repoRoot != "" {
+				scanResult = repoRelativeResult(scanResult, repoRoot)
+			}
+			if *format == "json" {
+				jsonOutput, err := json.MarshalIndent(scanResult, "", jsonIndent)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+					return
+				}
+				fmt.Println(string(jsonOutput))
+				return
+			}
+			printResults(scanResult, *groupBy, *summary, *noSummary, *quiet, *onlyAffected, noColor, time.Now())
+		}
+
+		var fetchList func() ([]byte, error)
+		var refresh <-chan time.Time
+		if *listURL != "" && listSource == *listURL {
+			fetchList = func() ([]byte, error) {
+				return fetchListFrom(*listURL)
+			}
+			refresh = time.NewTicker(*listRefresh).C
+		}
+
+		w := newWatcher(lockfiles, affected, fetchList, scan, warnings)
+		w.setListContent(listContent)
+		scan(affected, listContent)
+		w.run(nil, time.NewTicker(*watchInterval).C, refresh)
+		return
+	}
+
+	// Stream findings as each lockfile completes
+	if *minimalMemory {
+		streams := []*resultStream{newResultStream(os.Stdout, *format, rootAbs, jsonIndent, noColor)}
+		var jsonFile *os.File
+		if *jsonPath != "" {
+			jsonFile, err = os.Create(*jsonPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing JSON file: %v\n", err)
+				os.Exit(1)
+			}
+			streams = append(streams, newResultStream(jsonFile, "json", rootAbs, jsonIndent, true))
+		}
+
+		for _, stream := range streams {
+			if err := stream.begin(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		emit := func(result Result) error {
+			processed, _, _ := postProcess([]Result{result})
+			for _, res := range processed {
+				for _, stream := range streams {
+					if err := stream.add(res); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		phaseStart = time.Now()
+		err := streamLockfiles(lockfiles, affected, extraLockfiles, opts, stats, emit)
+		saveVerdicts()
+		opts.trace.finish(lockfiles)
+		if err == nil && *scanCache {
+			cacheResults := forEachRoot(roots, func(root string) []Result {
+				results, _, _ := scanCaches(root, affected)
+				return results
+			})
+			for _, result := range cacheResults {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && safeList != nil {
+			safeListResults, errs := checkSafeList(lockfiles, safeList)
+			for _, listErr := range errs {
+				warnings.warnf("safe list check failed: %v", listErr)
+			}
+			for _, result := range safeListResults {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *scanInstalledFlag {
+			installedResults := forEachRoot(roots, func(root string) []Result {
+				results, _, _ := scanInstalled(root, affected)
+				return results
+			})
+			for _, result := range installedResults {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *verifyInstalledFlag {
+			driftResults, errs := verifyInstalled(lockfiles, &http.Client{Timeout: listFetchTimeout})
+			for _, verifyErr := range errs {
+				warnings.warnf("verifying installed files failed: %v", verifyErr)
+			}
+			for _, result := range driftResults {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *resolveTransitiveFlag {
+			for _, result := range resolveTransitiveRoots() {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *scanGlobalFlag {
+			globalResults, _, _ := scanGlobal(globalNodeModulesDirs(), affected)
+			for _, result := range globalResults {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *scanYarnPluginsFlag {
+			for _, result := range scanYarnPluginsRoots() {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *checkAutomergeFlag {
+			for _, result := range forEachRoot(roots, checkAutomerge) {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *checkPostureFlag {
+			for _, result := range checkPosture(roots, lockfiles) {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil && *scanTarballsFlag {
+			tarballResults := forEachRoot(roots, func(root string) []Result {
+				results, _, _ := scanTarballs(root, affected, include, exclude)
+				return results
+			})
+			for _, result := range tarballResults {
+				if err = emit(result); err != nil {
+					break
+				}
+			}
+		}
+		for _, stream := range streams {
+			if err == nil {
+				err = stream.finish(len(lockfiles), warnings.suppressed())
+			}
+		}
+		stats.phase("scanning", phaseStart)
+		if jsonFile != nil {
+			jsonFile.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+
+		scanResult := ScanResult{
+			Root:          rootAbs,
+			AnyAffected:   streams[0].anyAffected,
+			AnyWarnings:   streams[0].anyWarnings,
+			AnySuspicious: streams[0].anySuspicious,
+			Summary:       streams[0].summary,
+		}
+		if err := writeJSONReports(scanResult, "", *summaryJSONPath, jsonIndent); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON file: %v\n", err)
+			os.Exit(1)
+		}
+		if statusFile != nil {
+			if err := writeStatusLine(statusFile, scanResult.Summary); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing status to fd %d: %v\n", *statusFd, err)
+			}
+		}
+		stats.print(os.Stderr)
+
+		os.Exit(scanExitCode(scanResult, *failThreshold, *exitCodeAffected, *exitCodeWarning, *failOnSuspicious))
+	}
+
+	// Scan lockfiles
+	phaseStart = time.Now()
+	var unchangedLockfiles map[string]bool
+	if *reportOnlyChanged {
+		unchangedLockfiles = opts.verdicts.recordLockfiles(lockfiles)
+	}
+	results, _, _ := scanLockfiles(lockfiles, affected, extraLockfiles, opts, stats)
+	saveVerdicts()
+	opts.trace.finish(lockfiles)
+	stats.phase("scanning", phaseStart)
+
+	// Record what was and wasn't scanned as audit evidence
+	if *coveragePath != "" {
+		coverage := buildCoverage(roots, managers, extraLockfiles, discovered, lockfiles, results, rootAbs)
+		if err := writeCoverage(*coveragePath, coverage, jsonIndent); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing coverage file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Scan package manager caches
+	if *scanCache {
+		results = append(results, forEachRoot(roots, func(root string) []Result {
+			cacheResults, _, _ := scanCaches(root, affected)
+			return cacheResults
+		})...)
+	}
+
+	// Scan what is physically installed, which may differ from the lockfile
+	if *scanInstalledFlag {
+		results = append(results, forEachRoot(roots, func(root string) []Result {
+			installedResults, _, _ := scanInstalled(root, affected)
+			return installedResults
+		})...)
+	}
+
+	// Compare installed files with the artifacts the lockfile pins
+	if *verifyInstalledFlag {
+		driftResults, errs := verifyInstalled(lockfiles, &http.Client{Timeout: listFetchTimeout})
+		for _, err := range errs {
+			warnings.warnf("verifying installed files failed: %v", err)
+		}
+		results = append(results, driftResults...)
+	}
+
+	// Resolve manifests that have no lockfile through the registry
+	if *resolveTransitiveFlag {
+		results = append(results, resolveTransitiveRoots()...)
+	}
+
+	// Scan globally installed packages
+	if *scanGlobalFlag {
+		globalResults, _, _ := scanGlobal(globalNodeModulesDirs(), affected)
+		results = append(results, globalResults...)
+	}
+
+	// Flag anything not on the approved packages allowlist
+	if safeList != nil {
+		safeListResults, errs := checkSafeList(lockfiles, safeList)
+		for _, err := range errs {
+			warnings.warnf("safe list check failed: %v", err)
+		}
+		results = append(results, safeListResults...)
+	}
+
+	// Check Yarn plugins, which run outside the lockfile
+	if *scanYarnPluginsFlag {
+		results = append(results, scanYarnPluginsRoots()...)
+	}
+
+	// Report auto-merge settings as context
+	if *checkAutomergeFlag {
+		results = append(results, forEachRoot(roots, checkAutomerge)...)
+	}
+
+	// Report weakened verification settings as context
+	if *checkPostureFlag {
+		results = append(results, checkPosture(roots, lockfiles)...)
+	}
+
+	// Sweep the filesystem for package tarballs
+	if *scanTarballsFlag {
+		results = append(results, forEachRoot(roots, func(root string) []Result {
+			tarballResults, _, _ := scanTarballs(root, affected, include, exclude)
+			return tarballResults
+		})...)
+	}
+
+	results, anyAffected, anyWarnings := postProcess(results)
+
+	// Record the current findings so later scans report only new ones
+	if *writeBaselinePath != "" {
+		if err := writeBaseline(*writeBaselinePath, *baselineFormat, buildBaseline(results, rootAbs)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Mark compromised entries in copies of the lockfiles for review in an editor
+	if *annotateOut != "" {
+		written, err := writeAnnotatedLockfiles(results, rootAbs, *annotateOut, extraLockfiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing annotated lockfiles: %v\n", err)
+			os.Exit(1)
+		}
+		for _, path := range written {
+			fmt.Fprintf(os.Stderr, "Annotated lockfile written to %s\n", path)
+		}
+	}
+
+	// Create output
+	phaseStart = time.Now()
+	scanResult := ScanResult{
+		Root:          rootAbs,
+		Results:       results,
+		AnyAffected:   anyAffected,
+		AnyWarnings:   anyWarnings,
+		AnySuspicious: anySuspicious(results),
+		Summary:       summarizeResults(results, len(lockfiles)),
+		Sample:        sample,
+	}
+	scanResult.Summary.SuppressedWarnings = warnings.suppressed()
+
+	// Focus on the lockfiles just edited; the exit code still reflects every lockfile
+	if *reportOnlyChanged {
+		scanResult.Results, scanResult.Unchanged = omitUnchangedLockfiles(scanResult.Results, unchangedLockfiles)
+	}
+
+	// Keep only the newest version of each package once the summary reflects the true totals
+	if *latestOnly {
+		scanResult.Results, scanResult.Collapsed = collapseToLatest(scanResult.Results)
+	}
+
+	// Keep one lockfile from drowning out the others
+	if *maxPerLockfile > 0 {
+		scanResult.Results = capFindingsPerLockfile(scanResult.Results, *maxPerLockfile)
+	}
+
+	// Cap the findings shown once the summary reflects the true totals
+	if *limit > 0 {
+		scanResult.Results, scanResult.Omitted = limitFindings(scanResult.Results, *limit)
+	}
+
+	scannedResult := scanResult
+	if repoRoot != "" {
+		scanResult = repoRelativeResult(scanResult, repoRoot)
+	}
+
+	if statusFile != nil {
+		if err := writeStatusLine(statusFile, scanResult.Summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing status to fd %d: %v\n", *statusFd, err)
+		}
+	}
+
+	// Silent pass/fail for commit hooks
+	if *checkOnly {
+		code := scanExitCode(scanResult, *failThreshold, *exitCodeAffected, *exitCodeWarning, *failOnSuspicious)
+		if code != 0 {
+			fmt.Fprint(os.Stderr, formatStatusLine(scanResult.Summary))
+		}
+		os.Exit(code)
+	}
+
+	// Plan upgrades instead of reporting findings
+	if *planRemediationFlag {
+		if registry == nil {
+			registry = newRegistryClient(*registryURL, defaultRegistryCacheDir())
+		}
+		steps, errs := planRemediation(results, affected, registry)
+		for _, err := range errs {
+			warnings.warnf("registry lookup failed: %v", err)
+		}
+		if machineOutput {
+			if steps == nil {
+				steps = []RemediationStep{}
+			}
+			planOutput, err := json.MarshalIndent(steps, "", jsonIndent)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(planOutput))
+		} else {
+			printRemediationPlan(steps, noColor)
+		}
+		os.Exit(scanExitCode(scanResult, *failThreshold, *exitCodeAffected, *exitCodeWarning, *failOnSuspicious))
+	}
+
+	// JSON output
+	jsonOutput, err := json.MarshalIndent(scanResult, "", jsonIndent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case outputTemplate != "":
+		if err := renderTemplate(os.Stdout, outputTemplate, scanResult); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering template: %v\n", err)
+			os.Exit(1)
+		}
+	case *format == "json":
+		fmt.Println(string(jsonOutput))
+	case *format == "yaml":
+		if err := writeYAML(os.Stdout, scanResult); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing YAML: %v\n", err)
+			os.Exit(1)
+		}
+	case *format == "junit":
+		meta := ScanMetadata{
+			Version:    Version,
+			ListSource: listSource,
+			Root:       rootAbs,
+			Timestamp:  startTime,
+		}
+		if err := writeJUnit(os.Stdout, scanResult, meta); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JUnit report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writeJSONReports(scanResult, *jsonPath, *summaryJSONPath, jsonIndent); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSON file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *yamlPath != "" {
+		file, err := os.Create(*yamlPath)
+		if err == nil {
+			err = writeYAML(file, scanResult)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing YAML file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *reportURI != "" {
+		if err := postReport(&http.Client{Timeout: *reportTimeout}, *reportURI, *reportAuthHeader, scanResult); err != nil {
+			warnings.warnf("posting report to %s failed: %v", *reportURI, err)
+		}
+	}
+
+	if *sarifPath != "" {
+		if err := writeSARIF(*sarifPath, scanResult); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing SARIF file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *sarifGzipPath != "" {
+		if err := writeSARIFGzip(*sarifGzipPath, scanResult); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing SARIF file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *jsonZstdPath != "" {
+		if err := writeJSONZstd(*jsonZstdPath, scanResult, jsonIndent); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Human-readable output
+	if !machineOutput {
+		if *collapsePaths {
+			// Collapse from the paths as scanned, which resolve against the working directory
+			scannedResult.Root = scanResult.Root
+			scanResult = collapseResultPaths(scannedResult)
+		}
+		printResults(scanResult, *groupBy, *summary, *noSummary, *quiet, *onlyAffected, noColor, startTime)
+	}
+
+	stats.phase("output", phaseStart)
+	stats.print(os.Stderr)
+
+	// Exit code based on findings
+	os.Exit(scanExitCode(scanResult, *failThreshold, *exitCodeAffected, *exitCodeWarning, *failOnSuspicious))
+}
+
+// scanExitCode maps a scan result to the process exit code, treating compromised
+// packages as failing only once their count exceeds failThreshold, and suspicious findings
+// as failing only with failOnSuspicious
+func scanExitCode(result ScanResult, failThreshold, affectedCode, warningCode int, failOnSuspicious bool) int {
+	overBudget := result.AnyAffected && result.Summary.TotalCompromised > failThreshold
+	return determineExitCode(overBudget, result.AnyWarnings, failOnSuspicious && result.AnySuspicious, affectedCode, warningCode)
+}
+
+// anySuspicious reports whether any finding is suspicious
+func anySuspicious(results []Result) bool {
+	for _, result := range results {
+		for _, pkg := range result.Packages {
+			if pkg.IsSuspicious {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// determineExitCode maps scan findings to the process exit code; failing suspicious findings
+// use the affected code
+func determineExitCode(anyAffected, anyWarnings, failingSuspicious bool, affectedCode, warningCode int) int {
+	if anyAffected || failingSuspicious {
+		return affectedCode
+	}
+	if anyWarnings {
+		return warningCode
+	}
+	return 0
+}
+
+// listInlineEnv is the environment variable carrying an inline exploited packages list
+const listInlineEnv = "SHAI_LIST_INLINE"
+
+// resolveInlineList returns the --list-inline value, falling back to the environment unless
+// a list was given explicitly with --list-path or --list-url
+func resolveInlineList(flagValue string, explicitList bool) string {
+	if flagValue != "" || explicitList {
+		return flagValue
+	}
+	return os.Getenv(listInlineEnv)
+}
+
+// parseCommaSeparated parses a comma-separated string into a slice
+func parseCommaSeparated(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	parts := strings.Split(s, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+// formatStatusLine formats the summary as a single KEY=VALUE line for scripts
+func formatStatusLine(summary Summary) string {
+	return fmt.Sprintf("COMPROMISED=%d WARNINGS=%d LOCKFILES=%d\n",
+		summary.TotalCompromised, summary.TotalWarnings, summary.TotalLockfiles)
+}
+
+// writeStatusLine writes the status line to the file wrapping the --status-fd descriptor
+func writeStatusLine(file *os.File, summary Summary) error {
+	_, err := file.WriteString(formatStatusLine(summary))
+	return err
+}
+
+// loadExploitedPackages loads and parses the exploited packages list
+func loadExploitedPackages(path string) (map[string]map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseExploitedPackages(file)
+}
+
+// loadEmbeddedExploitedPackages loads the embedded exploited packages list
+func loadEmbeddedExploitedPackages() (map[string]map[string]bool, error) {
+	return parseExploitedPackages(strings.NewReader(embeddedExploitedPackages))
+}
+
+// loadInlineExploitedPackages parses newline- or comma-separated package@version entries
+func loadInlineExploitedPackages(list string) (map[string]map[string]bool, error) {
+	return parseExploitedPackages(strings.NewReader(strings.ReplaceAll(list, ",", "\n")))
+}
+
+// exploitedPackageRegex matches a package@version line, optionally followed by a severity and
+// the integrity hash of the compromised tarball
+var exploitedPackageRegex = regexp.MustCompile(`^(@?[^@/\s]+(?:/[^@/\s]+)?)@([vV]?[0-9]+\.[0-9]+\.[0-9]+(?:\.[0-9]+)?)(?:\s+(?i:(critical|high|medium|low)))?(?:\s+(sha(?:1|256|384|512)-[A-Za-z0-9+/]+=*))?$`)
+
+// exploitedRangeRegex matches a package@range line such as 'left-pad@>=1.2.0 <1.4.0',
+// optionally followed by a severity. A range names no single tarball, so takes no integrity
+var exploitedRangeRegex = regexp.MustCompile(`^(@?[^@/\s]+(?:/[^@/\s]+)?)@([^@]+?)(?:\s+(?i:(critical|high|medium|low)))?$`)
+
+// parseExploitedPackages parses an exploited packages list
+func parseExploitedPackages(r io.Reader) (map[string]map[string]bool, error) {
+	affected, _, err := parseExploitedList(r)
+	return affected, err
+}
+
+// Severity levels an enriched exploited packages list can assign to an entry
+const (
+	severityCritical = "critical"
+	severityHigh     = "high"
+	severityMedium   = "medium"
+	severityLow      = "low"
+)
+
+// severityRank orders severity levels, higher is more severe
+var severityRank = map[string]int{
+	severityLow:      1,
+	severityMedium:   2,
+	severityHigh:     3,
+	severityCritical: 4,
+}
+
+// parseExploitedList parses package@version and package@range lines, each optionally followed
+// by a severity (critical, high, medium or low), returning the affected map and severities
+// keyed by name@version. A range is stored as one entry of the package's versions, which
+// listedVersion matches installed versions against
+func parseExploitedList(r io.Reader) (map[string]map[string]bool, map[string]string, error) {
+	affected := make(map[string]map[string]bool)
+	severities := make(map[string]string)
+
+	// A JSON list of {name, versions, severity, advisory} entries is read as the lines it lists
+	reader := bufio.NewReader(r)
+	if start, _ := reader.Peek(512); isJSONList(start) {
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines, err := jsonListLines(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader = bufio.NewReader(strings.NewReader(lines))
+	}
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
-		// Skip comments and empty lines
-		if line == "" || strings.HasPrefix(line, "#") {
+		// Skip comments and empty lines
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Parse package@version, or else package@range
+		matches := exploitedPackageRegex.FindStringSubmatch(line)
+		if matches != nil {
+			matches[2] = normalizeVersion(matches[2])
+		} else if matches = exploitedRangeRegex.FindStringSubmatch(line); matches != nil {
+			// Collapse the range's whitespace so equal ranges share one entry
+			matches[2] = strings.Join(strings.Fields(matches[2]), " ")
+			if _, ok := parseRange(matches[2]); !ok {
+				continue
+			}
+			matches = append(matches, "")
+		}
+		if len(matches) == 5 {
+			name := matches[1]
+			version := matches[2]
+
+			// Normalize scoped packages
+			if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
+				name = "@" + name
+			}
+
+			if affected[name] == nil {
+				affected[name] = make(map[string]bool)
+			}
+			affected[name][version] = true
+			if severity := strings.ToLower(matches[3]); severity != "" {
+				severities[name+"@"+version] = severity
+			}
+		}
+	}
+
+	return affected, severities, scanner.Err()
+}
+
+// parseKnownBadIntegrities collects the integrity hashes an enriched list gives after an
+// entry, as in 'pkg@1.0.0 critical sha512-...'
+func parseKnownBadIntegrities(r io.Reader) (map[string]string, error) {
+	integrities := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		matches := exploitedPackageRegex.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if len(matches) != 5 || matches[4] == "" {
+			continue
+		}
+		name := matches[1]
+		if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
+			name = "@" + name
+		}
+		integrities[matches[4]] = name + "@" + normalizeVersion(matches[2])
+	}
+	return integrities, scanner.Err()
+}
+
+// applySeverities sets the list's severity on every compromised package it classifies, taking
+// it from the exact version or range the package matched
+func applySeverities(results []Result, affected map[string]map[string]bool, severities map[string]string) {
+	for i := range results {
+		comparator := versionComparatorFor(lockfileFormat(filepath.Base(results[i].LockFile), nil))
+		for j := range results[i].Packages {
+			pkg := &results[i].Packages[j]
+			if !pkg.IsAffected {
+				continue
+			}
+			listed, ok := listedVersion(comparator, affected[pkg.Name], pkg.Version)
+			if !ok {
+				listed = normalizeVersion(pkg.Version)
+			}
+			pkg.Severity = severities[pkg.Name+"@"+listed]
+		}
+	}
+}
+
+// sortResultsBySeverity orders findings within each result from most to least severe,
+// and results by their most severe finding
+func sortResultsBySeverity(results []Result) {
+	maxRank := func(result Result) int {
+		rank := 0
+		for _, pkg := range result.Packages {
+			rank = max(rank, severityRank[pkg.Severity])
+		}
+		return rank
+	}
+	for _, result := range results {
+		packages := result.Packages
+		sort.SliceStable(packages, func(i, j int) bool {
+			return severityRank[packages[i].Severity] > severityRank[packages[j].Severity]
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return maxRank(results[i]) > maxRank(results[j])
+	})
+}
+
+// normalizeVersion canonicalizes a version so equivalent spellings compare equal: it trims
+// whitespace and a leading v or =, drops leading zeros from numeric components and uses - as
+// the pre-release separator; build metadata after + is kept as is
+func normalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	version = strings.TrimLeft(version, "vV=")
+
+	core, suffix, separator := version, "", ""
+	if index := strings.IndexAny(version, "-_+"); index != -1 {
+		core, suffix, separator = version[:index], version[index+1:], version[index:index+1]
+		if separator == "_" {
+			separator = "-"
+		}
+	}
+
+	parts := strings.Split(core, ".")
+	for i, part := range parts {
+		if trimmed := strings.TrimLeft(part, "0"); trimmed != part {
+			if trimmed == "" {
+				trimmed = "0"
+			}
+			if _, err := strconv.Atoi(trimmed); err == nil {
+				parts[i] = trimmed
+			}
+		}
+	}
+
+	normalized := strings.Join(parts, ".")
+	if suffix != "" {
+		normalized += separator + suffix
+	}
+	return normalized
+}
+
+// lockfileMapping maps an additional lockfile name glob to the parser used for it
+type lockfileMapping struct {
+	Pattern string
+	Format  string
+}
+
+// parseLockfileMappings parses comma-separated 'glob=format' lockfile mappings
+func parseLockfileMappings(s string) ([]lockfileMapping, error) {
+	var mappings []lockfileMapping
+	for _, entry := range parseCommaSeparated(s) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid lockfile mapping '%s', expected 'glob=format'", entry)
+		}
+
+		pattern := strings.TrimSpace(parts[0])
+		format := strings.TrimSpace(parts[1])
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid lockfile glob '%s': %v", pattern, err)
+		}
+		switch format {
+		case "yarn", "npm", "pnpm", "bun":
+		default:
+			return nil, fmt.Errorf("invalid lockfile format '%s' for '%s'. Valid options: yarn, npm, pnpm, bun", format, pattern)
+		}
+
+		mappings = append(mappings, lockfileMapping{Pattern: pattern, Format: format})
+	}
+	return mappings, nil
+}
+
+// lockfileFormat returns the parser format for a lockfile name, or "" if it is not a lockfile
+func lockfileFormat(baseName string, extra []lockfileMapping) string {
+	switch baseName {
+	case "yarn.lock":
+		return "yarn"
+	case "package-lock.json", "npm-shrinkwrap.json":
+		return "npm"
+	case "pnpm-lock.yaml", "shrinkwrap.yaml":
+		return "pnpm"
+	case "bun.lock", "bun.lockb":
+		return "bun"
+	case "package.json":
+		return manifestFormat
+	}
+
+	for _, mapping := range extra {
+		if matched, _ := filepath.Match(mapping.Pattern, baseName); matched {
+			return mapping.Format
+		}
+	}
+
+	return ""
+}
+
+// findLockfiles finds all relevant lockfiles for the specified managers, reporting
+// inaccessible paths through warn
+func findLockfiles(rootDir string, managers, include, exclude []string, extra []lockfileMapping, warn *warner, stats *scanStats) ([]string, error) {
+	var lockfiles []string
+	var patterns []string
+
+	// Build patterns based on managers
+	for _, manager := range managers {
+		switch manager {
+		case "yarn":
+			patterns = append(patterns, "yarn.lock")
+		case "npm":
+			patterns = append(patterns, "package-lock.json", "npm-shrinkwrap.json")
+		case "pnpm":
+			patterns = append(patterns, "pnpm-lock.yaml", "shrinkwrap.yaml")
+		case "bun":
+			patterns = append(patterns, "bun.lock", "bun.lockb")
+		case manifestFormat:
+			patterns = append(patterns, "package.json")
+		}
+	}
+
+	// Find all files matching patterns
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			warn.warnf("skipping %s: %v", path, err)
+			return nil // Skip inaccessible files
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+		stats.fileWalked()
+
+		// Check if file matches any pattern
+		for _, pattern := range patterns {
+			if d.Name() == pattern {
+				// Check include/exclude filters
+				if includePath(path, rootDir, include, exclude, stats) {
+					lockfiles = append(lockfiles, path)
+					stats.fileMatched()
+				}
+				return nil
+			}
+		}
+
+		// Check additional lockfile names for the selected managers
+		for _, mapping := range extra {
+			if matched, _ := filepath.Match(mapping.Pattern, d.Name()); matched {
+				for _, manager := range managers {
+					if manager == mapping.Format && includePath(path, rootDir, include, exclude, stats) {
+						lockfiles = append(lockfiles, path)
+						stats.fileMatched()
+						break
+					}
+				}
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	return preferShrinkwrap(lockfiles), err
+}
+
+// preferShrinkwrap drops each package-lock.json that sits next to an npm-shrinkwrap.json,
+// because npm ignores package-lock.json when a shrinkwrap is present
+func preferShrinkwrap(lockfiles []string) []string {
+	shrinkwrapDirs := make(map[string]bool)
+	for _, lockfile := range lockfiles {
+		if filepath.Base(lockfile) == "npm-shrinkwrap.json" {
+			shrinkwrapDirs[filepath.Dir(lockfile)] = true
+		}
+	}
+	if len(shrinkwrapDirs) == 0 {
+		return lockfiles
+	}
+
+	kept := lockfiles[:0]
+	for _, lockfile := range lockfiles {
+		if filepath.Base(lockfile) == "package-lock.json" && shrinkwrapDirs[filepath.Dir(lockfile)] {
+			continue
+		}
+		kept = append(kept, lockfile)
+	}
+	return kept
+}
+
+// findLockfilesInRoots finds lockfiles under each root, evaluating include and exclude
+// patterns relative to the root being walked. Lockfiles under overlapping roots are listed once
+func findLockfilesInRoots(roots, managers, include, exclude []string, extra []lockfileMapping, warn *warner, stats *scanStats) ([]string, error) {
+	var lockfiles []string
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		found, err := findLockfiles(root, managers, include, exclude, extra, warn, stats)
+		if err != nil {
+			return nil, err
+		}
+		for _, lockfile := range found {
+			key := lockfile
+			if abs, err := filepath.Abs(lockfile); err == nil {
+				key = abs
+			}
+			if !seen[key] {
+				seen[key] = true
+				lockfiles = append(lockfiles, lockfile)
+			}
+		}
+	}
+	return lockfiles, nil
+}
+
+// forEachRoot runs a per-root scan over every root and concatenates the results
+func forEachRoot(roots []string, scan func(root string) []Result) []Result {
+	var results []Result
+	for _, root := range roots {
+		results = append(results, scan(root)...)
+	}
+	return results
+}
+
+// commonRoot returns the deepest directory containing every root, as an absolute path
+func commonRoot(roots []string) string {
+	common := ""
+	for i, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if i == 0 || common == "" {
+			common = abs
+			continue
+		}
+		for common != filepath.Dir(common) {
+			if rel, err := filepath.Rel(common, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				break
+			}
+			common = filepath.Dir(common)
+		}
+	}
+	return common
+}
+
+// emptyDiscoveryWarning explains an empty discovery caused by --include patterns that matched nothing.
+// It returns "" when no lockfiles exist at all, so callers can report that case as usual.
+func emptyDiscoveryWarning(rootDir string, managers, include, exclude []string, extra []lockfileMapping) string {
+	if len(include) == 0 {
+		return ""
+	}
+
+	// The discovery walk already reported inaccessible paths
+	unfiltered, err := findLockfiles(rootDir, managers, nil, exclude, extra, &warner{quiet: true}, nil)
+	if err != nil || len(unfiltered) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("no lockfiles matched your include pattern(s) %s, but %d lockfile(s) exist under %s; check your --include value",
+		strings.Join(include, ", "), len(unfiltered), rootDir)
+}
+
+// shouldIncludePath checks if a path should be included based on include/exclude patterns
+func shouldIncludePath(fullPath, rootDir string, include, exclude []string) bool {
+	return includePath(fullPath, rootDir, include, exclude, nil)
+}
+
+// includePath implements shouldIncludePath, counting pattern evaluations in stats
+func includePath(fullPath, rootDir string, include, exclude []string, stats *scanStats) bool {
+	// Get relative path from root
+	relPath, err := filepath.Rel(rootDir, fullPath)
+	if err != nil {
+		return false
+	}
+
+	// Normalize path separators
+	relPath = filepath.ToSlash(relPath)
+
+	// Check exclude patterns first
+	for _, pattern := range exclude {
+		stats.patternEvaluated()
+		if matchesGlobPattern(relPath, pattern) {
+			return false
+		}
+	}
+
+	// If include patterns specified, path must match at least one
+	if len(include) > 0 {
+		for _, pattern := range include {
+			stats.patternEvaluated()
+			if matchesGlobPattern(relPath, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// matchesPattern checks if a path matches a glob pattern
+func matchesPattern(path, pattern string) bool {
+	// Convert glob to regex
+	regex := "^" + regexp.QuoteMeta(pattern)
+	regex = strings.ReplaceAll(regex, "\\*\\*", ".*")
+	regex = strings.ReplaceAll(regex, "\\*", "[^/]*")
+	regex += "$"
+
+	matched, _ := regexp.MatchString(regex, path)
+	return matched
+}
+
+// Improved glob matching function
+func matchesGlobPattern(path, pattern string) bool {
+	// Handle common glob patterns more accurately
+	if pattern == "**/node_modules/**" {
+		return strings.Contains(path, "node_modules/")
+	}
+	if strings.HasSuffix(pattern, "/**") {
+		prefix := strings.TrimSuffix(pattern, "/**")
+		return strings.HasPrefix(path, prefix+"/") || path == strings.TrimSuffix(prefix, "/")
+	}
+	if strings.HasPrefix(pattern, "**/") {
+		suffix := strings.TrimPrefix(pattern, "**/")
+		return strings.Contains(path, "/"+suffix) || strings.HasPrefix(path, suffix)
+	}
+
+	// Fallback to simple regex conversion
+	regex := "^" + regexp.QuoteMeta(pattern)
+	regex = strings.ReplaceAll(regex, "\\*\\*", ".*")
+	regex = strings.ReplaceAll(regex, "\\*", "[^/]*")
+	regex += "$"
+
+	matched, _ := regexp.MatchString(regex, path)
+	return matched
+}
+
+// scanOptions holds the settings a scan applies to every lockfile it parses. It is passed
+// down rather than kept in package state so that concurrent scans, such as --jobs, each
+// apply their own
+type scanOptions struct {
+	// includeRoot checks an npm or bun lockfile's root package itself, set by --include-root
+	includeRoot bool
+	// contextLines is how many lockfile lines around a finding the text parsers capture,
+	// set by --context-lines
+	contextLines int
+	// knownBad maps the integrity hash of each compromised tarball an enriched list names to
+	// its name@version, so a lockfile entry carrying that hash is caught whatever version it
+	// claims
+	knownBad map[string]string
+	// advisories maps each name@version or name@range of a JSON list to its entry's advisory,
+	// for findings to carry
+	advisories map[string]string
+	// warn reports the operational problems met while scanning
+	warn *warner
+	// verdicts is the verdict cache the parsers consult, nil when --verdict-cache is not set
+	verdicts *verdictCache
+	// trace is the tracer the parsers report evaluations to, nil unless --trace is set
+	trace *packageTracer
+	// bunBinary is the bun executable used to read bun.lockb files, empty to read only the
+	// tarball URLs they record
+	bunBinary string
+}
+
+// scanLockfiles scans all found lockfiles
+func scanLockfiles(lockfiles []string, affected map[string]map[string]bool, extra []lockfileMapping, opts scanOptions, stats *scanStats) ([]Result, bool, bool) {
+	var results []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, lockfile := range lockfiles {
+		format := lockfileFormat(filepath.Base(lockfile), extra)
+		packages, notices, hasAffected, hasWarnings := scanLockfileAs(lockfile, format, affected, opts, stats)
+
+		if len(packages) > 0 || len(notices) > 0 {
+			results = append(results, Result{
+				LockFile:   lockfile,
+				Packages:   packages,
+				Notices:    notices,
+				Incomplete: incompleteScan(notices),
+			})
+		}
+
+		if hasAffected {
+			anyAffected = true
+		}
+		if hasWarnings {
+			anyWarnings = true
+		}
+	}
+
+	return results, anyAffected, anyWarnings
+}
+
+// resultManager infers the package manager a result belongs to from its lockfile or cache path
+func resultManager(lockFile string, extra []lockfileMapping) string {
+	if format := lockfileFormat(filepath.Base(lockFile), extra); format != "" {
+		return format
+	}
+
+	switch {
+	case filepath.Base(lockFile) == ".pnpm-store", filepath.Base(lockFile) == ".pnpm":
+		return "pnpm"
+	case filepath.Base(lockFile) == "cache" && filepath.Base(filepath.Dir(lockFile)) == ".yarn":
+		return "yarn"
+	case filepath.Base(lockFile) == ".yarnrc.yml":
+		return "yarn"
+	}
+	return ""
+}
+
+// filterResultsByManagers keeps only results for the given managers and recomputes the result flags
+func filterResultsByManagers(results []Result, managers []string, extra []lockfileMapping) ([]Result, bool, bool) {
+	var filtered []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, result := range results {
+		manager := resultManager(result.LockFile, extra)
+		keep := false
+		for _, m := range managers {
+			if m == manager {
+				keep = true
+				break
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		filtered = append(filtered, result)
+		for _, pkg := range result.Packages {
+			if pkg.IsAffected {
+				anyAffected = true
+			}
+			if pkg.IsWarning {
+				anyWarnings = true
+			}
+		}
+	}
+
+	return filtered, anyAffected, anyWarnings
+}
+
+// scanLockfile scans a single lockfile
+func scanLockfile(lockfile string, affected map[string]map[string]bool) ([]Package, []string, bool, bool) {
+	// Determine file type and parse accordingly
+	return scanLockfileAs(lockfile, lockfileFormat(filepath.Base(lockfile), nil), affected, scanOptions{}, nil)
+}
+
+// scanLockfileAs scans a single lockfile with the parser for the given format
+func scanLockfileAs(lockfile, format string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	return parseRecovered(lockfile, func() ([]Package, []string, bool, bool) {
+		return parseLockfileAs(lockfile, format, affected, opts, stats)
+	})
+}
+
+// parseRecovered runs a lockfile parser, turning a panic into a parse error notice so one
+// malformed file cannot abort the whole scan
+func parseRecovered(lockfile string, parse func() ([]Package, []string, bool, bool)) (packages []Package, notices []string, hasAffected, hasWarnings bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			packages = nil
+			notices = []string{fmt.Sprintf("parse error: %v, this file was not scanned", r)}
+			hasAffected, hasWarnings = false, false
+		}
+	}()
+	return parse()
+}
+
+// parseLockfileAs dispatches a lockfile to the parser for the given format
+func parseLockfileAs(lockfile, format string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	switch format {
+	case "yarn":
+		return parseYarnLock(lockfile, affected, opts, stats)
+	case "npm":
+		return parseNPMLock(lockfile, affected, opts, stats)
+	case "pnpm":
+		return parsePNMLock(lockfile, affected, opts, stats)
+	case "bun":
+		// The binary bun.lockb is read in full only through bun itself
+		if filepath.Base(lockfile) == "bun.lockb" {
+			return parseBunLockb(lockfile, affected, opts, stats)
+		}
+		return parseBunLock(lockfile, affected, opts, stats)
+	case manifestFormat:
+		return parseManifest(lockfile, affected, opts, stats)
+	}
+	return nil, nil, false, false
+}
+
+// readLockfile reads a lockfile, dropping the UTF-8 byte order mark some Windows editors
+// write, which would otherwise make the JSON and YAML decoders reject the file
+func readLockfile(lockfile string) ([]byte, error) {
+	content, err := os.ReadFile(lockfile)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")), nil
+}
+
+// yarnLockEntry is one resolved package entry of a yarn.lock
+type yarnLockEntry struct {
+	name      string
+	version   string
+	integrity string
+	checksum  string // Berry cache checksum
+	resolved  string
+	line      int    // index of the header line
+	alias     string // installed name when the header is alias@npm:real@range
+}
+
+// parseYarnLock parses a yarn.lock file
+func parseYarnLock(lockfile string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	comparator := versionComparatorFor("yarn")
+	var packages []Package
+	var notices []string
+	hasAffected := false
+	hasWarnings := false
+
+	content, err := readLockfile(lockfile)
+	if err != nil {
+		return packages, notices, hasAffected, hasWarnings
+	}
+	stats.fileParsed()
+
+	lines := strings.Split(string(content), "\n")
+	// A package can be installed at several versions, each in its own entry
+	var entries []yarnLockEntry
+	foundLocal := make(map[string]string) // name -> local file:/link: source
+
+	i := 0
+	for i < len(lines) {
+		line := strings.TrimSpace(lines[i])
+
+		// Look for package header lines
+		if strings.Contains(line, "@") && strings.Contains(line, ":") {
+			// Extract package name from header
+			header := strings.Trim(line, `":`)
+			name := extractPackageNameFromYarnHeader(header)
+			if name == "" {
+				i++
+				continue
+			}
+			for _, protocol := range []string{"@file:", "@link:", "@portal:"} {
+				if index := strings.Index(header, protocol); index != -1 {
+					foundLocal[name] = strings.Split(header[index+1:], ",")[0]
+				}
+			}
+
+			// Find version in the following lines
+			version := ""
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "\"") {
+				verLine := strings.TrimSpace(lines[j])
+				if strings.HasPrefix(verLine, "version") {
+					// Classic uses `version "1.0.0"`, Berry uses `version: 1.0.0`
+					version = strings.Trim(strings.TrimPrefix(verLine, "version"), ` ":`)
+					break
+				}
+				j++
+			}
+
+			if version != "" {
+				entry := yarnLockEntry{name: name, version: version, line: i}
+				// An aliased install is checked, and correlated across lockfiles, as the real package
+				if alias, real, found := strings.Cut(name, "@npm:"); found {
+					entry.alias, entry.name = alias, real
+				}
+
+				// Collect integrity signals from the rest of the entry
+				for j++; j < len(lines) && strings.TrimSpace(lines[j]) != ""; j++ {
+					field := strings.TrimSpace(lines[j])
+					switch {
+					case strings.HasPrefix(field, "integrity"):
+						entry.integrity = strings.Trim(strings.TrimPrefix(field, "integrity"), ` ":`)
+					case strings.HasPrefix(field, "checksum"):
+						entry.checksum = strings.Trim(strings.TrimPrefix(field, "checksum"), ` ":`)
+					case strings.HasPrefix(field, "resolved"):
+						entry.resolved = strings.Trim(strings.TrimPrefix(field, "resolved"), ` ":`)
+						if strings.HasPrefix(entry.resolved, "file:") {
+							foundLocal[name] = entry.resolved
+						}
+					}
+				}
+				entries = append(entries, entry)
+			}
+		}
+		i++
+	}
+
+	siblingsUseSHA512 := false
+	for _, entry := range entries {
+		if strings.Contains(entry.integrity, "sha512-") {
+			siblingsUseSHA512 = true
+			break
+		}
+	}
+
+	// Local overrides sharing a compromised package's name need a human to verify them
+	for name, source := range foundLocal {
+		if affected[name] != nil {
+			notices = append(notices, localOverrideNotice(name, source))
+		}
+	}
+
+	// Check every installed version against affected packages
+	for _, entry := range entries {
+		if reason := integrityDowngradeReason(entry.integrity, entry.resolved, siblingsUseSHA512); reason != "" {
+			packages = append(packages, suspiciousPackage(entry.name, entry.version, reason))
+		}
+		if reason := resolvedNameMismatchReason(entry.name, entry.resolved); reason != "" {
+			packages = append(packages, suspiciousPackage(entry.name, entry.version, reason))
+		}
+
+		stats.packageEnumerated(entry.name, entry.version)
+		stats.mapLookup()
+		opts.trace.record(lockfile, entry.name, entry.version, affected)
+		if pkg, ok := opts.verdicts.check(comparator, entry.name, entry.version, entry.integrity, entry.resolved, affected, opts.knownBad); ok {
+			// Berry checksums hash Yarn's zip archive, so they are kept in Yarn's own format
+			pkg.Integrity = normalizeIntegrity(entry.integrity, entry.resolved)
+			if pkg.Integrity == "" {
+				pkg.Integrity = entry.checksum
+			}
+			pkg.Context = lockfileContext(lines, entry.line, opts.contextLines)
+			pkg.Alias = entry.alias
+			packages = append(packages, pkg)
+
+			if pkg.IsAffected {
+				hasAffected = true
+			}
+			if pkg.IsWarning {
+				hasWarnings = true
+			}
+		}
+	}
+
+	return packages, notices, hasAffected, hasWarnings
+}
+
+// Confidence levels for a match, from least to most certain
+const (
+	confidenceLow    = "low"
+	confidenceMedium = "medium"
+	confidenceHigh   = "high"
+)
+
+// confidenceRank orders confidence levels for filtering
+var confidenceRank = map[string]int{
+	confidenceLow:    1,
+	confidenceMedium: 2,
+	confidenceHigh:   3,
+}
+
+// matchConfidence grades a match from the signals the lockfile entry provides: name-only
+// matches are low and exact versions are medium. A version or an unverified hash can be
+// edited to look legitimate, so only an integrity that is one of the list's known-bad
+// hashes confirms the artifact and grades high
+func matchConfidence(isAffected bool, integrity string, knownBad map[string]string) string {
+	if !isAffected {
+		return confidenceLow
+	}
+	if _, ok := knownBad[integrity]; ok && integrity != "" {
+		return confidenceHigh
+	}
+	return confidenceMedium
+}
+
+// filterResultsByConfidence drops findings below the minimum confidence and recomputes the result flags
+func filterResultsByConfidence(results []Result, minConfidence string) ([]Result, bool, bool) {
+	var filtered []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, result := range results {
+		var packages []Package
+		for _, pkg := range result.Packages {
+			// Notices carry no confidence and are always kept
+			if pkg.Confidence != "" && confidenceRank[pkg.Confidence] < confidenceRank[minConfidence] {
+				continue
+			}
+			packages = append(packages, pkg)
+			if pkg.IsAffected {
+				anyAffected = true
+			}
+			if pkg.IsWarning {
+				anyWarnings = true
+			}
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			result.Packages = packages
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered, anyAffected, anyWarnings
+}
+
+// filterResultsByScope keeps findings in the given scope, or only scoped or only unscoped
+// findings, and recomputes the result flags. Notices are not package findings and are kept
+func filterResultsByScope(results []Result, scope string, scopedOnly, unscopedOnly bool) ([]Result, bool, bool) {
+	prefix := ""
+	if scope != "" {
+		prefix = "@" + strings.Trim(scope, "@/") + "/"
+	}
+
+	var filtered []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, result := range results {
+		var packages []Package
+		for _, pkg := range result.Packages {
+			if pkg.IsAffected || pkg.IsWarning || pkg.IsSuspicious {
+				scoped := strings.HasPrefix(pkg.Name, "@")
+				if (prefix != "" && !strings.HasPrefix(pkg.Name, prefix)) || (scopedOnly && !scoped) || (unscopedOnly && scoped) {
+					continue
+				}
+			}
+			packages = append(packages, pkg)
+			if pkg.IsAffected {
+				anyAffected = true
+			}
+			if pkg.IsWarning {
+				anyWarnings = true
+			}
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			result.Packages = packages
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered, anyAffected, anyWarnings
+}
+
+// filterResultsByDependencyType drops findings for peer or optional lockfile entries and
+// recomputes the result flags
+func filterResultsByDependencyType(results []Result, ignorePeer, ignoreOptional bool) ([]Result, bool, bool) {
+	var filtered []Result
+	anyAffected := false
+	anyWarnings := false
+
+	for _, result := range results {
+		var packages []Package
+		for _, pkg := range result.Packages {
+			if (ignorePeer && pkg.Peer) || (ignoreOptional && pkg.Optional) {
+				continue
+			}
+			packages = append(packages, pkg)
+			if pkg.IsAffected {
+				anyAffected = true
+			}
+			if pkg.IsWarning {
+				anyWarnings = true
+			}
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			result.Packages = packages
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered, anyAffected, anyWarnings
+}
+
+// integrityStrength ranks SRI hash algorithms, higher is stronger
+var integrityStrength = map[string]int{"sha1": 1, "sha256": 2, "sha384": 3, "sha512": 4}
+
+// normalizeIntegrity reduces a lockfile's recorded hash to one algorithm-hash token such as
+// sha512-...: the strongest of several space-separated SRI tokens, or, for Yarn classic
+// entries without one, the sha1 hex fragment of the resolved URL. It returns "" when neither
+// is present
+func normalizeIntegrity(integrity, resolved string) string {
+	best := ""
+	for _, token := range strings.Fields(integrity) {
+		algorithm, _, found := strings.Cut(token, "-")
+		if !found || integrityStrength[algorithm] == 0 {
+			continue
+		}
+		if current, _, _ := strings.Cut(best, "-"); best == "" || integrityStrength[algorithm] > integrityStrength[current] {
+			best = token
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	if _, fragment, found := strings.Cut(resolved, "#"); found && len(fragment) == 40 {
+		if digest, err := hex.DecodeString(fragment); err == nil {
+			return "sha1-" + base64.StdEncoding.EncodeToString(digest)
+		}
+	}
+	return ""
+}
+
+// integrityDowngradeReason explains why an entry's integrity looks downgraded, or returns "".
+// Registries always publish sha512, so a sha1-only hash, or a registry tarball with no hash
+// while its siblings carry sha512, suggests the entry was edited to hide a swapped tarball.
+func integrityDowngradeReason(integrity, resolved string, siblingsUseSHA512 bool) string {
+	switch {
+	case strings.Contains(integrity, "sha512-"):
+		return ""
+	case strings.HasPrefix(integrity, "sha1-"):
+		return "integrity uses sha1 where the registry provides sha512"
+	case integrity == "" && siblingsUseSHA512 && strings.HasPrefix(resolved, "http"):
+		return "integrity is missing while sibling entries use sha512"
+	}
+	return ""
+}
+
+// splitRegistryTarball splits a registry tarball URL such as .../@scope/name/-/name-1.2.3.tgz
+// into the package its path names, decoding an escaped scope separator as in @scope%2fname, and
+// the tarball file name. ok is false for URLs that are not registry tarballs
+func splitRegistryTarball(resolved string) (name, file string, ok bool) {
+	resolved = strings.SplitN(strings.SplitN(resolved, "#", 2)[0], "?", 2)[0]
+	index := strings.LastIndex(resolved, "/-/")
+	if index == -1 || !strings.HasSuffix(resolved, ".tgz") || !strings.Contains(resolved, "://") {
+		return "", "", false
+	}
+	path, err := url.PathUnescape(resolved[:index])
+	if err != nil {
+		return "", "", false
+	}
+	segments := strings.Split(path, "/")
+	name = segments[len(segments)-1]
+	if len(segments) > 1 && strings.HasPrefix(segments[len(segments)-2], "@") {
+		name = segments[len(segments)-2] + "/" + name
+	}
+	return name, resolved[index+len("/-/"):], true
+}
+
+// resolvedNameMismatchReason explains why an entry's resolved tarball belongs to a different
+// package than the entry is named after, or returns ""
+func resolvedNameMismatchReason(name, resolved string) string {
+	resolvedName, file, ok := splitRegistryTarball(resolved)
+	switch {
+	case !ok:
+		return ""
+	case resolvedName != name:
+		return fmt.Sprintf("resolved URL points at %s, not %s", resolvedName, name)
+	case !strings.HasPrefix(file, name[strings.LastIndex(name, "/")+1:]+"-"):
+		return fmt.Sprintf("resolved tarball %s is not a %s tarball", file, name)
+	}
+	return ""
+}
+
+// localOverrideNotice describes a package resolved from a local path whose name matches a
+// compromised package, which may be a legitimate fork or a confusion attack
+func localOverrideNotice(name, source string) string {
+	return fmt.Sprintf("%s is a local override (%s) sharing its name with a compromised package; verify the local copy is not the compromised code", name, source)
+}
+
+// suspiciousPackage builds a finding for an entry whose lockfile metadata looks tampered with
+func suspiciousPackage(name, version, reason string) Package {
+	return Package{
+		Name:         name,
+		Version:      version,
+		IsSuspicious: true,
+		Notice:       fmt.Sprintf("%s@%s looks tampered: %s", name, version, reason),
+	}
+}
+
+// knownBadIntegrityPackage builds a high-confidence compromised finding for an entry whose
+// integrity hash is that of the compromised tarball of compromised (name@version)
+func knownBadIntegrityPackage(name, version, integrity, compromised string, affected map[string]map[string]bool) Package {
+	var affectedVers []string
+	for v := range affected[name] {
+		affectedVers = append(affectedVers, v)
+	}
+	sortAffectedVersions(affectedVers)
+
+	return Package{
+		Name:             name,
+		Version:          version,
+		IsAffected:       true,
+		AffectedVersions: affectedVers,
+		Confidence:       confidenceHigh,
+		Integrity:        integrity,
+		Notice:           fmt.Sprintf("integrity %s is that of the compromised %s tarball", integrity, compromised),
+	}
+}
+
+// isPrerelease reports whether a version carries a pre-release tag such as -rc.1 or -canary
+func isPrerelease(version string) bool {
+	core, _, _ := strings.Cut(normalizeVersion(version), "+")
+	return strings.Contains(core, "-")
+}
+
+// flagPrereleaseFindings adds a suspicious finding for each locked prerelease of a package
+// that has a compromised stable version; compromised prereleases are already affected
+func flagPrereleaseFindings(results []Result, affected map[string]map[string]bool) {
+	for i := range results {
+		var flagged []Package
+		for _, pkg := range results[i].Packages {
+			if !pkg.IsWarning || !isPrerelease(pkg.Version) {
+				continue
+			}
+			var stable []string
+			for version := range affected[pkg.Name] {
+				if !isPrerelease(version) {
+					stable = append(stable, version)
+				}
+			}
+			if len(stable) == 0 {
+				continue
+			}
+			sortAffectedVersions(stable)
+			flagged = append(flagged, Package{
+				Name:         pkg.Name,
+				Version:      pkg.Version,
+				IsSuspicious: true,
+				Notice: fmt.Sprintf("%s@%s is a prerelease of a package with compromised versions (%s), inspect it before trusting it",
+					pkg.Name, pkg.Version, strings.Join(stable, ", ")),
+			})
+		}
+		results[i].Packages = append(results[i].Packages, flagged...)
+	}
+}
+
+// sampleLockfiles picks n lockfiles at random using seed, keeping discovery order
+func sampleLockfiles(lockfiles []string, n int, seed int64) []string {
+	if n >= len(lockfiles) {
+		return lockfiles
+	}
+	picked := rand.New(rand.NewSource(seed)).Perm(len(lockfiles))[:n]
+	sort.Ints(picked)
+
+	sampled := make([]string, 0, n)
+	for _, index := range picked {
+		sampled = append(sampled, lockfiles[index])
+	}
+	return sampled
+}
+
+// summarizeResults counts the package entries, compromised packages and warnings in results
+func summarizeResults(results []Result, totalLockfiles int) Summary {
+	summary := Summary{TotalLockfiles: totalLockfiles}
+	for _, result := range results {
+		summary.TotalPackages += len(result.Packages)
+		for _, pkg := range result.Packages {
+			if pkg.IsAffected {
+				summary.TotalCompromised++
+			}
+			if pkg.IsWarning {
+				summary.TotalWarnings++
+			}
+			if pkg.IsSuspicious {
+				summary.TotalSuspicious++
+			}
+		}
+	}
+	return summary
+}
+
+// findingPriority ranks a finding for display, lower is more urgent: compromised packages
+// by descending confidence, then warnings, suspicious entries and finally notices
+func findingPriority(pkg Package) int {
+	switch {
+	case pkg.IsAffected:
+		return 3 - confidenceRank[pkg.Confidence]
+	case pkg.IsWarning:
+		return 4
+	case pkg.IsSuspicious:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// limitFindings keeps the limit most urgent findings, preserving lockfile order,
+// and returns how many were left out
+func limitFindings(results []Result, limit int) ([]Result, int) {
+	type ref struct{ result, pkg int }
+	var refs []ref
+	for i, result := range results {
+		for j := range result.Packages {
+			refs = append(refs, ref{i, j})
+		}
+	}
+	if len(refs) <= limit {
+		return results, 0
+	}
+
+	sort.SliceStable(refs, func(a, b int) bool {
+		return findingPriority(results[refs[a].result].Packages[refs[a].pkg]) < findingPriority(results[refs[b].result].Packages[refs[b].pkg])
+	})
+	kept := make(map[ref]bool)
+	for _, r := range refs[:limit] {
+		kept[r] = true
+	}
+
+	var limited []Result
+	for i, result := range results {
+		var packages []Package
+		for j, pkg := range result.Packages {
+			if kept[ref{i, j}] {
+				packages = append(packages, pkg)
+			}
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			sort.SliceStable(packages, func(a, b int) bool {
+				return findingPriority(packages[a]) < findingPriority(packages[b])
+			})
+			result.Packages = packages
+			limited = append(limited, result)
+		}
+	}
+
+	return limited, len(refs) - limit
+}
+
+// omitUnchangedLockfiles drops the findings of the unchanged lockfiles and returns how many
+// findings were left out
+func omitUnchangedLockfiles(results []Result, unchanged map[string]bool) ([]Result, int) {
+	var changed []Result
+	omitted := 0
+	for _, result := range results {
+		if unchanged[result.LockFile] {
+			omitted += len(result.Packages)
 			continue
 		}
+		changed = append(changed, result)
+	}
+	return changed, omitted
+}
+
+// capFindingsPerLockfile keeps the limit most urgent findings of each lockfile, preserving
+// lockfile order, and records in Omitted how many of a lockfile's findings were left out
+func capFindingsPerLockfile(results []Result, limit int) []Result {
+	capped := make([]Result, len(results))
+	for i, result := range results {
+		if len(result.Packages) > limit {
+			order := make([]int, len(result.Packages))
+			for j := range order {
+				order[j] = j
+			}
+			sort.SliceStable(order, func(a, b int) bool {
+				return findingPriority(result.Packages[order[a]]) < findingPriority(result.Packages[order[b]])
+			})
+			kept := order[:limit]
+			sort.Ints(kept)
+
+			packages := make([]Package, 0, limit)
+			for _, j := range kept {
+				packages = append(packages, result.Packages[j])
+			}
+			result.Omitted += len(result.Packages) - limit
+			result.Packages = packages
+		}
+		capped[i] = result
+	}
+	return capped
+}
+
+// collapseToLatest keeps, for each package, only the findings at its newest compromised
+// version and at its newest warning version, so an older compromised version is never hidden
+// behind a newer safe one. Other findings are kept. It returns how many findings were dropped
+func collapseToLatest(results []Result) ([]Result, int) {
+	newest := make(map[string]string)
+	kindKey := func(pkg Package) string {
+		if pkg.IsAffected {
+			return "affected\x00" + pkg.Name
+		}
+		return "warning\x00" + pkg.Name
+	}
+	for _, result := range results {
+		for _, pkg := range result.Packages {
+			if !pkg.IsAffected && !pkg.IsWarning {
+				continue
+			}
+			key := kindKey(pkg)
+			if current, ok := newest[key]; !ok || compareVersions(pkg.Version, current) > 0 || (compareVersions(pkg.Version, current) == 0 && pkg.Version > current) {
+				newest[key] = pkg.Version
+			}
+		}
+	}
+
+	var collapsed []Result
+	dropped := 0
+	for _, result := range results {
+		var packages []Package
+		for _, pkg := range result.Packages {
+			if (pkg.IsAffected || pkg.IsWarning) && pkg.Version != newest[kindKey(pkg)] {
+				dropped++
+				continue
+			}
+			packages = append(packages, pkg)
+		}
+		if len(packages) > 0 || len(result.Notices) > 0 {
+			result.Packages = packages
+			collapsed = append(collapsed, result)
+		}
+	}
+	return collapsed, dropped
+}
+
+// sortAffectedVersions orders versions semantically, falling back to lexical order so
+// output is reproducible when versions compare equal, e.g. differing only in pre-release
+func sortAffectedVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		if cmp := compareVersions(versions[i], versions[j]); cmp != 0 {
+			return cmp < 0
+		}
+		return versions[i] < versions[j]
+	})
+}
+
+// listedVersion returns the entry of a package's listed versions that version matches: the
+// version itself, or else the first range, in sorted order, that it satisfies under the
+// comparator's rules; for npm semver a pre-release only matches a range naming a pre-release
+// of the same x.y.z
+func listedVersion(comparator VersionComparator, versions map[string]bool, version string) (string, bool) {
+	normalized := normalizeVersion(version)
+	if versions[normalized] {
+		return normalized, true
+	}
+	var ranges []string
+	for entry := range versions {
+		if _, exact := parseSemver(entry); !exact && !exactVersionRegex.MatchString(entry) {
+			ranges = append(ranges, entry)
+		}
+	}
+	sort.Strings(ranges)
+	for _, rng := range ranges {
+		if comparator.Satisfies(normalized, rng) {
+			return rng, true
+		}
+	}
+	return "", false
+}
+
+// checkPackage checks a single name@version against the affected packages
+func checkPackage(comparator VersionComparator, name, version string, affected map[string]map[string]bool) (Package, bool) {
+	return evaluatePackage(comparator, name, version, "", "", affected, nil)
+}
+
+// evaluatePackage checks name@version against the affected packages under the comparator's
+// version semantics, grading confidence against the list's known-bad integrities. An entry
+// whose integrity is in knownBad is compromised whatever version it claims
+func evaluatePackage(comparator VersionComparator, name, version, integrity, resolved string, affected map[string]map[string]bool, knownBad map[string]string) (Package, bool) {
+	normalized := normalizeIntegrity(integrity, resolved)
+	if compromised, ok := knownBad[normalized]; ok && normalized != "" {
+		return knownBadIntegrityPackage(name, version, normalized, compromised, affected), true
+	}
+
+	affectedVersions, exists := affected[name]
+	if !exists {
+		return Package{}, false
+	}
+
+	_, isAffected := listedVersion(comparator, affectedVersions, version)
+	isWarning := !isAffected && len(affectedVersions) > 0
+	if !isAffected && !isWarning {
+		return Package{}, false
+	}
+
+	var affectedVers []string
+	for v := range affectedVersions {
+		affectedVers = append(affectedVers, v)
+	}
+	sortAffectedVersions(affectedVers)
+
+	return Package{
+		Name:             name,
+		Version:          version,
+		IsAffected:       isAffected,
+		IsWarning:        isWarning,
+		AffectedVersions: affectedVers,
+		Confidence:       matchConfidence(isAffected, normalized, knownBad),
+		Integrity:        integrity,
+	}, true
+}
+
+// extractPackageNameFromYarnHeader extracts package name from yarn.lock header
+func extractPackageNameFromYarnHeader(header string) string {
+	// Handle patterns like: @scope/package@^1.0.0, @scope/package@^2.0.0
+	parts := strings.Split(header, ",")
+	if len(parts) == 0 {
+		return ""
+	}
+
+	// Take first part and extract package name
+	firstPart := strings.TrimSpace(parts[0])
+	atIndex := strings.LastIndex(firstPart, "@")
+	if atIndex == -1 {
+		return ""
+	}
+
+	name := firstPart[:atIndex]
+	// Normalize scoped packages
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
+		name = "@" + name
+	}
+
+	return name
+}
+
+// supportedNPMLockfileVersions lists the npm lockfileVersion values the parser understands
+var supportedNPMLockfileVersions = map[string]bool{"1": true, "2": true, "3": true}
+
+// lockfileContext captures contextLines lines either side of lines[index], or nil when
+// context is off
+func lockfileContext(lines []string, index, contextLines int) *LockfileContext {
+	if contextLines <= 0 {
+		return nil
+	}
+	start := max(index-contextLines, 0)
+	end := min(index+contextLines+1, len(lines))
+	context := &LockfileContext{Line: index + 1, StartLine: start + 1}
+	for _, line := range lines[start:end] {
+		context.Lines = append(context.Lines, strings.TrimRight(line, "\r"))
+	}
+	return context
+}
+
+// parseNPMLock parses package-lock.json or npm-shrinkwrap.json
+func parseNPMLock(lockfile string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	comparator := versionComparatorFor("npm")
+	var packages []Package
+	var notices []string
+	hasAffected := false
+	hasWarnings := false
+
+	content, err := readLockfile(lockfile)
+	if err != nil {
+		return packages, notices, hasAffected, hasWarnings
+	}
+	stats.fileParsed()
+
+	var lockfileData map[string]interface{}
+	if err := json.Unmarshal(content, &lockfileData); err != nil {
+		return packages, notices, hasAffected, hasWarnings
+	}
+
+	// Surface lockfile versions we don't know how to parse completely
+	if rawVersion, ok := lockfileData["lockfileVersion"]; ok {
+		lockfileVersion := fmt.Sprintf("%v", rawVersion)
+		if !supportedNPMLockfileVersions[lockfileVersion] {
+			notices = append(notices, fmt.Sprintf("unsupported lockfileVersion %s, this file may be incompletely scanned", lockfileVersion))
+		}
+	}
+
+	// Parse packages section
+	if packagesData, ok := lockfileData["packages"].(map[string]interface{}); ok {
+		siblingsUseSHA512 := false
+		for _, pkgData := range packagesData {
+			if pkg, ok := pkgData.(map[string]interface{}); ok {
+				if integrity, _ := pkg["integrity"].(string); strings.Contains(integrity, "sha512-") {
+					siblingsUseSHA512 = true
+					break
+				}
+			}
+		}
+
+		for key, pkgData := range packagesData {
+			if pkg, ok := pkgData.(map[string]interface{}); ok {
+				if key == "" {
+					// The root is the project itself, unless it is a published package under audit
+					if opts.includeRoot {
+						if name, version := npmRootPackage(lockfile, pkg); name != "" && version != "" {
+							stats.packageEnumerated(name, version)
+							stats.mapLookup()
+							opts.trace.record(lockfile, name, version, affected)
+							if finding, ok := checkPackage(comparator, name, version, affected); ok {
+								packages = append(packages, finding)
+								hasAffected = hasAffected || finding.IsAffected
+								hasWarnings = hasWarnings || finding.IsWarning
+							}
+						}
+					}
+					continue
+				}
+
+				// Extract package name from path
+				name := extractPackageNameFromPath(key)
+				if name == "" {
+					continue
+				}
+				// An aliased install is checked, and correlated across lockfiles, as the real package
+				alias := ""
+				if real, _ := pkg["name"].(string); real != "" && real != name && strings.Contains("/"+key, "/node_modules/") {
+					alias, name = name, real
+				}
+
+				// Local overrides sharing a compromised package's name need a human to verify them
+				resolved, _ := pkg["resolved"].(string)
+				if link, _ := pkg["link"].(bool); affected[name] != nil {
+					if link {
+						notices = append(notices, localOverrideNotice(name, "link:"+resolved))
+					} else if strings.HasPrefix(resolved, "file:") {
+						notices = append(notices, localOverrideNotice(name, resolved))
+					}
+				}
 
-		// Parse package@version
-		re := regexp.MustCompile(`^(@?[^@/\s]+(?:/[^@/\s]+)?)@([0-9]+\.[0-9]+\.[0-9]+(?:\.[0-9]+)?)$`)
-		matches := re.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			name := matches[1]
-			version := matches[2]
+				// Some git or aliased installs omit version but encode it in the tarball URL
+				version, ok := pkg["version"].(string)
+				if !ok {
+					version = versionFromResolved(name, resolved)
+					ok = version != ""
+				}
 
-			// Normalize scoped packages
-			if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
-				name = "@" + name
+				if ok {
+					integrity, _ := pkg["integrity"].(string)
+					if reason := integrityDowngradeReason(integrity, resolved, siblingsUseSHA512); reason != "" {
+						packages = append(packages, suspiciousPackage(name, version, reason))
+					}
+					if reason := resolvedNameMismatchReason(name, resolved); reason != "" {
+						packages = append(packages, suspiciousPackage(name, version, reason))
+					}
+
+					stats.packageEnumerated(name, version)
+					stats.mapLookup()
+					opts.trace.record(lockfile, name, version, affected)
+					if finding, ok := opts.verdicts.check(comparator, name, version, integrity, resolved, affected, opts.knownBad); ok {
+						finding.Integrity = normalizeIntegrity(integrity, resolved)
+						finding.Alias = alias
+						finding.Optional, _ = pkg["optional"].(bool)
+						finding.Peer, _ = pkg["peer"].(bool)
+						packages = append(packages, finding)
+
+						if finding.IsAffected {
+							hasAffected = true
+						}
+						if finding.IsWarning {
+							hasWarnings = true
+						}
+					}
+				}
 			}
+		}
 
-			if affected[name] == nil {
-				affected[name] = make(map[string]bool)
+		// Bundled dependencies may be pinned in an entry without a packages key of their own
+		checked := make(map[string]bool)
+		for key, pkgData := range packagesData {
+			pkg, ok := pkgData.(map[string]interface{})
+			if !ok || key == "" {
+				continue
+			}
+			for name, version := range npmBundledDependencies(key, pkg, packagesData) {
+				if checked[name+"@"+version] {
+					continue
+				}
+				checked[name+"@"+version] = true
+				stats.packageEnumerated(name, version)
+				stats.mapLookup()
+				opts.trace.record(lockfile, name, version, affected)
+				if finding, ok := checkPackage(comparator, name, version, affected); ok {
+					packages = append(packages, finding)
+					hasAffected = hasAffected || finding.IsAffected
+					hasWarnings = hasWarnings || finding.IsWarning
+				}
 			}
-			affected[name][version] = true
 		}
+	} else if dependenciesData, ok := lockfileData["dependencies"].(map[string]interface{}); ok {
+		// lockfileVersion 1 nests each package's own copies of its dependencies under it
+		checked := make(map[string]bool)
+		walkNPMDependencies(dependenciesData, make(map[string]bool), func(name string, dep map[string]interface{}) {
+			version, _ := dep["version"].(string)
+			resolved, _ := dep["resolved"].(string)
+			if strings.HasPrefix(version, "file:") {
+				if affected[name] != nil {
+					notices = append(notices, localOverrideNotice(name, version))
+				}
+				return
+			}
+			if version == "" || checked[name+"@"+version] {
+				return
+			}
+			checked[name+"@"+version] = true
+
+			integrity, _ := dep["integrity"].(string)
+			stats.packageEnumerated(name, version)
+			stats.mapLookup()
+			opts.trace.record(lockfile, name, version, affected)
+			if finding, ok := opts.verdicts.check(comparator, name, version, integrity, resolved, affected, opts.knownBad); ok {
+				finding.Integrity = normalizeIntegrity(integrity, resolved)
+				finding.Optional, _ = dep["optional"].(bool)
+				packages = append(packages, finding)
+				hasAffected = hasAffected || finding.IsAffected
+				hasWarnings = hasWarnings || finding.IsWarning
+			}
+		})
 	}
 
-	return affected, scanner.Err()
+	return packages, notices, hasAffected, hasWarnings
 }
 
-// loadEmbeddedExploitedPackages loads the embedded exploited packages list
-func loadEmbeddedExploitedPackages() (map[string]map[string]bool, error) {
-	affected := make(map[string]map[string]bool)
-	scanner := bufio.NewScanner(strings.NewReader(embeddedExploitedPackages))
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+// walkNPMDependencies visits every entry of a lockfileVersion 1 dependencies tree. ancestors
+// holds the name@version of each entry on the current path; an entry repeating one of them
+// is a cycle and is neither visited again nor descended into, so adversarial trees terminate
+func walkNPMDependencies(dependencies map[string]interface{}, ancestors map[string]bool, visit func(name string, dep map[string]interface{})) {
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		// Skip comments and empty lines
-		if line == "" || strings.HasPrefix(line, "#") {
+	for _, name := range names {
+		dep, ok := dependencies[name].(map[string]interface{})
+		if !ok {
 			continue
 		}
+		version, _ := dep["version"].(string)
+		key := name + "@" + version
+		if ancestors[key] {
+			continue
+		}
+		visit(name, dep)
 
-		// Parse package@version
-		re := regexp.MustCompile(`^(@?[^@/\s]+(?:/[^@/\s]+)?)@([0-9]+\.[0-9]+\.[0-9]+(?:\.[0-9]+)?)$`)
-		matches := re.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			name := matches[1]
-			version := matches[2]
-
-			// Normalize scoped packages
-			if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
-				name = "@" + name
-			}
-
-			if affected[name] == nil {
-				affected[name] = make(map[string]bool)
-			}
-			affected[name][version] = true
+		if children, ok := dep["dependencies"].(map[string]interface{}); ok {
+			ancestors[key] = true
+			walkNPMDependencies(children, ancestors, visit)
+			delete(ancestors, key)
 		}
 	}
-
-	return affected, scanner.Err()
 }
 
-// findLockfiles finds all relevant lockfiles for the specified managers
-func findLockfiles(rootDir string, managers, include, exclude []string) ([]string, error) {
-	var lockfiles []string
-	var patterns []string
-
-	// Build patterns based on managers
-	for _, manager := range managers {
-		switch manager {
-		case "yarn":
-			patterns = append(patterns, "yarn.lock")
-		case "npm":
-			patterns = append(patterns, "package-lock.json", "npm-shrinkwrap.json")
-		case "pnpm":
-			patterns = append(patterns, "pnpm-lock.yaml")
-		case "bun":
-			patterns = append(patterns, "bun.lock", "bun.lockb")
+// npmRootPackage returns the name and version of a lockfile's root package, preferring the
+// sibling package.json over the root entry since older npm versions omit them there
+func npmRootPackage(lockfile string, root map[string]interface{}) (string, string) {
+	name, _ := root["name"].(string)
+	version, _ := root["version"].(string)
+	if content, err := readLockfile(filepath.Join(filepath.Dir(lockfile), "package.json")); err == nil {
+		var manifest struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(content, &manifest) == nil && manifest.Name != "" {
+			name, version = manifest.Name, manifest.Version
 		}
 	}
+	return name, version
+}
 
-	// Find all files matching patterns
-	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Skip inaccessible files
+// versionFromResolved derives a version from a registry tarball URL like .../name/-/name-1.2.3.tgz
+func versionFromResolved(name, resolved string) string {
+	baseName := name[strings.LastIndex(name, "/")+1:]
+	marker := "/-/" + baseName + "-"
+	index := strings.LastIndex(resolved, marker)
+	if index == -1 {
+		return ""
+	}
+	version := strings.SplitN(resolved[index+len(marker):], "?", 2)[0]
+	version = strings.SplitN(version, "#", 2)[0]
+	if !strings.HasSuffix(version, ".tgz") {
+		return ""
+	}
+	return strings.TrimSuffix(version, ".tgz")
+}
+
+// npmBundledDependencies returns the exact-pinned dependencies and bundleDependencies of a
+// packages entry that node resolution cannot find under any packages key
+func npmBundledDependencies(key string, pkg map[string]interface{}, packagesData map[string]interface{}) map[string]string {
+	dependencies, _ := pkg["dependencies"].(map[string]interface{})
+	names := make(map[string]bool)
+	for name := range dependencies {
+		names[name] = true
+	}
+	if bundled, ok := pkg["bundleDependencies"].([]interface{}); ok {
+		for _, name := range bundled {
+			if name, ok := name.(string); ok {
+				names[name] = true
+			}
 		}
+	}
 
-		if d.IsDir() {
-			return nil
+	found := make(map[string]string)
+	for name := range names {
+		version, _ := dependencies[name].(string)
+		if !exactVersionRegex.MatchString(version) {
+			continue
 		}
 
-		// Check if file matches any pattern
-		for _, pattern := range patterns {
-			if d.Name() == pattern {
-				// Check include/exclude filters
-				if shouldIncludePath(path, rootDir, include, exclude) {
-					lockfiles = append(lockfiles, path)
-				}
+		// Walk up from the entry the way node resolves modules
+		resolved := false
+		for dir := key; ; {
+			if _, exists := packagesData[dir+"/node_modules/"+name]; exists {
+				resolved = true
+				break
+			}
+			index := strings.LastIndex(dir, "/node_modules/")
+			if index == -1 {
 				break
 			}
+			dir = dir[:index]
 		}
+		if _, exists := packagesData["node_modules/"+name]; exists || resolved {
+			continue
+		}
+		found[name] = version
+	}
 
-		return nil
-	})
-
-	return lockfiles, err
+	return found
 }
 
-// shouldIncludePath checks if a path should be included based on include/exclude patterns
-func shouldIncludePath(fullPath, rootDir string, include, exclude []string) bool {
-	// Get relative path from root
-	relPath, err := filepath.Rel(rootDir, fullPath)
-	if err != nil {
-		return false
+// extractPackageNameFromPath extracts package name from node_modules path
+func extractPackageNameFromPath(path string) string {
+	// Handle patterns like: node_modules/@scope/package, node_modules/package
+	cleanPath := strings.TrimPrefix(path, "/")
+	cleanPath = strings.TrimPrefix(cleanPath, "node_modules/")
+	if cleanPath == "" {
+		return ""
 	}
 
-	// Normalize path separators
-	relPath = filepath.ToSlash(relPath)
-
-	// Check exclude patterns first
-	for _, pattern := range exclude {
-		if matchesGlobPattern(relPath, pattern) {
-			return false
-		}
+	// Normalize scoped packages
+	if strings.Contains(cleanPath, "/") && !strings.HasPrefix(cleanPath, "@") {
+		cleanPath = "@" + cleanPath
 	}
 
-	// If include patterns specified, path must match at least one
-	if len(include) > 0 {
-		for _, pattern := range include {
-			if matchesGlobPattern(relPath, pattern) {
-				return true
-			}
-		}
-		return false
-	}
+	return cleanPath
+}
 
-	return true
+// pnpmLockfile is the part of a pnpm-lock.yaml the scanner reads. Packages stays a node so
+// each entry keeps its line for --context-lines
+type pnpmLockfile struct {
+	Packages yaml.Node `yaml:"packages"`
 }
 
-// matchesPattern checks if a path matches a glob pattern
-func matchesPattern(path, pattern string) bool {
-	// Convert glob to regex
-	regex := "^" + regexp.QuoteMeta(pattern)
-	regex = strings.ReplaceAll(regex, "\\*\\*", ".*")
-	regex = strings.ReplaceAll(regex, "\\*", "[^/]*")
-	regex += "$"
+// pnpmPackageEntry is the value of one packages entry. Name and version are only recorded for
+// packages not resolved from the registry, whose key is not name@version
+type pnpmPackageEntry struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Resolution struct {
+		Integrity string `yaml:"integrity"`
+	} `yaml:"resolution"`
+}
 
-	matched, _ := regexp.MatchString(regex, path)
-	return matched
+// pnpmLockEntry is one resolved package of a pnpm lockfile
+type pnpmLockEntry struct {
+	name      string
+	version   string
+	integrity string
+	line      int // index of the key line
 }
 
-// Improved glob matching function
-func matchesGlobPattern(path, pattern string) bool {
-	// Handle common glob patterns more accurately
-	if pattern == "**/node_modules/**" {
-		return strings.Contains(path, "node_modules/")
-	}
-	if strings.HasSuffix(pattern, "/**") {
-		prefix := strings.TrimSuffix(pattern, "/**")
-		return strings.HasPrefix(path, prefix+"/") || path == strings.TrimSuffix(prefix, "/")
+// pnpmYAMLEntries decodes the packages section of a pnpm lockfile. It understands the
+// /name@version and /name/version keys of v5 and v6 and the unprefixed name@version keys of
+// v9, which moved peer-resolved copies into a snapshots section that names no new versions
+func pnpmYAMLEntries(content []byte) ([]pnpmLockEntry, error) {
+	var lock pnpmLockfile
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return nil, err
 	}
-	if strings.HasPrefix(pattern, "**/") {
-		suffix := strings.TrimPrefix(pattern, "**/")
-		return strings.Contains(path, "/"+suffix) || strings.HasPrefix(path, suffix)
+	if lock.Packages.Kind != yaml.MappingNode {
+		return nil, nil
 	}
 
-	// Fallback to simple regex conversion
-	regex := "^" + regexp.QuoteMeta(pattern)
-	regex = strings.ReplaceAll(regex, "\\*\\*", ".*")
-	regex = strings.ReplaceAll(regex, "\\*", "[^/]*")
-	regex += "$"
-
-	matched, _ := regexp.MatchString(regex, path)
-	return matched
-}
-
-// scanLockfiles scans all found lockfiles
-func scanLockfiles(lockfiles []string, affected map[string]map[string]bool) ([]Result, bool, bool) {
-	var results []Result
-	anyAffected := false
-	anyWarnings := false
-
-	for _, lockfile := range lockfiles {
-		packages, hasAffected, hasWarnings := scanLockfile(lockfile, affected)
-
-		if len(packages) > 0 {
-			results = append(results, Result{
-				LockFile: lockfile,
-				Packages: packages,
-			})
+	var entries []pnpmLockEntry
+	nodes := lock.Packages.Content
+	for i := 0; i+1 < len(nodes); i += 2 {
+		key, value := nodes[i], nodes[i+1]
+		var entry pnpmPackageEntry
+		if err := value.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", value.Line, err)
 		}
 
-		if hasAffected {
-			anyAffected = true
+		name, version := parsePnpmPackageKey(key.Value)
+		if entry.Name != "" && entry.Version != "" {
+			name, version = entry.Name, entry.Version
 		}
-		if hasWarnings {
-			anyWarnings = true
+		version = normalizeVersion(version)
+		// Local and tarball dependencies carry no registry version to check
+		if name == "" || version == "" || version[0] < '0' || version[0] > '9' {
+			continue
 		}
+		entries = append(entries, pnpmLockEntry{
+			name:      name,
+			version:   version,
+			integrity: entry.Resolution.Integrity,
+			line:      key.Line - 1,
+		})
 	}
-
-	return results, anyAffected, anyWarnings
+	return entries, nil
 }
 
-// scanLockfile scans a single lockfile
-func scanLockfile(lockfile string, affected map[string]map[string]bool) ([]Package, bool, bool) {
-	var packages []Package
-	hasAffected := false
-	hasWarnings := false
-
-	// Determine file type and parse accordingly
-	baseName := filepath.Base(lockfile)
-
-	switch {
-	case baseName == "yarn.lock":
-		pkgs, affected, warnings := parseYarnLock(lockfile, affected)
-		packages = append(packages, pkgs...)
-		if affected { hasAffected = true }
-		if warnings { hasWarnings = true }
-
-	case baseName == "package-lock.json" || baseName == "npm-shrinkwrap.json":
-		pkgs, affected, warnings := parseNPMLock(lockfile, affected)
-		packages = append(packages, pkgs...)
-		if affected { hasAffected = true }
-		if warnings { hasWarnings = true }
-
-	case baseName == "pnpm-lock.yaml":
-		pkgs, affected, warnings := parsePNMLock(lockfile, affected)
-		packages = append(packages, pkgs...)
-		if affected { hasAffected = true }
-		if warnings { hasWarnings = true }
+// pnpmLineEntries finds package entries line by line, for lockfiles that are not valid YAML
+func pnpmLineEntries(lines []string) []pnpmLockEntry {
+	var entries []pnpmLockEntry
+	for index, line := range lines {
+		line = strings.TrimSpace(line)
 
-	case baseName == "bun.lock" || baseName == "bun.lockb":
-		// For now, skip binary bun.lockb files
-		if baseName == "bun.lockb" {
-			return packages, hasAffected, hasWarnings
+		// Look for package entries like: /package-name@version: or legacy /package-name/version:
+		if strings.HasPrefix(line, "/") && strings.HasSuffix(line, ":") {
+			name, version := parsePnpmPackageKey(strings.TrimSuffix(line, ":"))
+			if name == "" {
+				continue
+			}
+			entries = append(entries, pnpmLockEntry{
+				name:      name,
+				version:   version,
+				integrity: pnpmEntryIntegrity(lines, index),
+				line:      index,
+			})
 		}
-		pkgs, affected, warnings := parseBunLock(lockfile, affected)
-		packages = append(packages, pkgs...)
-		if affected { hasAffected = true }
-		if warnings { hasWarnings = true }
 	}
-
-	return packages, hasAffected, hasWarnings
+	return entries
 }
 
-// parseYarnLock parses a yarn.lock file
-func parseYarnLock(lockfile string, affected map[string]map[string]bool) ([]Package, bool, bool) {
+// parsePNMLock parses pnpm-lock.yaml and the legacy shrinkwrap.yaml
+func parsePNMLock(lockfile string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	comparator := versionComparatorFor("pnpm")
 	var packages []Package
+	var notices []string
 	hasAffected := false
 	hasWarnings := false
 
-	content, err := os.ReadFile(lockfile)
+	content, err := readLockfile(lockfile)
 	if err != nil {
-		return packages, hasAffected, hasWarnings
+		return packages, notices, hasAffected, hasWarnings
 	}
-
+	stats.fileParsed()
 	lines := strings.Split(string(content), "\n")
-	foundPackages := make(map[string]string) // name -> version
-
-	i := 0
-	for i < len(lines) {
-		line := strings.TrimSpace(lines[i])
-
-		// Look for package header lines
-		if strings.Contains(line, "@") && strings.Contains(line, ":") {
-			// Extract package name from header
-			header := strings.Trim(line, `":`)
-			name := extractPackageNameFromYarnHeader(header)
-			if name == "" {
-				i++
-				continue
-			}
-
-			// Find version in the following lines
-			version := ""
-			j := i + 1
-			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "\"") {
-				verLine := strings.TrimSpace(lines[j])
-				if strings.HasPrefix(verLine, "version") {
-					version = strings.Trim(strings.TrimPrefix(verLine, "version"), ` "`)
-					break
-				}
-				j++
-			}
 
-			if version != "" {
-				foundPackages[name] = version
-			}
+	// Keep scanning malformed files line by line, but tell the user the result may be incomplete.
+	// The structural check only explains why decoding failed, it never rejects a file that decodes
+	entries, err := pnpmYAMLEntries(content)
+	if err != nil {
+		if diagnostic := validatePnpmYAML(string(content)); diagnostic != nil {
+			err = diagnostic
 		}
-		i++
+		entries = pnpmLineEntries(lines)
+		notice := fmt.Sprintf("malformed YAML (%v), fell back to best-effort line scanning", err)
+		// The line scan only knows /-prefixed keys, so a malformed v9 lockfile yields nothing
+		if len(entries) == 0 {
+			notice = fmt.Sprintf("malformed YAML (%v), no package entries could be read, this file was not scanned", err)
+		}
+		notices = append(notices, notice)
 	}
 
-	// Check against affected packages
-	for name, version := range foundPackages {
-		if affectedVersions, exists := affected[name]; exists {
-			isAffected := affectedVersions[version]
-			isWarning := !isAffected && len(affectedVersions) > 0
+	for _, entry := range entries {
+		name, version := entry.name, entry.version
 
-			if isAffected || isWarning {
-				var affectedVers []string
-				for v := range affectedVersions {
-					affectedVers = append(affectedVers, v)
-				}
+		stats.packageEnumerated(name, version)
+		stats.mapLookup()
+		opts.trace.record(lockfile, name, version, affected)
+		pkg, ok := evaluatePackage(comparator, name, version, normalizeIntegrity(entry.integrity, ""), "", affected, opts.knownBad)
+		if !ok {
+			continue
+		}
+		pkg.Context = lockfileContext(lines, entry.line, opts.contextLines)
+		packages = append(packages, pkg)
+		hasAffected = hasAffected || pkg.IsAffected
+		hasWarnings = hasWarnings || pkg.IsWarning
+	}
 
-				packages = append(packages, Package{
-					Name:             name,
-					Version:          version,
-					IsAffected:       isAffected,
-					IsWarning:        isWarning,
-					AffectedVersions: affectedVers,
-				})
+	return packages, notices, hasAffected, hasWarnings
+}
 
-				if isAffected {
-					hasAffected = true
-				}
-				if isWarning {
-					hasWarnings = true
+// pnpmEntryIntegrity returns the resolution integrity of the pnpm packages entry whose key is
+// lines[index], in either the flow form `resolution: {integrity: sha512-...}` or the block form
+func pnpmEntryIntegrity(lines []string, index int) string {
+	indent := func(line string) int {
+		return len(line) - len(strings.TrimLeft(line, " "))
+	}
+	keyIndent := indent(lines[index])
+	inResolution := false
+	for _, line := range lines[index+1:] {
+		field := strings.TrimSpace(line)
+		if field == "" {
+			continue
+		}
+		if indent(line) <= keyIndent {
+			break
+		}
+		switch {
+		case strings.HasPrefix(field, "resolution:"):
+			value := strings.Trim(strings.TrimSpace(strings.TrimPrefix(field, "resolution:")), "{}")
+			for _, pair := range strings.Split(value, ",") {
+				if key, integrity, found := strings.Cut(pair, ":"); found && strings.TrimSpace(key) == "integrity" {
+					return strings.Trim(strings.TrimSpace(integrity), `"'`)
 				}
 			}
+			inResolution = value == ""
+		case inResolution && strings.HasPrefix(field, "integrity:"):
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(field, "integrity:")), `"'`)
 		}
 	}
-
-	return packages, hasAffected, hasWarnings
+	return ""
 }
 
-// extractPackageNameFromYarnHeader extracts package name from yarn.lock header
-func extractPackageNameFromYarnHeader(header string) string {
-	// Handle patterns like: @scope/package@^1.0.0, @scope/package@^2.0.0
-	parts := strings.Split(header, ",")
-	if len(parts) == 0 {
-		return ""
-	}
-
-	// Take first part and extract package name
-	firstPart := strings.TrimSpace(parts[0])
-	atIndex := strings.LastIndex(firstPart, "@")
-	if atIndex == -1 {
-		return ""
+// parsePnpmPackageKey splits a pnpm packages key into name and version. Lockfile v9 uses
+// name@version and v6 /name@version, either with an optional (peer@version) suffix, while
+// shrinkwrap.yaml and older lockfiles use /name/version with an optional _peer suffix
+func parsePnpmPackageKey(key string) (string, string) {
+	entry := strings.TrimPrefix(key, "/")
+	entry, _, _ = strings.Cut(entry, "(")
+
+	var name, version string
+	slashIndex := strings.LastIndex(entry, "/")
+	if slashIndex > 0 && slashIndex+1 < len(entry) && entry[slashIndex+1] >= '0' && entry[slashIndex+1] <= '9' &&
+		!strings.Contains(entry[1:slashIndex], "@") {
+		name = entry[:slashIndex]
+		version = strings.SplitN(entry[slashIndex+1:], "_", 2)[0]
+	} else {
+		atIndex := strings.LastIndex(entry, "@")
+		if atIndex <= 0 {
+			return "", ""
+		}
+		name = entry[:atIndex]
+		version = entry[atIndex+1:]
 	}
 
-	name := firstPart[:atIndex]
 	// Normalize scoped packages
 	if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
 		name = "@" + name
 	}
 
-	return name
+	return name, version
 }
 
-// parseNPMLock parses package-lock.json or npm-shrinkwrap.json
-func parseNPMLock(lockfile string, affected map[string]map[string]bool) ([]Package, bool, bool) {
+// validatePnpmYAML performs a structural sanity check of a pnpm lockfile
+func validatePnpmYAML(content string) error {
+	if !strings.Contains(content, "lockfileVersion:") && !strings.Contains(content, "shrinkwrapVersion:") {
+		return fmt.Errorf("missing lockfileVersion")
+	}
+
+	depth := 0
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if strings.Contains(indent, "\t") {
+			return fmt.Errorf("line %d: tab used for indentation", i+1)
+		}
+		if strings.Count(trimmed, "'")%2 != 0 {
+			return fmt.Errorf("line %d: unterminated quoted string", i+1)
+		}
+
+		// Flow mappings and sequences like {integrity: ...} must close on the same line
+		depth += strings.Count(trimmed, "{") + strings.Count(trimmed, "[")
+		depth -= strings.Count(trimmed, "}") + strings.Count(trimmed, "]")
+		if depth != 0 {
+			return fmt.Errorf("line %d: unbalanced flow collection", i+1)
+		}
+	}
+
+	return nil
+}
+
+// parseBunLock parses bun.lock
+func parseBunLock(lockfile string, affected map[string]map[string]bool, opts scanOptions, stats *scanStats) ([]Package, []string, bool, bool) {
+	comparator := versionComparatorFor("bun")
 	var packages []Package
+	var notices []string
 	hasAffected := false
 	hasWarnings := false
 
-	content, err := os.ReadFile(lockfile)
+	content, err := readLockfile(lockfile)
 	if err != nil {
-		return packages, hasAffected, hasWarnings
+		return packages, notices, hasAffected, hasWarnings
 	}
+	stats.fileParsed()
 
+	// bun.lock is JSONC: bun writes trailing commas
 	var lockfileData map[string]interface{}
-	if err := json.Unmarshal(content, &lockfileData); err != nil {
-		return packages, hasAffected, hasWarnings
+	if err := json.Unmarshal(stripJSONC(content), &lockfileData); err != nil {
+		notices = append(notices, fmt.Sprintf("%s (%v), this file was not scanned", bunUnparseableReason, err))
+		return packages, notices, hasAffected, true
 	}
 
-	// Parse packages section
-	if packagesData, ok := lockfileData["packages"].(map[string]interface{}); ok {
-		for key, pkgData := range packagesData {
-			if pkg, ok := pkgData.(map[string]interface{}); ok {
-				if key == "" {
-					continue // Skip root package
-				}
-
-				// Extract package name from path
-				name := extractPackageNameFromPath(key)
-				if name == "" {
-					continue
-				}
+	packagesData, _ := lockfileData["packages"].(map[string]interface{})
 
-				if version, ok := pkg["version"].(string); ok {
-					if affectedVersions, exists := affected[name]; exists {
-						isAffected := affectedVersions[version]
-						isWarning := !isAffected && len(affectedVersions) > 0
+	record := func(name, version, integrity, alias, workspace string) {
+		stats.packageEnumerated(name, version)
+		stats.mapLookup()
+		opts.trace.record(lockfile, name, version, affected)
+		pkg, ok := evaluatePackage(comparator, name, version, normalizeIntegrity(integrity, ""), "", affected, opts.knownBad)
+		if !ok {
+			return
+		}
+		pkg.Alias = alias
+		pkg.Workspace = workspace
+		packages = append(packages, pkg)
+		hasAffected = hasAffected || pkg.IsAffected
+		hasWarnings = hasWarnings || pkg.IsWarning
+	}
 
-						if isAffected || isWarning {
-							var affectedVers []string
-							for v := range affectedVersions {
-								affectedVers = append(affectedVers, v)
-							}
+	// Parse workspace member dependencies, attributing findings to the member
+	seen := make(map[string]bool)
+	if workspacesData, ok := lockfileData["workspaces"].(map[string]interface{}); ok {
+		for member, memberData := range workspacesData {
+			memberInfo, ok := memberData.(map[string]interface{})
+			if !ok {
+				continue
+			}
 
-							packages = append(packages, Package{
-								Name:             name,
-								Version:          version,
-								IsAffected:       isAffected,
-								IsWarning:        isWarning,
-								AffectedVersions: affectedVers,
-							})
+			workspace := member
+			if workspace == "" {
+				workspace = "."
+			}
 
-							if isAffected {
-								hasAffected = true
-							}
-							if isWarning {
-								hasWarnings = true
-							}
-						}
+			for _, field := range []string{"dependencies", "devDependencies", "optionalDependencies", "peerDependencies"} {
+				deps, _ := memberInfo[field].(map[string]interface{})
+				for name, specData := range deps {
+					spec, _ := specData.(string)
+					real, version, integrity := decodeBunEntry(name, packagesData[name])
+					if version == "" {
+						real, version = name, resolveBunVersion(packagesData, name, spec)
+					}
+					if version == "" {
+						continue
 					}
+					alias := ""
+					if real != name {
+						alias = name
+					}
+
+					seen[real+"@"+version] = true
+					record(real, version, integrity, alias, workspace)
+				}
+			}
+		}
+	}
+
+	// Parse packages section, which holds the transitive dependencies too
+	for key, entry := range packagesData {
+		if key == "" {
+			// The root is the project itself, unless it is a published package under audit
+			if root, ok := entry.(map[string]interface{}); ok && opts.includeRoot {
+				if name, version := npmRootPackage(lockfile, root); name != "" && version != "" {
+					record(name, version, "", "", "")
 				}
 			}
+			continue
+		}
+
+		name, version, integrity := decodeBunEntry(key, entry)
+		if version == "" {
+			continue
+		}
+		// Already attributed to a workspace member
+		if seen[name+"@"+version] {
+			continue
 		}
+		seen[name+"@"+version] = true
+		record(name, version, integrity, "", "")
 	}
 
-	return packages, hasAffected, hasWarnings
+	return packages, notices, hasAffected, hasWarnings
 }
 
-// extractPackageNameFromPath extracts package name from node_modules path
-func extractPackageNameFromPath(path string) string {
-	// Handle patterns like: node_modules/@scope/package, node_modules/package
-	cleanPath := strings.TrimPrefix(path, "/")
-	cleanPath = strings.TrimPrefix(cleanPath, "node_modules/")
-	if cleanPath == "" {
-		return ""
+// parseJSONIndent converts a --json-indent value (a number of spaces or "tab") into an indent string
+func parseJSONIndent(s string) (string, error) {
+	if s == "tab" {
+		return "\t", nil
 	}
 
-	// Normalize scoped packages
-	if strings.Contains(cleanPath, "/") && !strings.HasPrefix(cleanPath, "@") {
-		cleanPath = "@" + cleanPath
+	spaces, err := strconv.Atoi(s)
+	if err != nil || spaces < 0 || spaces > 16 {
+		return "", fmt.Errorf("invalid JSON indent '%s'. Use a number of spaces (0-16) or 'tab'", s)
 	}
+	return strings.Repeat(" ", spaces), nil
+}
 
-	return cleanPath
+// writeYAML writes the scan result as YAML with the same field names as the JSON output
+func writeYAML(w io.Writer, result ScanResult) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	return encoder.Close()
 }
 
-// parsePNMLock parses pnpm-lock.yaml
-func parsePNMLock(lockfile string, affected map[string]map[string]bool) ([]Package, bool, bool) {
-	var packages []Package
-	hasAffected := false
-	hasWarnings := false
+// writeJSONReports writes the full report and the summary-only report to their paths, if set
+func writeJSONReports(result ScanResult, jsonPath, summaryJSONPath, indent string) error {
+	if jsonPath != "" {
+		jsonOutput, err := json.MarshalIndent(result, "", indent)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(jsonPath, jsonOutput, 0644); err != nil {
+			return err
+		}
+	}
 
-	content, err := os.ReadFile(lockfile)
-	if err != nil {
-		return packages, hasAffected, hasWarnings
+	if summaryJSONPath != "" {
+		summaryOutput, err := json.MarshalIndent(SummaryReport{
+			Root:        result.Root,
+			AnyAffected: result.AnyAffected,
+			AnyWarnings: result.AnyWarnings,
+			Summary:     result.Summary,
+		}, "", indent)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(summaryJSONPath, summaryOutput, 0644); err != nil {
+			return err
+		}
 	}
 
-	// PNPM lockfiles are YAML, but we can parse them with simple string processing
-	lines := strings.Split(string(content), "\n")
+	return nil
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// writeJSONZstd writes the full report as zstd-compressed JSON
+func writeJSONZstd(path string, result ScanResult, indent string) error {
+	jsonOutput, err := json.MarshalIndent(result, "", indent)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		// Look for package entries like: /package-name@version:
-		if strings.HasPrefix(line, "/") && strings.Contains(line, "@") && strings.HasSuffix(line, ":") {
-			// Remove the leading / and trailing :
-			entry := strings.TrimSuffix(strings.TrimPrefix(line, "/"), ":")
+	writer, err := zstd.NewWriter(file)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(jsonOutput); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
 
-			// Split into package name and version
-			atIndex := strings.LastIndex(entry, "@")
-			if atIndex == -1 {
+// bunUnparseableReason opens the notice of a bun.lock that could not be decoded
+const bunUnparseableReason = "unparseable bun.lock"
+
+// stripJSONC turns JSONC into JSON by dropping // and /* */ comments and the trailing commas
+// before a closing brace or bracket, leaving string contents untouched
+func stripJSONC(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '"':
+			start := i
+			for i++; i < len(content) && content[i] != '"'; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(content) {
+				return append(out, content[start:]...)
+			}
+			out = append(out, content[start:i+1]...)
+		case c == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end == -1 {
+				return out
+			}
+			i += end + 3
+		case c == ',':
+			j := i + 1
+			for j < len(content) && (content[j] == ' ' || content[j] == '\t' || content[j] == '\n' || content[j] == '\r') {
+				j++
+			}
+			if j < len(content) && (content[j] == '}' || content[j] == ']') {
 				continue
 			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
 
-			name := entry[:atIndex]
-			version := entry[atIndex+1:]
+// splitBunPackageKey splits a bun.lock key or identifier like @scope/pkg@npm:1.0.0 into name
+// and version. The search for the version separator skips a scope's leading @, so a bare
+// @scope/pkg yields no version, and an npm: protocol prefix or alias target is stripped
+func splitBunPackageKey(key string) (string, string) {
+	offset := 0
+	if strings.HasPrefix(key, "@") {
+		offset = 1
+	}
+	atIndex := strings.Index(key[offset:], "@")
+	if atIndex == -1 {
+		return key, ""
+	}
+	atIndex += offset
 
-			// Normalize scoped packages
-			if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
-				name = "@" + name
-			}
+	name := key[:atIndex]
+	version := strings.TrimPrefix(key[atIndex+1:], "npm:")
+	// An alias like npm:other@1.0.0 resolves to the target's version
+	if aliasIndex := strings.LastIndex(version, "@"); aliasIndex != -1 {
+		version = version[aliasIndex+1:]
+	}
 
-			if affectedVersions, exists := affected[name]; exists {
-				isAffected := affectedVersions[version]
-				isWarning := !isAffected && len(affectedVersions) > 0
+	return name, version
+}
 
-				if isAffected || isWarning {
-					var affectedVers []string
-					for v := range affectedVersions {
-						affectedVers = append(affectedVers, v)
-					}
+// decodeBunEntry reads the package name, version and integrity of a bun.lock packages entry
+// under key, in either of bun's shapes: the text format's ["name@version", registry, info,
+// "sha512-..."] tuple, whose key is the installed name or a path like parent/name, or the
+// keyed format's {version, integrity} object under a name@version or name key
+func decodeBunEntry(key string, entry interface{}) (string, string, string) {
+	var name, version string
+	switch fields := entry.(type) {
+	case []interface{}:
+		if len(fields) == 0 {
+			return "", "", ""
+		}
+		ident, _ := fields[0].(string)
+		name, version = splitBunPackageKey(ident)
+	case map[string]interface{}:
+		name, version = splitBunPackageKey(key)
+		if keyed, ok := fields["version"].(string); ok {
+			version = keyed
+		}
+	default:
+		return "", "", ""
+	}
 
-					packages = append(packages, Package{
-						Name:             name,
-						Version:          version,
-						IsAffected:       isAffected,
-						IsWarning:        isWarning,
-						AffectedVersions: affectedVers,
-					})
+	// Normalize scoped packages
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
+		name = "@" + name
+	}
+	return name, version, bunEntryIntegrity(entry)
+}
 
-					if isAffected {
-						hasAffected = true
-					}
-					if isWarning {
-						hasWarnings = true
-					}
-				}
+// bunEntryIntegrity returns the integrity of a bun.lock packages entry: the trailing hash of
+// the text format's ["name@version", registry, info, "sha512-..."] array, or the keyed
+// format's integrity field
+func bunEntryIntegrity(entry interface{}) string {
+	switch entry := entry.(type) {
+	case []interface{}:
+		for i := len(entry) - 1; i > 0; i-- {
+			if value, ok := entry[i].(string); ok && normalizeIntegrity(value, "") != "" {
+				return value
 			}
 		}
+	case map[string]interface{}:
+		integrity, _ := entry["integrity"].(string)
+		return integrity
 	}
-
-	return packages, hasAffected, hasWarnings
+	return ""
 }
 
-// parseBunLock parses bun.lock
-func parseBunLock(lockfile string, affected map[string]map[string]bool) ([]Package, bool, bool) {
-	var packages []Package
-	hasAffected := false
-	hasWarnings := false
-
-	content, err := os.ReadFile(lockfile)
-	if err != nil {
-		return packages, hasAffected, hasWarnings
+// resolveBunVersion resolves a workspace dependency whose entry is not keyed by its bare
+// name to the version locked in bun.lock's packages section
+func resolveBunVersion(packagesData map[string]interface{}, name, spec string) string {
+	// Keyed format: packages["name@version"] = {version: "x.y.z"}
+	var resolved string
+	for key, pkgData := range packagesData {
+		if !strings.HasPrefix(key, name+"@") {
+			continue
+		}
+		if pkg, ok := pkgData.(map[string]interface{}); ok {
+			if version, ok := pkg["version"].(string); ok && (resolved == "" || compareVersions(version, resolved) > 0) {
+				resolved = version
+			}
+		}
 	}
-
-	// Try to parse as JSON first (bun.lock can be JSON)
-	var lockfileData map[string]interface{}
-	if err := json.Unmarshal(content, &lockfileData); err != nil {
-		// If JSON parsing fails, it might be the binary format
-		// For now, we'll skip binary bun.lock files
-		return packages, hasAffected, hasWarnings
+	if resolved != "" {
+		return resolved
 	}
 
-	// Parse packages section
-	if packagesData, ok := lockfileData["packages"].(map[string]interface{}); ok {
-		for key, pkgData := range packagesData {
-			if pkg, ok := pkgData.(map[string]interface{}); ok {
-				if key == "" {
-					continue // Skip root package
-				}
+	// Fall back to an exact version in the workspace's own spec
+	if exactVersionRegex.MatchString(spec) {
+		return spec
+	}
+	return ""
+}
 
-				// Bun format: packages["package@version"] = {version: "x.y.z"}
-				// Extract package name from key (remove version part)
-				atIndex := strings.LastIndex(key, "@")
-				if atIndex == -1 {
-					continue
-				}
+// exactVersionRegex matches a plain x.y.z version with no range operators
+var exactVersionRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(?:\.[0-9]+)?$`)
 
-				name := key[:atIndex]
-				if version, ok := pkg["version"].(string); ok {
-					// Normalize scoped packages
-					if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
-						name = "@" + name
-					}
+// collapsePath shortens a deep lockfile path relative to root by replacing its middle
+// segments with "...", keeping the first two and last two segments to identify the file
+func collapsePath(path, root string) string {
+	if absPath, err := filepath.Abs(path); err == nil {
+		if rel, err := filepath.Rel(root, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
 
-					if affectedVersions, exists := affected[name]; exists {
-						isAffected := affectedVersions[version]
-						isWarning := !isAffected && len(affectedVersions) > 0
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	if len(parts) <= 5 {
+		return path
+	}
+	collapsed := append(append(append([]string{}, parts[:2]...), "..."), parts[len(parts)-2:]...)
+	return filepath.FromSlash(strings.Join(collapsed, "/"))
+}
 
-						if isAffected || isWarning {
-							var affectedVers []string
-							for v := range affectedVersions {
-								affectedVers = append(affectedVers, v)
-							}
+// collapseResultPaths returns a copy of result with lockfile paths collapsed for display
+func collapseResultPaths(result ScanResult) ScanResult {
+	collapsed := make([]Result, len(result.Results))
+	for i, res := range result.Results {
+		res.LockFile = collapsePath(res.LockFile, result.Root)
+		collapsed[i] = res
+	}
+	result.Results = collapsed
+	return result
+}
 
-							packages = append(packages, Package{
-								Name:             name,
-								Version:          version,
-								IsAffected:       isAffected,
-								IsWarning:        isWarning,
-								AffectedVersions: affectedVers,
-							})
+// findRepoRoot returns the nearest directory at or above start that contains .git, or ""
+// when start is not inside a repository. A .git file marks worktrees and submodules
+func findRepoRoot(start string) string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
 
-							if isAffected {
-								hasAffected = true
-							}
-							if isWarning {
-								hasWarnings = true
-							}
-						}
-					}
-				}
+// repoRelativeResult returns a copy of result rooted at repoRoot, with lockfile paths
+// relative to it in forward-slash form
+func repoRelativeResult(result ScanResult, repoRoot string) ScanResult {
+	relative := make([]Result, len(result.Results))
+	for i, res := range result.Results {
+		if absPath, err := filepath.Abs(res.LockFile); err == nil {
+			if rel, err := filepath.Rel(repoRoot, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+				res.LockFile = filepath.ToSlash(rel)
 			}
 		}
+		relative[i] = res
 	}
-
-	return packages, hasAffected, hasWarnings
+	result.Root = repoRoot
+	result.Results = relative
+	return result
 }
 
-// printResults prints human-readable results
-func printResults(result ScanResult, summaryOnly, quiet, onlyAffected, noColor bool, startTime time.Time) {
+// printResults prints human-readable results; noSummary drops the summary and timing footer
+func printResults(result ScanResult, groupBy string, summaryOnly, noSummary, quiet, onlyAffected, noColor bool, startTime time.Time) {
 	if summaryOnly {
-		printSummary(result, noColor)
+		printSummary(os.Stdout, result, noColor)
 		return
 	}
 
@@ -850,15 +3933,56 @@ func printResults(result ScanResult, summaryOnly, quiet, onlyAffected, noColor b
 	if result.AnyAffected {
 		colorPrint("❌ SECURITY ISSUE FOUND!\n", "red", noColor)
 		colorPrint("Compromised packages detected - immediate action required\n\n", "red", noColor)
+	} else if result.AnyWarnings && result.Summary.TotalWarnings == 0 {
+		colorPrint("⚠️  INCOMPLETE SCAN\n", "yellow", noColor)
+		colorPrint("Some lockfiles could only be partly scanned, see the notices below\n\n", "yellow", noColor)
 	} else if result.AnyWarnings {
 		colorPrint("⚠️  VULNERABILITY WARNING\n", "yellow", noColor)
 		colorPrint("Current versions are SAFE, but vulnerable versions exist\n\n", "yellow", noColor)
+	} else if result.AnySuspicious {
+		colorPrint("⚠️  SUSPICIOUS FINDINGS\n", "yellow", noColor)
+		colorPrint("No compromised versions, but some lockfile entries look tampered with\n\n", "yellow", noColor)
 	} else {
 		colorPrint("✅ SCAN PASSED\n", "green", noColor)
 		colorPrint("No security issues detected\n\n", "green", noColor)
 	}
 
-	// Show affected packages first
+	if groupBy == "severity" {
+		printSeverityGroups(groupFindingsBySeverity(result.Results), noColor)
+	} else {
+		printFindingsByKind(result, noColor)
+	}
+
+	for _, res := range result.Results {
+		if res.Omitted > 0 {
+			colorPrint(fmt.Sprintf("... +%d more in %s (raise --max-findings-per-lockfile to see them)\n\n", res.Omitted, res.LockFile), "gray", noColor)
+		}
+	}
+	if result.Unchanged > 0 {
+		colorPrint(fmt.Sprintf("... and %d more in lockfiles unchanged since the last run (--report-only-changed)\n\n", result.Unchanged), "gray", noColor)
+	}
+	if result.Collapsed > 0 {
+		colorPrint(fmt.Sprintf("... and %d older versions collapsed by --latest-only\n\n", result.Collapsed), "gray", noColor)
+	}
+	if result.Omitted > 0 {
+		colorPrint(fmt.Sprintf("... and %d more not shown (raise --limit to see them)\n\n", result.Omitted), "gray", noColor)
+	}
+
+	if noSummary {
+		return
+	}
+
+	printSummary(os.Stdout, result, noColor)
+
+	elapsed := time.Since(startTime)
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+	colorPrint(fmt.Sprintf("⏱️  Scan completed in %v\n", elapsed.Round(time.Millisecond)), "cyan", noColor)
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+}
+
+// printFindingsByKind prints compromised packages first, then warnings and notices, each in
+// lockfile order
+func printFindingsByKind(result ScanResult, noColor bool) {
 	affectedCount := 0
 	warningCount := 0
 
@@ -877,11 +4001,7 @@ func printResults(result ScanResult, summaryOnly, quiet, onlyAffected, noColor b
 		for _, res := range result.Results {
 			for _, pkg := range res.Packages {
 				if pkg.IsAffected {
-					colorPrint(fmt.Sprintf("  %s@%s\n", pkg.Name, pkg.Version), "red", noColor)
-					colorPrint(fmt.Sprintf("    in: %s\n", res.LockFile), "gray", noColor)
-					if len(pkg.AffectedVersions) > 0 {
-						colorPrint(fmt.Sprintf("    affected: %s\n", strings.Join(pkg.AffectedVersions, ", ")), "red", noColor)
-					}
+					printAffectedFinding(os.Stdout, res, pkg, noColor)
 				}
 			}
 		}
@@ -893,48 +4013,191 @@ func printResults(result ScanResult, summaryOnly, quiet, onlyAffected, noColor b
 		for _, res := range result.Results {
 			for _, pkg := range res.Packages {
 				if pkg.IsWarning {
-					colorPrint(fmt.Sprintf("  %s@%s (current version is safe)\n", pkg.Name, pkg.Version), "yellow", noColor)
-					colorPrint(fmt.Sprintf("    in: %s\n", res.LockFile), "gray", noColor)
-					if len(pkg.AffectedVersions) > 0 {
-						colorPrint(fmt.Sprintf("    vulnerable: %s\n", strings.Join(pkg.AffectedVersions, ", ")), "yellow", noColor)
-					}
+					printWarningFinding(os.Stdout, res, pkg, noColor)
 				}
 			}
 		}
 		fmt.Println()
 	}
 
-	printSummary(result, noColor)
+	noticeCount := 0
+	for _, res := range result.Results {
+		noticeCount += len(res.Notices)
+		for _, pkg := range res.Packages {
+			if pkg.Notice != "" && !pkg.IsAffected && !pkg.IsWarning {
+				noticeCount++
+			}
+		}
+	}
 
-	elapsed := time.Since(startTime)
-	fmt.Println("═══════════════════════════════════════════════════════════════")
-	colorPrint(fmt.Sprintf("⏱️  Scan completed in %v\n", elapsed.Round(time.Millisecond)), "cyan", noColor)
-	fmt.Println("═══════════════════════════════════════════════════════════════")
+	if noticeCount > 0 {
+		colorPrint("Notices:\n", "cyan", noColor)
+		for _, res := range result.Results {
+			for _, notice := range res.Notices {
+				printNoticeFinding(os.Stdout, res, notice, noColor)
+			}
+			for _, pkg := range res.Packages {
+				if pkg.Notice != "" && !pkg.IsAffected && !pkg.IsWarning {
+					printNoticeFinding(os.Stdout, res, pkg.Notice, noColor)
+				}
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// printAffectedFinding prints a compromised package and everything known about it
+func printAffectedFinding(w io.Writer, res Result, pkg Package, noColor bool) {
+	if pkg.Severity != "" {
+		fcolorPrint(w, fmt.Sprintf("  %s@%s [%s]\n", pkg.Name, pkg.Version, pkg.Severity), severityColor(pkg.Severity), noColor)
+	} else {
+		fcolorPrint(w, fmt.Sprintf("  %s@%s\n", pkg.Name, pkg.Version), "red", noColor)
+	}
+	fcolorPrint(w, fmt.Sprintf("    in: %s\n", res.LockFile), "gray", noColor)
+	if res.Submodule != "" {
+		fcolorPrint(w, fmt.Sprintf("    submodule: %s\n", res.Submodule), "gray", noColor)
+	}
+	if pkg.Alias != "" {
+		fcolorPrint(w, fmt.Sprintf("    installed as: %s\n", pkg.Alias), "gray", noColor)
+	}
+	if pkg.Workspace != "" {
+		fcolorPrint(w, fmt.Sprintf("    workspace: %s\n", pkg.Workspace), "gray", noColor)
+	}
+	if len(pkg.AffectedVersions) > 0 {
+		fcolorPrint(w, fmt.Sprintf("    affected: %s\n", strings.Join(pkg.AffectedVersions, ", ")), "red", noColor)
+	}
+	if pkg.Advisory != "" {
+		fcolorPrint(w, fmt.Sprintf("    advisory: %s\n", pkg.Advisory), "gray", noColor)
+	}
+	if pkg.ChecksumMismatch {
+		fcolorPrint(w, "    checksum: cached artifact does not match its recorded hash\n", "red", noColor)
+	}
+	if pkg.Deprecated {
+		fcolorPrint(w, "    registry: this version is deprecated or unpublished\n", "gray", noColor)
+	}
+	if pkg.SuggestedVersion != "" {
+		fcolorPrint(w, fmt.Sprintf("    suggested: %s\n", pkg.SuggestedVersion), "green", noColor)
+	}
+	if pkg.FirstPublished != "" {
+		fcolorPrint(w, fmt.Sprintf("    first published: %s\n", pkg.FirstPublished), "gray", noColor)
+	}
+	if pkg.WeeklyDownloads > 0 {
+		fcolorPrint(w, fmt.Sprintf("    weekly downloads: %d\n", pkg.WeeklyDownloads), "gray", noColor)
+	}
+	printLockfileContext(w, pkg.Context, noColor)
+}
+
+// printWarningFinding prints a package whose installed version is safe but has compromised versions
+func printWarningFinding(w io.Writer, res Result, pkg Package, noColor bool) {
+	state := "current version is safe"
+	if pkg.Notice != "" {
+		state = pkg.Notice
+	}
+	fcolorPrint(w, fmt.Sprintf("  %s@%s (%s)\n", pkg.Name, pkg.Version, state), "yellow", noColor)
+	fcolorPrint(w, fmt.Sprintf("    in: %s\n", res.LockFile), "gray", noColor)
+	if res.Submodule != "" {
+		fcolorPrint(w, fmt.Sprintf("    submodule: %s\n", res.Submodule), "gray", noColor)
+	}
+	if pkg.Alias != "" {
+		fcolorPrint(w, fmt.Sprintf("    installed as: %s\n", pkg.Alias), "gray", noColor)
+	}
+	if len(pkg.AffectedVersions) > 0 {
+		fcolorPrint(w, fmt.Sprintf("    vulnerable: %s\n", strings.Join(pkg.AffectedVersions, ", ")), "yellow", noColor)
+	}
+	if pkg.ChecksumMismatch {
+		fcolorPrint(w, "    checksum: cached artifact does not match its recorded hash\n", "yellow", noColor)
+	}
+	printLockfileContext(w, pkg.Context, noColor)
+}
+
+// printNoticeFinding prints an informational message and the file it is about
+func printNoticeFinding(w io.Writer, res Result, notice string, noColor bool) {
+	fcolorPrint(w, fmt.Sprintf("  ℹ️  %s\n", notice), "cyan", noColor)
+	fcolorPrint(w, fmt.Sprintf("    in: %s\n", res.LockFile), "gray", noColor)
+}
+
+// printLockfileContext prints the lockfile lines captured around a finding, marking the
+// matched entry
+func printLockfileContext(w io.Writer, context *LockfileContext, noColor bool) {
+	if context == nil {
+		return
+	}
+	width := len(strconv.Itoa(context.StartLine + len(context.Lines) - 1))
+	for i, line := range context.Lines {
+		number := context.StartLine + i
+		marker := " "
+		if number == context.Line {
+			marker = ">"
+		}
+		fcolorPrint(w, fmt.Sprintf("    %s %*d | %s\n", marker, width, number, line), "gray", noColor)
+	}
+}
+
+// severityColor maps a severity to the color it is printed in
+func severityColor(severity string) string {
+	switch severity {
+	case severityMedium:
+		return "yellow"
+	case severityLow:
+		return "gray"
+	default:
+		return "red"
+	}
 }
 
 // printSummary prints the scan summary
-func printSummary(result ScanResult, noColor bool) {
-	colorPrint("📊 Scan Summary:\n", "cyan", noColor)
-	colorPrint(fmt.Sprintf("   Lockfiles scanned: %d\n", result.Summary.TotalLockfiles), "white", noColor)
-	colorPrint(fmt.Sprintf("   Package entries checked: %d\n", result.Summary.TotalPackages), "white", noColor)
+func printSummary(w io.Writer, result ScanResult, noColor bool) {
+	fcolorPrint(w, "📊 Scan Summary:\n", "cyan", noColor)
+	fcolorPrint(w, fmt.Sprintf("   Lockfiles scanned: %d\n", result.Summary.TotalLockfiles), "white", noColor)
+	if result.Sample != nil {
+		fcolorPrint(w, fmt.Sprintf("   Sample: %d of %d lockfiles (%.0f%% coverage, seed %d)\n", result.Sample.Scanned, result.Sample.Discovered,
+			100*float64(result.Sample.Scanned)/float64(result.Sample.Discovered), result.Sample.Seed), "yellow", noColor)
+	}
+	fcolorPrint(w, fmt.Sprintf("   Package entries checked: %d\n", result.Summary.TotalPackages), "white", noColor)
 
 	if result.Summary.TotalCompromised > 0 {
-		colorPrint(fmt.Sprintf("   Compromised packages: ❌ %d\n", result.Summary.TotalCompromised), "red", noColor)
+		fcolorPrint(w, fmt.Sprintf("   Compromised packages: ❌ %d\n", result.Summary.TotalCompromised), "red", noColor)
 	} else {
-		colorPrint("   Compromised packages: ✅ 0\n", "green", noColor)
+		fcolorPrint(w, "   Compromised packages: ✅ 0\n", "green", noColor)
 	}
 
 	if result.Summary.TotalWarnings > 0 {
-		colorPrint(fmt.Sprintf("   Warning packages: ⚠️ %d\n", result.Summary.TotalWarnings), "yellow", noColor)
+		fcolorPrint(w, fmt.Sprintf("   Warning packages: ⚠️ %d\n", result.Summary.TotalWarnings), "yellow", noColor)
+	} else {
+		fcolorPrint(w, "   Warning packages: ✅ 0\n", "green", noColor)
+	}
+	if result.Summary.TotalSuspicious > 0 {
+		fcolorPrint(w, fmt.Sprintf("   Suspicious packages: ⚠️ %d\n", result.Summary.TotalSuspicious), "yellow", noColor)
 	} else {
-		colorPrint("   Warning packages: ✅ 0\n", "green", noColor)
+		fcolorPrint(w, "   Suspicious packages: ✅ 0\n", "green", noColor)
+	}
+	if result.Summary.SuppressedWarnings > 0 {
+		fcolorPrint(w, fmt.Sprintf("   Suppressed warnings: %d (rerun without --quiet-errors to see them)\n", result.Summary.SuppressedWarnings), "gray", noColor)
+	}
+}
+
+// colorEnabled reports whether output to out should carry ANSI color codes for a --color
+// mode: always and never are unconditional, auto colors only when out is a terminal
+func colorEnabled(mode string, out *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
 	}
+	info, err := out.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 // colorPrint prints colored output if supported
 func colorPrint(text, color string, noColor bool) {
+	fcolorPrint(os.Stdout, text, color, noColor)
+}
+
+// fcolorPrint writes colored output to w if supported
+func fcolorPrint(w io.Writer, text, color string, noColor bool) {
 	if noColor {
-		fmt.Print(text)
+		fmt.Fprint(w, text)
 		return
 	}
 
@@ -951,8 +4214,8 @@ func colorPrint(text, color string, noColor bool) {
 	}
 
 	if code, exists := colors[color]; exists {
-		fmt.Print(code + text + colors["reset"])
+		fmt.Fprint(w, code+text+colors["reset"])
 	} else {
-		fmt.Print(text)
+		fmt.Fprint(w, text)
 	}
 }
\ No newline at end of file
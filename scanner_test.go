@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/yaml.v3"
 )
 
 func TestParseCommaSeparated(t *testing.T) {
@@ -196,7 +204,7 @@ func TestScanLockfile(t *testing.T) {
 		"left-pad": {"1.3.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -335,6 +343,55 @@ func TestLoadExploitedPackagesFileNotFound(t *testing.T) {
 	}
 }
 
+// Test that list entries may give a semver range, matched with semver rules
+func TestExploitedVersionRanges(t *testing.T) {
+	list := `left-pad@>=1.2.0   <1.4.0 high
+@scoped/pkg@^2.0.0
+is-odd@3.0.1
+bad@latest
+`
+	affected, severities, err := parseExploitedList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !affected["left-pad"][">=1.2.0 <1.4.0"] || !affected["@scoped/pkg"]["^2.0.0"] || !affected["is-odd"]["3.0.1"] {
+		t.Fatalf("Expected ranges and exact versions to be stored, got %v", affected)
+	}
+	if _, ok := affected["bad"]; ok {
+		t.Errorf("Expected an entry that is not a valid range to be skipped, got %v", affected["bad"])
+	}
+	if severities["left-pad@>=1.2.0 <1.4.0"] != severityHigh {
+		t.Errorf("Expected the range's severity, got %v", severities)
+	}
+
+	tests := []struct {
+		name, version string
+		affected      bool
+	}{
+		{"left-pad", "1.2.0", true},
+		{"left-pad", "1.3.9", true},
+		{"left-pad", "1.4.0", false},
+		{"left-pad", "1.3.0-rc.1", false},
+		{"@scoped/pkg", "2.5.1", true},
+		{"@scoped/pkg", "2.5.1+build.7", true},
+		{"@scoped/pkg", "3.0.0", false},
+		{"is-odd", "3.0.1", true},
+		{"is-odd", "3.0.2", false},
+	}
+	for _, tt := range tests {
+		pkg, ok := checkPackage(npmSemverComparator{}, tt.name, tt.version, affected)
+		if !ok || pkg.IsAffected != tt.affected || pkg.IsWarning == tt.affected {
+			t.Errorf("%s@%s: expected affected %v, got %+v", tt.name, tt.version, tt.affected, pkg)
+		}
+	}
+
+	results := []Result{{LockFile: "yarn.lock", Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}}}
+	applySeverities(results, affected, severities)
+	if results[0].Packages[0].Severity != severityHigh {
+		t.Errorf("Expected a range match to take the range's severity, got %+v", results[0].Packages[0])
+	}
+}
+
 // Test warning scenarios - packages that exist in exploited list but different versions
 func TestScanLockfileWarnings(t *testing.T) {
 	content := `{
@@ -374,7 +431,7 @@ func TestScanLockfileWarnings(t *testing.T) {
 		"@scoped/package": {"2.0.0": true, "2.2.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if hasAffected {
 		t.Error("Expected no affected packages")
@@ -433,7 +490,7 @@ left-pad@^1.3.0:
 		"@scoped/package": {"2.0.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -484,7 +541,7 @@ packages:
 		"@scoped/package": {"2.0.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -497,6 +554,130 @@ packages:
 	}
 }
 
+// Test that pnpm lockfile v9 entries, keyed name@version without a leading slash, are decoded
+// while snapshots and local packages are not reported
+func TestParsePnpmLockV9(t *testing.T) {
+	content := `lockfileVersion: '9.0'
+
+importers:
+  .:
+    dependencies:
+      left-pad:
+        specifier: ^1.3.0
+        version: 1.3.0
+
+packages:
+
+  left-pad@1.3.0:
+    resolution: {integrity: sha512-abc==}
+    engines: {node: '>=0.10.0'}
+
+  '@scoped/package@2.0.0':
+    resolution: {integrity: sha512-def==}
+
+  react-dom@18.2.0:
+    resolution: {integrity: sha512-ghi==}
+    peerDependencies:
+      react: ^18.2.0
+
+  local-lib@file:packages/local-lib:
+    resolution: {directory: packages/local-lib, type: directory}
+
+snapshots:
+
+  left-pad@1.3.0: {}
+
+  react-dom@18.2.0(react@18.2.0):
+    dependencies:
+      react: 18.2.0
+`
+	lockfile := filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+		"react-dom":       {"18.2.1": true},
+		"local-lib":       {"1.0.0": true},
+	}
+	packages, _, hasAffected, hasWarnings := scanLockfileAs(lockfile, lockfileFormat(filepath.Base(lockfile), nil), affected, scanOptions{contextLines: 1}, nil)
+	if !hasAffected || !hasWarnings {
+		t.Errorf("Expected affected and warning findings, got %+v", packages)
+	}
+	found := make(map[string]Package)
+	for _, pkg := range packages {
+		if pkg.Notice != "" {
+			t.Errorf("Expected no notices for a well-formed lockfile, got %q", pkg.Notice)
+		}
+		found[pkg.Name+"@"+pkg.Version] = pkg
+	}
+	if len(packages) != 3 {
+		t.Errorf("Expected each packages entry reported once, got %+v", packages)
+	}
+	leftPad := found["left-pad@1.3.0"]
+	if !leftPad.IsAffected || leftPad.Integrity != "sha512-abc==" {
+		t.Errorf("Expected left-pad compromised with its integrity, got %+v", leftPad)
+	}
+	if leftPad.Context == nil || leftPad.Context.Line != 12 {
+		t.Errorf("Expected context at the key line, got %+v", leftPad.Context)
+	}
+	if !found["@scoped/package@2.0.0"].IsAffected {
+		t.Errorf("Expected the quoted scoped key to be parsed, got %+v", found)
+	}
+	if !found["react-dom@18.2.0"].IsWarning {
+		t.Errorf("Expected react-dom to be a warning, got %+v", found)
+	}
+}
+
+// Test that pnpm lockfiles are decoded before the structural check is consulted, that
+// v-prefixed versions are kept, and that a malformed v9 lockfile is not passed as clean
+func TestParsePnpmLockDecodeFirst(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid", "pnpm-lock.yaml")
+	malformed := filepath.Join(dir, "malformed", "pnpm-lock.yaml")
+	files := map[string]string{
+		// The apostrophe trips the structural check but is a plain YAML scalar
+		valid: `lockfileVersion: '9.0'
+
+packages:
+
+  left-pad@https://codeload.github.com/stevemao/left-pad/tar.gz/abc:
+    resolution: {tarball: https://codeload.github.com/stevemao/left-pad/tar.gz/abc}
+    name: left-pad
+    version: v1.3.0
+    deprecated: it's compromised
+`,
+		malformed: `lockfileVersion: '9.0'
+
+packages:
+
+  left-pad@1.3.0:
+    resolution: {integrity: sha512-abc==
+`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+
+	packages, _, hasAffected, _ := scanLockfile(valid, affected)
+	if !hasAffected || len(packages) != 1 || packages[0].Version != "1.3.0" || packages[0].Notice != "" {
+		t.Errorf("Expected the v-prefixed entry flagged without a notice, got %+v", packages)
+	}
+
+	packages, notices, hasAffected, _ := scanLockfile(malformed, affected)
+	if hasAffected || len(packages) != 0 || len(notices) != 1 || !strings.Contains(notices[0], "this file was not scanned") {
+		t.Errorf("Expected a not-scanned notice for a malformed v9 lockfile, got %+v %q", packages, notices)
+	}
+}
+
 // Test malformed JSON handling
 func TestScanMalformedJSON(t *testing.T) {
 	content := `{
@@ -528,7 +709,7 @@ func TestScanMalformedJSON(t *testing.T) {
 		"left-pad": {"1.3.0": true},
 	}
 
-	packages, hasAffected, _ := scanLockfile(tmpFile.Name(), affected)
+	packages, _, hasAffected, _ := scanLockfile(tmpFile.Name(), affected)
 
 	// Should handle malformed JSON gracefully
 	if hasAffected {
@@ -602,7 +783,7 @@ func TestLargeLockfile(t *testing.T) {
 		"package5": {"1.0.5": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected package")
@@ -657,7 +838,7 @@ func TestMixedPackageTypes(t *testing.T) {
 		"@scoped/package": {"2.0.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -708,7 +889,7 @@ func TestEmptyLockfile(t *testing.T) {
 		"left-pad": {"1.3.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(tmpFile.Name(), affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(tmpFile.Name(), affected)
 
 	if hasAffected {
 		t.Error("Expected no affected packages in empty lockfile")
@@ -747,7 +928,7 @@ func TestRootOnlyLockfile(t *testing.T) {
 		"root-package": {"1.0.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(tmpFile.Name(), affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(tmpFile.Name(), affected)
 
 	if hasAffected {
 		t.Error("Expected no affected packages (root package should be ignored)")
@@ -806,7 +987,7 @@ func TestSemanticVersionEdgeCases(t *testing.T) {
 		"package4": {"2.0.0-alpha.1": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -876,7 +1057,7 @@ func TestMultipleAffectedVersions(t *testing.T) {
 		"safe-pkg":      {"1.0.0": true, "2.1.0": true},
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -964,7 +1145,7 @@ func TestCaseSensitivity(t *testing.T) {
 		"Left-Pad": {"1.3.0": true}, // Match case of first package in lockfile
 	}
 
-	packages, hasAffected, _ := scanLockfile(exactName, affected)
+	packages, _, hasAffected, _ := scanLockfile(exactName, affected)
 
 	// Should find the first case variant that matches
 	if !hasAffected {
@@ -1079,7 +1260,7 @@ func TestEndToEndScenario(t *testing.T) {
 		"outdated-safe":     {"1.0.0": true, "1.5.0": true},    // Current is safe
 	}
 
-	packages, hasAffected, hasWarnings := scanLockfile(exactName, affected)
+	packages, _, hasAffected, hasWarnings := scanLockfile(exactName, affected)
 
 	if !hasAffected {
 		t.Error("Expected to find affected packages")
@@ -1107,4 +1288,2259 @@ func TestEndToEndScenario(t *testing.T) {
 	if warningCount != 2 { // @babel/core and safe-package (different versions)
 		t.Errorf("Expected 2 warning packages, got %d", warningCount)
 	}
-}
\ No newline at end of file
+}
+
+// Test status line written to a dedicated file descriptor
+func TestWriteStatusLine(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	summary := Summary{
+		TotalLockfiles:   10,
+		TotalPackages:    42,
+		TotalWarnings:    2,
+		TotalCompromised: 3,
+	}
+
+	if err := writeStatusLine(w, summary); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "COMPROMISED=3 WARNINGS=2 LOCKFILES=10\n"
+	if line != expected {
+		t.Errorf("Expected status line %q, got %q", expected, line)
+	}
+}
+
+// Test that extra lockfile mappings are discovered and routed to the right parser
+func TestExtraLockfileMappings(t *testing.T) {
+	extra, err := parseLockfileMappings("custom-lock.json=npm, frontend.lock=yarn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extra) != 2 || extra[0].Format != "npm" || extra[1].Pattern != "frontend.lock" {
+		t.Fatalf("Unexpected mappings: %+v", extra)
+	}
+
+	root := t.TempDir()
+	content := `{
+		"lockfileVersion": 2,
+		"packages": {
+			"node_modules/left-pad": {
+				"version": "1.3.0"
+			}
+		}
+	}`
+	customPath := filepath.Join(root, "custom-lock.json")
+	if err := os.WriteFile(customPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockfiles, err := findLockfiles(root, []string{"npm"}, nil, nil, extra, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockfiles) != 1 || lockfiles[0] != customPath {
+		t.Fatalf("Expected custom-lock.json to be discovered, got %v", lockfiles)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+	}
+	results, anyAffected, _ := scanLockfiles(lockfiles, affected, extra, scanOptions{}, nil)
+	if !anyAffected || len(results) != 1 {
+		t.Errorf("Expected custom-lock.json to be scanned as npm, got %+v", results)
+	}
+
+	// Mappings for managers that were not selected are ignored
+	lockfiles, err = findLockfiles(root, []string{"yarn"}, nil, nil, extra, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockfiles) != 0 {
+		t.Errorf("Expected no lockfiles for yarn-only scan, got %v", lockfiles)
+	}
+
+	if _, err := parseLockfileMappings("custom.lock=cargo"); err == nil {
+		t.Error("Expected error for unknown lockfile format")
+	}
+}
+
+// Test writing the full report and the summary-only report in one run
+func TestWriteJSONReports(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "results.json")
+	summaryPath := filepath.Join(dir, "summary.json")
+
+	result := ScanResult{
+		Root: "/test",
+		Results: []Result{
+			{
+				LockFile: "package-lock.json",
+				Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}},
+			},
+		},
+		AnyAffected: true,
+		Summary: Summary{
+			TotalLockfiles:   1,
+			TotalPackages:    1,
+			TotalCompromised: 1,
+		},
+	}
+
+	if err := writeJSONReports(result, jsonPath, summaryPath, "  "); err != nil {
+		t.Fatal(err)
+	}
+
+	fullContent, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var full ScanResult
+	if err := json.Unmarshal(fullContent, &full); err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Results) != 1 {
+		t.Errorf("Expected full report to contain results, got %d", len(full.Results))
+	}
+
+	summaryContent, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary map[string]interface{}
+	if err := json.Unmarshal(summaryContent, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := summary["results"]; ok {
+		t.Error("Expected summary report to omit results")
+	}
+	if summary["anyAffected"] != true {
+		t.Errorf("Expected anyAffected in summary report, got %v", summary["anyAffected"])
+	}
+	if counts, ok := summary["summary"].(map[string]interface{}); !ok || counts["totalCompromised"] != float64(1) {
+		t.Errorf("Expected summary counts in summary report, got %v", summary["summary"])
+	}
+}
+
+// Test that --json-zstd writes a report that decompresses to the full JSON result
+func TestWriteJSONZstd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json.zst")
+	result := ScanResult{
+		Root: "/test",
+		Results: []Result{
+			{
+				LockFile: "package-lock.json",
+				Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}},
+			},
+		},
+		AnyAffected: true,
+	}
+
+	if err := writeJSONZstd(path, result, "  "); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	var decoded ScanResult
+	if err := json.NewDecoder(decoder).Decode(&decoded); err != nil {
+		t.Fatalf("Expected zstd-compressed JSON: %v", err)
+	}
+	if !decoded.AnyAffected || len(decoded.Results) != 1 || decoded.Results[0].Packages[0].Name != "left-pad" {
+		t.Errorf("Expected the report to round-trip, got %+v", decoded)
+	}
+}
+
+// Test exit code mapping with default and custom codes
+func TestDetermineExitCode(t *testing.T) {
+	tests := []struct {
+		anyAffected  bool
+		anyWarnings  bool
+		suspicious   bool
+		affectedCode int
+		warningCode  int
+		expected     int
+	}{
+		{false, false, false, 2, 0, 0},
+		{true, false, false, 2, 0, 2},
+		{false, true, false, 2, 0, 0},
+		{true, true, false, 2, 0, 2},
+		{true, false, false, 10, 3, 10},
+		{false, true, false, 10, 3, 3},
+		{true, true, false, 10, 3, 10},
+		{false, false, false, 10, 3, 0},
+		{false, false, true, 2, 0, 2},
+		{false, true, true, 10, 3, 10},
+	}
+
+	for _, test := range tests {
+		result := determineExitCode(test.anyAffected, test.anyWarnings, test.suspicious, test.affectedCode, test.warningCode)
+		if result != test.expected {
+			t.Errorf("determineExitCode(%v, %v, %v, %d, %d) = %d, want %d",
+				test.anyAffected, test.anyWarnings, test.suspicious, test.affectedCode, test.warningCode, result, test.expected)
+		}
+	}
+}
+
+// Test that an unsupported npm lockfileVersion is surfaced as a notice
+func TestUnsupportedLockfileVersion(t *testing.T) {
+	content := `{
+		"lockfileVersion": 99,
+		"packages": {
+			"node_modules/left-pad": {
+				"version": "1.3.0"
+			}
+		}
+	}`
+
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+	}
+
+	packages, notices, hasAffected, hasWarnings := scanLockfile(lockfile, affected)
+
+	if !hasAffected || len(packages) != 1 {
+		t.Errorf("Expected packages to still be scanned, got %+v", packages)
+	}
+	if hasWarnings {
+		t.Error("Expected the notice not to count as a warning")
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "unsupported lockfileVersion 99") {
+		t.Errorf("Expected an unsupported lockfileVersion notice, got %q", notices)
+	}
+}
+
+// Test that report managers hide findings from other ecosystems
+func TestFilterResultsByManagers(t *testing.T) {
+	results := []Result{
+		{
+			LockFile: "/repo/pnpm-lock.yaml",
+			Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}},
+		},
+		{
+			LockFile: "/repo/app/package-lock.json",
+			Packages: []Package{{Name: "left-pad", Version: "1.2.0", IsWarning: true}},
+		},
+	}
+
+	filtered, anyAffected, anyWarnings := filterResultsByManagers(results, []string{"npm"}, nil)
+
+	if len(filtered) != 1 || filtered[0].LockFile != "/repo/app/package-lock.json" {
+		t.Fatalf("Expected only the npm result, got %+v", filtered)
+	}
+	if anyAffected {
+		t.Error("Expected pnpm findings to be hidden")
+	}
+	if !anyWarnings {
+		t.Error("Expected npm warnings to be kept")
+	}
+}
+
+// Test that a malformed pnpm lockfile still surfaces compromised entries with a parse warning
+func TestParsePnpmLockMalformed(t *testing.T) {
+	content := `lockfileVersion: 5.4
+
+packages:
+  /left-pad@1.3.0:
+    resolution: {integrity: sha512-abc
+	engines: {node: '>=0.10.0'}
+
+  /@scoped/package@2.0.0:
+    resolution: {integrity: 'sha512-`
+
+	lockfile := filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+	}
+
+	_, notices, hasAffected, _ := scanLockfile(lockfile, affected)
+
+	if !hasAffected {
+		t.Error("Expected compromised entry to be found by the fallback scan")
+	}
+
+	foundWarning := false
+	for _, notice := range notices {
+		if strings.Contains(notice, "malformed YAML") {
+			foundWarning = true
+		}
+	}
+	if !foundWarning {
+		t.Errorf("Expected a parse warning notice, got %q", notices)
+	}
+}
+
+// Test that only a match confirmed by one of the list's known-bad hashes gets high confidence
+func TestMatchConfidence(t *testing.T) {
+	content := `{
+		"lockfileVersion": 2,
+		"packages": {
+			"node_modules/left-pad": {
+				"version": "1.3.0",
+				"resolved": "https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz",
+				"integrity": "sha512-abc"
+			},
+			"node_modules/@scoped/package": {
+				"version": "2.0.0",
+				"resolved": "https://registry.npmjs.org/@scoped/package/-/package-2.0.0.tgz",
+				"integrity": "sha512-unlisted"
+			},
+			"node_modules/other": {
+				"version": "0.9.0"
+			}
+		}
+	}`
+
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+		"other":           {"1.0.0": true},
+	}
+	opts := scanOptions{knownBad: map[string]string{"sha512-abc": "left-pad@1.3.0"}}
+
+	packages, _, _, _ := scanLockfileAs(lockfile, "npm", affected, opts, nil)
+
+	confidence := make(map[string]string)
+	for _, pkg := range packages {
+		confidence[pkg.Name] = pkg.Confidence
+	}
+
+	if confidence["left-pad"] != confidenceHigh {
+		t.Errorf("Expected high confidence for a known-bad integrity, got %q", confidence["left-pad"])
+	}
+	// Any hash an entry carries is not evidence until the list names it
+	if confidence["@scoped/package"] != confidenceMedium {
+		t.Errorf("Expected medium confidence for an unlisted integrity, got %q", confidence["@scoped/package"])
+	}
+	if confidence["other"] != confidenceLow {
+		t.Errorf("Expected low confidence for name-only warning, got %q", confidence["other"])
+	}
+	if got := matchConfidence(true, "sha512-abc", nil); got != confidenceMedium {
+		t.Errorf("Expected medium confidence without the list's hashes, got %q", got)
+	}
+
+	results, anyAffected, anyWarnings := filterResultsByConfidence([]Result{{LockFile: lockfile, Packages: packages}}, confidenceHigh)
+	if len(results) != 1 || len(results[0].Packages) != 1 || results[0].Packages[0].Name != "left-pad" || !anyAffected || anyWarnings {
+		t.Errorf("Expected only the high confidence finding to remain, got %+v", results)
+	}
+}
+
+// Test that an inline list from the environment drives the scan
+func TestInlineExploitedPackages(t *testing.T) {
+	t.Setenv(listInlineEnv, "left-pad@1.3.0, babel/core@7.15.0\n@scoped/package@2.0.0")
+
+	list := resolveInlineList("", false)
+	if list == "" {
+		t.Fatal("Expected inline list to be read from the environment")
+	}
+	if resolveInlineList("other@1.0.0", false) != "other@1.0.0" {
+		t.Error("Expected --list-inline to take precedence over the environment")
+	}
+	if resolveInlineList("", true) != "" {
+		t.Error("Expected an explicit --list-path or --list-url to take precedence over the environment")
+	}
+
+	affected, err := loadInlineExploitedPackages(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(affected) != 3 || !affected["@babel/core"]["7.15.0"] {
+		t.Fatalf("Expected 3 inline entries parsed like a list file, got %v", affected)
+	}
+
+	content := `{
+		"lockfileVersion": 2,
+		"packages": {
+			"node_modules/@scoped/package": {
+				"version": "2.0.0"
+			}
+		}
+	}`
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, hasAffected, _ := scanLockfile(lockfile, affected)
+	if !hasAffected || len(packages) != 1 || packages[0].Name != "@scoped/package" {
+		t.Errorf("Expected inline entries to flag @scoped/package, got %+v", packages)
+	}
+}
+
+// Test that bun workspace member dependencies are scanned and attributed to the member
+func TestParseBunLockWorkspaces(t *testing.T) {
+	content := `{
+		"lockfileVersion": 0,
+		"workspaces": {
+			"": {
+				"name": "monorepo"
+			},
+			"packages/web": {
+				"name": "web",
+				"dependencies": {
+					"left-pad": "^1.3.0"
+				}
+			},
+			"packages/api": {
+				"name": "api",
+				"dependencies": {
+					"express": "^4.18.0"
+				}
+			}
+		},
+		"packages": {
+			"left-pad": ["left-pad@1.3.0", "", {}, "sha512-abc"],
+			"express": ["express@4.18.2", "", {}, "sha512-def"]
+		}
+	}`
+
+	lockfile := filepath.Join(t.TempDir(), "bun.lock")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+	}
+
+	packages, _, hasAffected, hasWarnings := scanLockfile(lockfile, affected)
+
+	if !hasAffected {
+		t.Error("Expected compromised workspace dependency to be found")
+	}
+	if hasWarnings {
+		t.Error("Expected no warnings")
+	}
+	if len(packages) != 1 {
+		t.Fatalf("Expected 1 package, got %d: %+v", len(packages), packages)
+	}
+	if packages[0].Workspace != "packages/web" {
+		t.Errorf("Expected finding attributed to packages/web, got %q", packages[0].Workspace)
+	}
+}
+
+// Test that a bun.lock as bun writes it, JSONC with tuple entries, is scanned down to its
+// transitive dependencies, and an unreadable one is not passed as clean
+func TestParseBunLockTextFormat(t *testing.T) {
+	content := `{
+  "lockfileVersion": 1,
+  "workspaces": {
+    "": {
+      "name": "app",
+      "dependencies": {
+        "express": "^4.18.0",
+      },
+    },
+  },
+  "packages": {
+    "express": ["express@4.18.2", "", { "dependencies": { "debug": "2.6.9", "left-pad": "^1.3.0" } }, "sha512-def"],
+
+    "left-pad": ["left-pad@1.3.0", "", {}, "sha512-abc"],
+
+    "express/debug": ["debug@2.6.9", "", { "dependencies": { "ms": "2.0.0" } }, "sha512-ghi"],
+  }
+}
+`
+	lockfile := filepath.Join(t.TempDir(), "bun.lock")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+		"debug":    {"4.4.2": true},
+	}
+
+	packages, notices, hasAffected, hasWarnings := parseBunLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected || !hasWarnings || len(notices) != 0 {
+		t.Fatalf("Expected the transitive left-pad@1.3.0 to be found, got %+v %q", packages, notices)
+	}
+	found := make(map[string]Package)
+	for _, pkg := range packages {
+		found[pkg.Name+"@"+pkg.Version] = pkg
+	}
+	if pkg := found["left-pad@1.3.0"]; !pkg.IsAffected || pkg.Integrity != "sha512-abc" || pkg.Workspace != "" {
+		t.Errorf("Expected left-pad@1.3.0 compromised with its integrity, got %+v", packages)
+	}
+	if pkg := found["debug@2.6.9"]; !pkg.IsWarning {
+		t.Errorf("Expected the nested express/debug entry to be read as debug@2.6.9, got %+v", packages)
+	}
+
+	if err := os.WriteFile(lockfile, []byte(`{"lockfileVersion": 1, "packages": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, _, anyWarnings := scanLockfiles([]string{lockfile}, affected, nil, scanOptions{}, nil)
+	if !anyWarnings || len(results) != 1 || !results[0].Incomplete || !strings.HasPrefix(results[0].Notices[0], bunUnparseableReason) {
+		t.Errorf("Expected an unparseable bun.lock to be reported as not scanned, got %+v", results)
+	}
+}
+
+// Test that stripJSONC drops comments and trailing commas but not string contents
+func TestStripJSONC(t *testing.T) {
+	input := `{"a": ["x,]", "// not a comment",], // comment
+  /* block */ "b": {"c": 1,},}`
+	var data map[string]interface{}
+	if err := json.Unmarshal(stripJSONC([]byte(input)), &data); err != nil {
+		t.Fatalf("Expected valid JSON, got %v: %s", err, stripJSONC([]byte(input)))
+	}
+	if values := data["a"].([]interface{}); len(values) != 2 || values[0] != "x,]" || values[1] != "// not a comment" {
+		t.Errorf("Expected string contents kept, got %v", values)
+	}
+}
+
+// Test that an include pattern matching nothing is distinguished from an empty tree
+func TestEmptyDiscoveryWarning(t *testing.T) {
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "apps", "web", "package-lock.json")
+	if err := os.MkdirAll(filepath.Dir(lockfile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockfile, []byte(`{"packages": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	managers := []string{"npm"}
+	include := []string{"packages/**"}
+
+	lockfiles, err := findLockfiles(root, managers, include, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockfiles) != 0 {
+		t.Fatalf("Expected include pattern to match nothing, got %v", lockfiles)
+	}
+
+	warning := emptyDiscoveryWarning(root, managers, include, nil, nil)
+	if !strings.Contains(warning, "no lockfiles matched your include pattern(s) packages/**") ||
+		!strings.Contains(warning, "1 lockfile(s) exist") {
+		t.Errorf("Unexpected warning: %q", warning)
+	}
+
+	// A truly empty tree gets no include warning
+	if warning := emptyDiscoveryWarning(t.TempDir(), managers, include, nil, nil); warning != "" {
+		t.Errorf("Expected no include warning for an empty tree, got %q", warning)
+	}
+}
+
+// Test JSON indentation parsing and that tab-indented reports round-trip
+func TestJSONIndent(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"2", "  ", false},
+		{"4", "    ", false},
+		{"0", "", false},
+		{"tab", "\t", false},
+		{"-1", "", true},
+		{"wide", "", true},
+	}
+
+	for _, test := range tests {
+		result, err := parseJSONIndent(test.input)
+		if (err != nil) != test.wantErr || result != test.expected {
+			t.Errorf("parseJSONIndent(%q) = %q, %v, want %q (error: %v)", test.input, result, err, test.expected, test.wantErr)
+		}
+	}
+
+	jsonPath := filepath.Join(t.TempDir(), "results.json")
+	result := ScanResult{
+		Root:    "/test",
+		Results: []Result{{LockFile: "package-lock.json", Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}}},
+	}
+	if err := writeJSONReports(result, jsonPath, "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "\n\t\"root\"") {
+		t.Errorf("Expected tab indentation, got:\n%s", content)
+	}
+
+	var parsed ScanResult
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Root != "/test" || len(parsed.Results) != 1 {
+		t.Errorf("Expected report to round-trip, got %+v", parsed)
+	}
+}
+
+// Test that sha1 integrity and missing integrity among sha512 siblings are flagged as suspicious
+func TestIntegrityDowngrade(t *testing.T) {
+	tempDir := t.TempDir()
+
+	npmLock := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/left-pad": {
+      "version": "1.3.0",
+      "resolved": "https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz",
+      "integrity": "sha1-W5AJhEj4q4fBPcWRVuXvwDQrEoU="
+    },
+    "node_modules/is-odd": {
+      "version": "3.0.1",
+      "resolved": "https://registry.npmjs.org/is-odd/-/is-odd-3.0.1.tgz"
+    },
+    "node_modules/safe": {
+      "version": "1.0.0",
+      "resolved": "https://registry.npmjs.org/safe/-/safe-1.0.0.tgz",
+      "integrity": "sha512-abc=="
+    },
+    "node_modules/local": {
+      "version": "0.1.0",
+      "resolved": "file:../local"
+    }
+  }
+}`
+	npmPath := filepath.Join(tempDir, "package-lock.json")
+	if err := os.WriteFile(npmPath, []byte(npmLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, hasAffected, _ := parseNPMLock(npmPath, map[string]map[string]bool{}, scanOptions{}, nil)
+	if hasAffected {
+		t.Error("Expected suspicious entries not to count as affected")
+	}
+	suspicious := make(map[string]bool)
+	for _, pkg := range packages {
+		if pkg.IsSuspicious {
+			suspicious[pkg.Name] = true
+		}
+	}
+	if !suspicious["left-pad"] || !suspicious["is-odd"] || len(suspicious) != 2 {
+		t.Errorf("Expected left-pad and is-odd to be suspicious, got %v", suspicious)
+	}
+
+	yarnLock := `"left-pad@^1.3.0":
+  version "1.3.0"
+  resolved "https://registry.yarnpkg.com/left-pad/-/left-pad-1.3.0.tgz"
+  integrity sha1-W5AJhEj4q4fBPcWRVuXvwDQrEoU=
+
+"safe@^1.0.0":
+  version "1.0.0"
+  resolved "https://registry.yarnpkg.com/safe/-/safe-1.0.0.tgz"
+  integrity sha512-abc==
+`
+	yarnPath := filepath.Join(tempDir, "yarn.lock")
+	if err := os.WriteFile(yarnPath, []byte(yarnLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, _, _ = parseYarnLock(yarnPath, map[string]map[string]bool{}, scanOptions{}, nil)
+	if len(packages) != 1 || !packages[0].IsSuspicious || packages[0].Name != "left-pad" {
+		t.Errorf("Expected only left-pad to be suspicious, got %+v", packages)
+	}
+}
+
+// Test that entries whose resolved tarball belongs to another package are flagged as suspicious
+func TestResolvedNameMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+
+	npmLock := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/lodash": {
+      "version": "4.17.21",
+      "resolved": "https://registry.npmjs.org/lodash-utils/-/lodash-utils-4.17.21.tgz",
+      "integrity": "sha512-abc=="
+    },
+    "node_modules/@babel/core": {
+      "version": "7.24.0",
+      "resolved": "https://registry.npmjs.org/@babel%2fcore/-/core-7.24.0.tgz",
+      "integrity": "sha512-abc=="
+    },
+    "node_modules/@babel/parser": {
+      "version": "7.24.0",
+      "resolved": "https://registry.npmjs.org/@babel/parser/-/evil-7.24.0.tgz",
+      "integrity": "sha512-abc=="
+    },
+    "node_modules/my-chalk": {
+      "name": "chalk",
+      "version": "5.3.0",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.3.0.tgz",
+      "integrity": "sha512-abc=="
+    }
+  }
+}`
+	npmPath := filepath.Join(tempDir, "package-lock.json")
+	if err := os.WriteFile(npmPath, []byte(npmLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, hasAffected, _ := parseNPMLock(npmPath, map[string]map[string]bool{}, scanOptions{}, nil)
+	if hasAffected {
+		t.Error("Expected mismatched entries not to count as affected")
+	}
+	suspicious := make(map[string]string)
+	for _, pkg := range packages {
+		if pkg.IsSuspicious {
+			suspicious[pkg.Name] = pkg.Notice
+		}
+	}
+	if !strings.Contains(suspicious["lodash"], "points at lodash-utils") {
+		t.Errorf("Expected lodash resolving to lodash-utils to be suspicious, got %v", suspicious)
+	}
+	if !strings.Contains(suspicious["@babel/parser"], "evil-7.24.0.tgz") {
+		t.Errorf("Expected a foreign tarball file name to be suspicious, got %v", suspicious)
+	}
+	if len(suspicious) != 2 {
+		t.Errorf("Expected the encoded scope and the alias to pass, got %v", suspicious)
+	}
+
+	yarnLock := `"lodash@^4.17.21":
+  version "4.17.21"
+  resolved "https://registry.yarnpkg.com/lodahs/-/lodahs-4.17.21.tgz#abc"
+  integrity sha512-abc==
+
+"my-chalk@npm:chalk@^5.3.0":
+  version "5.3.0"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.3.0.tgz"
+  integrity sha512-abc==
+`
+	yarnPath := filepath.Join(tempDir, "yarn.lock")
+	if err := os.WriteFile(yarnPath, []byte(yarnLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, _, _ = parseYarnLock(yarnPath, map[string]map[string]bool{}, scanOptions{}, nil)
+	if len(packages) != 1 || !packages[0].IsSuspicious || packages[0].Name != "lodash" {
+		t.Errorf("Expected only lodash to be suspicious, got %+v", packages)
+	}
+}
+
+// Test that aliased installs are checked as the real package, so they correlate with direct
+// installs of it in other lockfiles
+func TestAliasedInstallsCorrelate(t *testing.T) {
+	tempDir := t.TempDir()
+	affected := map[string]map[string]bool{"chalk": {"5.6.1": true}}
+
+	npmLock := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/my-chalk": {
+      "name": "chalk",
+      "version": "5.6.1",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz",
+      "integrity": "sha512-abc=="
+    }
+  }
+}`
+	yarnLock := `"chalk@^5.6.0":
+  version "5.6.1"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz"
+  integrity sha512-abc==
+
+"other-chalk@npm:chalk@^5.6.0":
+  version "5.6.1"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz"
+  integrity sha512-abc==
+`
+	npmPath := filepath.Join(tempDir, "package-lock.json")
+	yarnPath := filepath.Join(tempDir, "yarn.lock")
+	if err := os.WriteFile(npmPath, []byte(npmLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(yarnPath, []byte(yarnLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	npmPackages, _, npmAffected, _ := parseNPMLock(npmPath, affected, scanOptions{}, nil)
+	yarnPackages, _, yarnAffected, _ := parseYarnLock(yarnPath, affected, scanOptions{}, nil)
+	if !npmAffected || !yarnAffected {
+		t.Fatalf("Expected both lockfiles to be affected, got npm %v yarn %v", npmAffected, yarnAffected)
+	}
+	if len(npmPackages) != 1 || npmPackages[0].Name != "chalk" || npmPackages[0].Alias != "my-chalk" {
+		t.Errorf("Expected the npm alias to be reported as chalk installed as my-chalk, got %+v", npmPackages)
+	}
+	aliases := make(map[string]bool)
+	for _, pkg := range yarnPackages {
+		if pkg.Name != "chalk" || !pkg.IsAffected {
+			t.Errorf("Expected every yarn finding to be compromised chalk, got %+v", pkg)
+		}
+		aliases[pkg.Alias] = true
+	}
+	if !aliases[""] || !aliases["other-chalk"] {
+		t.Errorf("Expected a direct and an aliased yarn install, got %+v", yarnPackages)
+	}
+
+	results := []Result{
+		{LockFile: npmPath, Packages: npmPackages},
+		{LockFile: yarnPath, Packages: yarnPackages},
+	}
+	snapshot := buildOrgSnapshot([]ScanResult{{Results: results}}, time.Now())
+	if len(snapshot.Compromised) != 1 || snapshot.Compromised[0] != "chalk@5.6.1" {
+		t.Errorf("Expected one distinct compromised package, got %v", snapshot.Compromised)
+	}
+}
+
+// Test that --limit keeps the most urgent findings while the summary reflects every finding
+func TestLimitFindings(t *testing.T) {
+	results := []Result{
+		{LockFile: "a/yarn.lock", Packages: []Package{
+			{Name: "warned", Version: "1.0.0", IsWarning: true, Confidence: confidenceLow},
+			{Name: "notice", Notice: "informational"},
+		}},
+		{LockFile: "b/package-lock.json", Packages: []Package{
+			{Name: "medium", Version: "1.0.0", IsAffected: true, Confidence: confidenceMedium},
+			{Name: "high", Version: "1.0.0", IsAffected: true, Confidence: confidenceHigh},
+		}},
+	}
+
+	summary := summarizeResults(results, 2)
+	limited, omitted := limitFindings(results, 2)
+
+	if omitted != 2 {
+		t.Errorf("Expected 2 omitted findings, got %d", omitted)
+	}
+	if len(limited) != 1 || len(limited[0].Packages) != 2 {
+		t.Fatalf("Expected the two compromised findings in one result, got %+v", limited)
+	}
+	if limited[0].Packages[0].Name != "high" || limited[0].Packages[1].Name != "medium" {
+		t.Errorf("Expected findings ordered by confidence, got %+v", limited[0].Packages)
+	}
+	if summary.TotalPackages != 4 || summary.TotalCompromised != 2 || summary.TotalWarnings != 1 {
+		t.Errorf("Expected summary to count every finding, got %+v", summary)
+	}
+
+	if unchanged, omitted := limitFindings(results, 10); omitted != 0 || len(unchanged) != 2 {
+		t.Errorf("Expected a generous limit to leave results untouched, got %d omitted", omitted)
+	}
+}
+
+// Test that --max-findings-per-lockfile caps each lockfile separately and notes the rest
+func TestCapFindingsPerLockfile(t *testing.T) {
+	results := []Result{
+		{LockFile: "a/yarn.lock", Packages: []Package{
+			{Name: "warned", Version: "1.0.0", IsWarning: true},
+			{Name: "first", Version: "1.0.0", IsAffected: true},
+			{Name: "second", Version: "1.0.0", IsAffected: true},
+		}},
+		{LockFile: "b/package-lock.json", Packages: []Package{
+			{Name: "other", Version: "1.0.0", IsAffected: true},
+		}},
+	}
+
+	summary := summarizeResults(results, 2)
+	capped := capFindingsPerLockfile(results, 2)
+	if len(capped) != 2 || capped[0].Omitted != 1 || capped[1].Omitted != 0 {
+		t.Fatalf("Expected only the first lockfile to be capped, got %+v", capped)
+	}
+	if len(capped[0].Packages) != 2 || capped[0].Packages[0].Name != "first" || capped[0].Packages[1].Name != "second" {
+		t.Errorf("Expected the compromised findings kept in lockfile order, got %+v", capped[0].Packages)
+	}
+	if len(capped[1].Packages) != 1 {
+		t.Errorf("Expected the second lockfile untouched, got %+v", capped[1].Packages)
+	}
+
+	result := ScanResult{AnyAffected: true, Results: capped, Summary: summary}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printResults(result, "", false, false, false, false, true, time.Now())
+	os.Stdout = stdout
+	w.Close()
+
+	var captured bytes.Buffer
+	if _, err := captured.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	output := captured.String()
+	if !strings.Contains(output, "+1 more in a/yarn.lock") || strings.Contains(output, "more in b/package-lock.json") {
+		t.Errorf("Expected a truncation note for the capped lockfile only, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Compromised packages: ❌ 3") || !strings.Contains(output, "Warning packages: ⚠️ 1") {
+		t.Errorf("Expected the summary to count every finding, got:\n%s", output)
+	}
+}
+
+// Test that compromised packages only fail the scan once they exceed the threshold
+func TestFailThreshold(t *testing.T) {
+	result := ScanResult{
+		Results: []Result{{LockFile: "yarn.lock", Packages: []Package{
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			{Name: "is-odd", Version: "3.0.1", IsAffected: true},
+		}}},
+		AnyAffected: true,
+	}
+	result.Summary = summarizeResults(result.Results, 1)
+
+	if code := scanExitCode(result, 2, 2, 0, false); code != 0 {
+		t.Errorf("Expected exit 0 with 2 compromised and threshold 2, got %d", code)
+	}
+	if code := scanExitCode(result, 1, 2, 0, false); code != 2 {
+		t.Errorf("Expected exit 2 with 2 compromised and threshold 1, got %d", code)
+	}
+	if code := scanExitCode(result, 0, 2, 0, false); code != 2 {
+		t.Errorf("Expected default threshold to fail on any compromised package, got %d", code)
+	}
+}
+
+// Test that suspicious-only findings fail the scan only with --fail-on-suspicious
+func TestFailOnSuspicious(t *testing.T) {
+	results := []Result{{LockFile: "yarn.lock", Packages: []Package{
+		suspiciousPackage("left-pad", "1.3.0", "integrity uses sha1 where the registry provides sha512"),
+	}}}
+	result := ScanResult{Results: results, AnySuspicious: anySuspicious(results), Summary: summarizeResults(results, 1)}
+
+	if code := scanExitCode(result, 0, 2, 0, false); code != 0 {
+		t.Errorf("Expected suspicious-only findings to exit 0 by default, got %d", code)
+	}
+	if code := scanExitCode(result, 0, 2, 0, true); code != 2 {
+		t.Errorf("Expected suspicious-only findings to exit 2 with --fail-on-suspicious, got %d", code)
+	}
+
+	// The decision holds even when the finding is not shown
+	result.Results = nil
+	if code := scanExitCode(result, 0, 2, 0, true); code != 2 {
+		t.Errorf("Expected hidden suspicious findings to still fail, got %d", code)
+	}
+}
+
+// Test that a bundled dependency pinned only in its parent's dependencies is scanned
+func TestParseNPMLockBundledDependencies(t *testing.T) {
+	content := `{
+  "lockfileVersion": 2,
+  "packages": {
+    "": {"name": "app", "dependencies": {"parent": "^1.0.0"}},
+    "node_modules/parent": {
+      "version": "1.0.0",
+      "bundleDependencies": ["left-pad"],
+      "dependencies": {"left-pad": "1.3.0", "hoisted": "2.0.0", "ranged": "^1.0.0"}
+    },
+    "node_modules/hoisted": {"version": "2.0.0"}
+  }
+}`
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+		"ranged":   {"1.0.0": true},
+	}
+
+	packages, _, hasAffected, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected {
+		t.Error("Expected bundled compromised dependency to be flagged")
+	}
+	if len(packages) != 1 || packages[0].Name != "left-pad" || packages[0].Version != "1.3.0" || !packages[0].IsAffected {
+		t.Errorf("Expected only left-pad@1.3.0 to be found, got %+v", packages)
+	}
+
+	bundled := npmBundledDependencies("node_modules/parent", map[string]interface{}{
+		"dependencies": map[string]interface{}{"hoisted": "2.0.0"},
+	}, map[string]interface{}{"node_modules/hoisted": map[string]interface{}{}})
+	if len(bundled) != 0 {
+		t.Errorf("Expected dependencies resolvable by node to be skipped, got %v", bundled)
+	}
+}
+
+// Test that a legacy pnpm shrinkwrap.yaml is discovered and its /name/version keys are scanned
+func TestLegacyPnpmShrinkwrap(t *testing.T) {
+	root := t.TempDir()
+	content := `shrinkwrapVersion: 3
+dependencies:
+  left-pad: 1.3.0
+packages:
+  /left-pad/1.3.0:
+    resolution:
+      integrity: sha512-abc==
+  /@scoped/package/2.0.0_react@16.0.0:
+    resolution:
+      integrity: sha512-def==
+registry: 'https://registry.npmjs.org/'
+`
+	lockfile := filepath.Join(root, "shrinkwrap.yaml")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockfiles, err := findLockfiles(root, []string{"pnpm"}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockfiles) != 1 || lockfiles[0] != lockfile {
+		t.Fatalf("Expected shrinkwrap.yaml to be discovered, got %v", lockfiles)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+	}
+	results, hasAffected, _ := scanLockfiles(lockfiles, affected, nil, scanOptions{}, nil)
+	if !hasAffected || len(results) != 1 {
+		t.Fatalf("Expected shrinkwrap.yaml to be flagged, got %+v", results)
+	}
+
+	found := make(map[string]string)
+	for _, pkg := range results[0].Packages {
+		if pkg.Notice != "" {
+			t.Errorf("Expected no malformed YAML notice, got %q", pkg.Notice)
+		}
+		found[pkg.Name] = pkg.Version
+	}
+	if found["left-pad"] != "1.3.0" || found["@scoped/package"] != "2.0.0" {
+		t.Errorf("Expected both legacy entries to be flagged, got %v", found)
+	}
+}
+
+// Test that AffectedVersions is sorted and identical across repeated parses
+func TestAffectedVersionsSorted(t *testing.T) {
+	content := `"left-pad@^1.0.0":
+  version "1.0.0"
+`
+	lockfile := filepath.Join(t.TempDir(), "yarn.lock")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.10.0": true, "1.2.0": true, "1.9.1": true, "2.0.0-beta": true, "2.0.0-alpha": true, "0.9.0": true},
+	}
+	expected := []string{"0.9.0", "1.2.0", "1.9.1", "1.10.0", "2.0.0-alpha", "2.0.0-beta"}
+
+	for i := 0; i < 20; i++ {
+		packages, _, _, _ := parseYarnLock(lockfile, affected, scanOptions{}, nil)
+		if len(packages) != 1 {
+			t.Fatalf("Expected 1 package, got %d", len(packages))
+		}
+		if strings.Join(packages[0].AffectedVersions, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Expected %v, got %v", expected, packages[0].AffectedVersions)
+		}
+	}
+
+	pkg, _ := checkPackage(npmSemverComparator{}, "left-pad", "1.0.0", affected)
+	if strings.Join(pkg.AffectedVersions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected checkPackage to sort versions too, got %v", pkg.AffectedVersions)
+	}
+}
+
+// Test splitting scoped bun.lock keys, including bare names and the npm: protocol
+func TestSplitBunPackageKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		name    string
+		version string
+	}{
+		{"left-pad@1.3.0", "left-pad", "1.3.0"},
+		{"@scope/pkg@1.0.0", "@scope/pkg", "1.0.0"},
+		{"@scope/pkg", "@scope/pkg", ""},
+		{"@scope/pkg@npm:1.0.0", "@scope/pkg", "1.0.0"},
+		{"alias@npm:@scope/pkg@2.0.0", "alias", "2.0.0"},
+		{"left-pad", "left-pad", ""},
+	}
+
+	for _, test := range tests {
+		name, version := splitBunPackageKey(test.key)
+		if name != test.name || version != test.version {
+			t.Errorf("splitBunPackageKey(%q) = %q, %q, want %q, %q", test.key, name, version, test.name, test.version)
+		}
+	}
+
+	content := `{
+  "lockfileVersion": 0,
+  "packages": {
+    "@scope/pkg@npm:1.0.0": {},
+    "@other/pkg": {"version": "2.0.0"}
+  }
+}`
+	lockfile := filepath.Join(t.TempDir(), "bun.lock")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"@scope/pkg": {"1.0.0": true},
+		"@other/pkg": {"2.0.0": true},
+	}
+	packages, _, hasAffected, _ := parseBunLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected || len(packages) != 2 {
+		t.Errorf("Expected both scoped packages to be flagged, got %+v", packages)
+	}
+}
+
+// Test that local file:/link: overrides sharing a compromised name are surfaced as notices
+func TestLocalOverrideNotice(t *testing.T) {
+	tempDir := t.TempDir()
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+		"is-odd":   {"3.0.1": true},
+	}
+
+	npmLock := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/left-pad": {"version": "0.0.1", "resolved": "file:../forks/left-pad"},
+    "node_modules/is-odd": {"resolved": "packages/is-odd", "link": true},
+    "node_modules/local-only": {"version": "1.0.0", "resolved": "file:../local-only"}
+  }
+}`
+	npmPath := filepath.Join(tempDir, "package-lock.json")
+	if err := os.WriteFile(npmPath, []byte(npmLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, notices, hasAffected, _ := parseNPMLock(npmPath, affected, scanOptions{}, nil)
+	if hasAffected {
+		t.Error("Expected local overrides not to count as affected")
+	}
+	joined := strings.Join(notices, "\n")
+	if !strings.Contains(joined, "file:../forks/left-pad") || !strings.Contains(joined, "link:packages/is-odd") {
+		t.Errorf("Expected local override notices for left-pad and is-odd, got %q", notices)
+	}
+	if strings.Contains(joined, "local-only") {
+		t.Error("Expected local packages with unaffected names to be ignored")
+	}
+
+	yarnLock := `"left-pad@file:../forks/left-pad":
+  version "0.0.1"
+`
+	yarnPath := filepath.Join(tempDir, "yarn.lock")
+	if err := os.WriteFile(yarnPath, []byte(yarnLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, notices, _, _ = parseYarnLock(yarnPath, affected, scanOptions{}, nil)
+	found := false
+	for _, notice := range notices {
+		if strings.HasPrefix(notice, "left-pad is a local override") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected yarn file: override notice, got %q", notices)
+	}
+}
+
+// Test that --sample picks exactly N lockfiles, reproducibly for a fixed seed
+func TestSampleLockfiles(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 10; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "yarn.lock"), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockfiles, err := findLockfiles(root, []string{"yarn"}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampled := sampleLockfiles(lockfiles, 3, 42)
+	if len(sampled) != 3 {
+		t.Fatalf("Expected 3 sampled lockfiles, got %d", len(sampled))
+	}
+	if again := sampleLockfiles(lockfiles, 3, 42); strings.Join(again, ",") != strings.Join(sampled, ",") {
+		t.Errorf("Expected the same seed to pick the same lockfiles, got %v and %v", sampled, again)
+	}
+
+	stats := &scanStats{}
+	scanLockfiles(sampled, map[string]map[string]bool{}, nil, scanOptions{}, stats)
+	if stats.FilesParsed != 3 {
+		t.Errorf("Expected exactly 3 lockfiles to be scanned, got %d", stats.FilesParsed)
+	}
+
+	if all := sampleLockfiles(lockfiles, 20, 42); len(all) != 10 {
+		t.Errorf("Expected an oversized sample to keep every lockfile, got %d", len(all))
+	}
+}
+
+// Test that YAML output round-trips into ScanResult with the JSON field names
+func TestWriteYAML(t *testing.T) {
+	result := ScanResult{
+		Root: "/test",
+		Results: []Result{{
+			LockFile: "yarn.lock",
+			Packages: []Package{
+				{Name: "left-pad", Version: "1.3.0", IsAffected: true, AffectedVersions: []string{"1.3.0"}, Confidence: confidenceHigh},
+				{Name: "is-odd", Version: "3.0.1", IsWarning: true, AffectedVersions: []string{"3.0.0"}},
+			},
+		}},
+		AnyAffected: true,
+		AnyWarnings: true,
+		Summary:     Summary{TotalLockfiles: 1, TotalPackages: 2, TotalWarnings: 1, TotalCompromised: 1},
+	}
+
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "lockFile: yarn.lock") || !strings.Contains(buf.String(), "totalCompromised: 1") {
+		t.Errorf("Expected JSON-equivalent field names, got:\n%s", buf.String())
+	}
+
+	var parsed ScanResult
+	if err := yaml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, result) {
+		t.Errorf("Expected YAML to round-trip\ngot:  %+v\nwant: %+v", parsed, result)
+	}
+}
+
+// Test that a version-less npm entry falls back to the version in its resolved tarball URL
+func TestParseNPMLockVersionFromResolved(t *testing.T) {
+	content := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/@scoped/package": {
+      "resolved": "https://registry.npmjs.org/@scoped/package/-/package-2.0.0.tgz"
+    },
+    "node_modules/from-git": {
+      "resolved": "git+ssh://git@github.com/example/from-git.git#abc123"
+    }
+  }
+}`
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"@scoped/package": {"2.0.0": true}}
+	packages, _, hasAffected, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected || len(packages) != 1 || packages[0].Version != "2.0.0" {
+		t.Errorf("Expected @scoped/package@2.0.0 from the resolved URL, got %+v", packages)
+	}
+
+	tests := map[string]string{
+		"https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz":       "1.3.0",
+		"https://registry.npmjs.org/left-pad/-/left-pad-1.3.0-beta.1.tgz": "1.3.0-beta.1",
+		"git+ssh://git@github.com/example/left-pad.git#abc123":           "",
+	}
+	for resolved, expected := range tests {
+		if version := versionFromResolved("left-pad", resolved); version != expected {
+			t.Errorf("versionFromResolved(%q) = %q, want %q", resolved, version, expected)
+		}
+	}
+}
+
+// Test that deep paths collapse deterministically for display while JSON keeps the full path
+func TestCollapsePaths(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "packages", "a", "b", "c", "d", "package-lock.json")
+
+	collapsed := collapsePath(deep, root)
+	expected := filepath.FromSlash("packages/a/.../d/package-lock.json")
+	if collapsed != expected {
+		t.Errorf("Expected %s, got %s", expected, collapsed)
+	}
+	if shallow := collapsePath(filepath.Join(root, "packages", "a", "yarn.lock"), root); shallow != filepath.FromSlash("packages/a/yarn.lock") {
+		t.Errorf("Expected shallow paths to stay intact, got %s", shallow)
+	}
+
+	result := ScanResult{Root: root, Results: []Result{{LockFile: deep}}}
+	display := collapseResultPaths(result)
+	if display.Results[0].LockFile != expected {
+		t.Errorf("Expected display copy to be collapsed, got %s", display.Results[0].LockFile)
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed ScanResult
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Results[0].LockFile != deep {
+		t.Errorf("Expected JSON to retain the full path, got %s", parsed.Results[0].LockFile)
+	}
+}
+
+// Test that v-prefixed and zero-padded versions match their canonical form in both directions
+func TestNormalizeVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.3.0":         "1.3.0",
+		"v1.3.0":        "1.3.0",
+		" V1.3.0 ":      "1.3.0",
+		"=1.3.0":        "1.3.0",
+		"01.03.00":      "1.3.0",
+		"1.0.0_beta.1":  "1.0.0-beta.1",
+		"1.0.0-rc.01":   "1.0.0-rc.01",
+		"1.0.0+build.1": "1.0.0+build.1",
+	}
+	for input, expected := range tests {
+		if normalized := normalizeVersion(input); normalized != expected {
+			t.Errorf("normalizeVersion(%q) = %q, want %q", input, normalized, expected)
+		}
+	}
+
+	affected, err := parseExploitedPackages(strings.NewReader("left-pad@v1.3.0\nis-odd@3.0.1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "node_modules/left-pad": {"version": "1.3.0"},
+    "node_modules/is-odd": {"version": "03.0.01"}
+  }
+}`
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, _, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil)
+	flagged := 0
+	for _, pkg := range packages {
+		if pkg.IsAffected {
+			flagged++
+		}
+	}
+	if flagged != 2 {
+		t.Errorf("Expected v1.3.0 and 03.0.01 to match their canonical forms, got %+v", packages)
+	}
+}
+
+// Test that severities from an enriched list propagate into findings and drive --sort severity
+func TestSeverityFromList(t *testing.T) {
+	list := `# name@version severity
+left-pad@1.3.0 low
+is-odd@3.0.1 CRITICAL
+@scoped/package@2.0.0 medium
+untagged@1.0.0
+`
+	affected, severities, err := parseExploitedList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(affected) != 4 {
+		t.Fatalf("Expected 4 listed packages, got %d", len(affected))
+	}
+
+	results := []Result{
+		{LockFile: "a/yarn.lock", Packages: []Package{
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			{Name: "untagged", Version: "1.0.0", IsAffected: true},
+		}},
+		{LockFile: "b/package-lock.json", Packages: []Package{
+			{Name: "@scoped/package", Version: "2.0.0", IsAffected: true},
+			{Name: "is-odd", Version: "3.0.1", IsAffected: true},
+		}},
+	}
+
+	applySeverities(results, affected, severities)
+	if results[0].Packages[0].Severity != severityLow || results[1].Packages[1].Severity != severityCritical {
+		t.Errorf("Expected severities to propagate, got %+v", results)
+	}
+	if results[0].Packages[1].Severity != "" {
+		t.Errorf("Expected untagged entries to carry no severity, got %q", results[0].Packages[1].Severity)
+	}
+
+	sortResultsBySeverity(results)
+	if results[0].LockFile != "b/package-lock.json" {
+		t.Errorf("Expected the result with the critical finding first, got %s", results[0].LockFile)
+	}
+	var order []string
+	for _, result := range results {
+		for _, pkg := range result.Packages {
+			order = append(order, pkg.Name)
+		}
+	}
+	if strings.Join(order, ",") != "is-odd,@scoped/package,left-pad,untagged" {
+		t.Errorf("Expected findings ordered by severity, got %v", order)
+	}
+
+	output, err := json.Marshal(results[0].Packages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), `"severity":"critical"`) {
+		t.Errorf("Expected JSON to include severity, got %s", output)
+	}
+}
+
+// Test that --no-summary keeps findings but drops the summary block and timing footer
+func TestPrintResultsNoSummary(t *testing.T) {
+	result := ScanResult{
+		AnyAffected: true,
+		Results: []Result{
+			{LockFile: "package-lock.json", Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}},
+		},
+		Summary: Summary{TotalLockfiles: 1, TotalPackages: 1, TotalCompromised: 1},
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printResults(result, "", false, true, false, false, true, time.Now())
+	os.Stdout = stdout
+	w.Close()
+
+	var captured bytes.Buffer
+	if _, err := captured.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	output := captured.String()
+	if !strings.Contains(output, "left-pad@1.3.0") {
+		t.Errorf("Expected findings in output, got:\n%s", output)
+	}
+	for _, line := range []string{"Scan Summary", "Lockfiles scanned", "Scan completed in"} {
+		if strings.Contains(output, line) {
+			t.Errorf("Expected %q to be omitted, got:\n%s", line, output)
+		}
+	}
+}
+
+// Test that a scan with only suspicious findings is not reported as passed
+func TestPrintResultsSuspiciousOnly(t *testing.T) {
+	results := []Result{
+		{LockFile: "package-lock.json", Packages: []Package{suspiciousPackage("left-pad", "1.3.0", "integrity is sha1 only")}},
+	}
+	result := ScanResult{
+		Results:       results,
+		AnySuspicious: anySuspicious(results),
+		Summary:       summarizeResults(results, 1),
+	}
+	if result.Summary.TotalSuspicious != 1 {
+		t.Fatalf("Expected one suspicious package in the summary, got %+v", result.Summary)
+	}
+
+	output := captureOutput(t, &os.Stdout, func() { printResults(result, "", false, false, false, false, true, time.Now()) })
+	if strings.Contains(output, "SCAN PASSED") || !strings.Contains(output, "SUSPICIOUS FINDINGS") {
+		t.Errorf("Expected a suspicious banner instead of a pass, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Suspicious packages: ⚠️ 1") {
+		t.Errorf("Expected the suspicious total in the summary, got:\n%s", output)
+	}
+}
+
+// Test that a parser panic on a malformed lockfile is reported as a parse error
+func TestParseRecoveredPanic(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "package-lock.json")
+	if err := os.WriteFile(malformed, []byte(`{"lockfileVersion": 3, "packages": ["not", "an", "object"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A parser trusting the shape of the file, as an unchecked interface{} cast would
+	naive := func() ([]Package, []string, bool, bool) {
+		content, _ := os.ReadFile(malformed)
+		var data map[string]interface{}
+		json.Unmarshal(content, &data)
+		for key := range data["packages"].(map[string]interface{}) {
+			return []Package{{Name: key}}, nil, true, false
+		}
+		return nil, nil, false, false
+	}
+
+	packages, notices, hasAffected, hasWarnings := parseRecovered(malformed, naive)
+	if hasAffected || hasWarnings || len(packages) != 0 {
+		t.Error("Expected a recovered panic to report no findings")
+	}
+	if len(notices) != 1 || !strings.HasPrefix(notices[0], "parse error:") {
+		t.Fatalf("Expected a parse error notice, got %q", notices)
+	}
+
+	// The scan carries on with the next file
+	valid := filepath.Join(dir, "sub", "package-lock.json")
+	os.MkdirAll(filepath.Dir(valid), 0755)
+	if err := os.WriteFile(valid, []byte(`{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, anyAffected, _ := scanLockfiles([]string{malformed, valid}, map[string]map[string]bool{"left-pad": {"1.3.0": true}}, nil, scanOptions{}, nil)
+	if !anyAffected || len(results) != 1 || results[0].LockFile != valid {
+		t.Errorf("Expected the valid lockfile to still be scanned, got %+v", results)
+	}
+}
+
+// Test that --scope and --unscoped-only narrow findings by npm scope
+func TestFilterResultsByScope(t *testing.T) {
+	results := []Result{
+		{LockFile: "a/package-lock.json", Packages: []Package{
+			{Name: "@babel/core", Version: "7.0.0", IsAffected: true},
+			{Name: "@babel-fake/core", Version: "1.0.0", IsAffected: true},
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+		}},
+		{LockFile: "b/yarn.lock", Packages: []Package{
+			{Name: "@babel/traverse", Version: "7.1.0", IsWarning: true},
+			{Name: "@ctrl/tinycolor", Version: "4.1.1", IsAffected: true},
+		}},
+	}
+
+	names := func(results []Result) string {
+		var names []string
+		for _, result := range results {
+			for _, pkg := range result.Packages {
+				names = append(names, pkg.Name)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+
+	filtered, anyAffected, anyWarnings := filterResultsByScope(results, "@babel", false, false)
+	if got := names(filtered); got != "@babel/core,@babel/traverse" {
+		t.Errorf("Expected only @babel/* findings, got %s", got)
+	}
+	if !anyAffected || !anyWarnings {
+		t.Error("Expected flags recomputed from the remaining findings")
+	}
+
+	filtered, _, anyWarnings = filterResultsByScope(results, "", false, true)
+	if got := names(filtered); got != "left-pad" {
+		t.Errorf("Expected only unscoped findings, got %s", got)
+	}
+	if anyWarnings || len(filtered) != 1 {
+		t.Errorf("Expected lockfiles with no remaining findings to be dropped, got %+v", filtered)
+	}
+
+	filtered, _, _ = filterResultsByScope(results, "", true, false)
+	if got := names(filtered); got != "@babel/core,@babel-fake/core,@babel/traverse,@ctrl/tinycolor" {
+		t.Errorf("Expected only scoped findings, got %s", got)
+	}
+}
+
+// Test that lockfiles found from a nested invocation are reported relative to the repo root
+func TestRepoRelativePaths(t *testing.T) {
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(repo, "packages", "web")
+	if err := os.MkdirAll(filepath.Join(nested, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "app", "package-lock.json"), []byte(`{"lockfileVersion": 3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if root := findRepoRoot(filepath.Join(nested, "app")); root != repo {
+		t.Fatalf("Expected repo root %s, got %q", repo, root)
+	}
+	if root := findRepoRoot(t.TempDir()); root != "" {
+		t.Errorf("Expected no repo root outside a repository, got %q", root)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(nested); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	lockfiles, err := findLockfiles(".", []string{"npm"}, nil, nil, nil, nil, nil)
+	if err != nil || len(lockfiles) != 1 {
+		t.Fatalf("Expected one lockfile, got %v (%v)", lockfiles, err)
+	}
+
+	result := repoRelativeResult(ScanResult{Root: nested, Results: []Result{{LockFile: lockfiles[0]}}}, findRepoRoot("."))
+	if result.Root != repo {
+		t.Errorf("Expected root %s, got %s", repo, result.Root)
+	}
+	if result.Results[0].LockFile != "packages/web/app/package-lock.json" {
+		t.Errorf("Expected repo-relative path, got %s", result.Results[0].LockFile)
+	}
+}
+
+// Test that a canary of a package with a compromised stable version is flagged as suspicious
+func TestFlagPrereleaseFindings(t *testing.T) {
+	lockfile := filepath.Join(t.TempDir(), "package-lock.json")
+	content := `{"lockfileVersion": 3, "packages": {
+		"node_modules/left-pad": {"version": "1.4.0-canary.2"},
+		"node_modules/is-odd": {"version": "3.0.2"},
+		"node_modules/is-even": {"version": "2.0.0-rc.1"}
+	}}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+		"is-odd":   {"3.0.1": true},
+		"is-even":  {"2.0.0-rc.0": true},
+	}
+	results, _, _ := scanLockfiles([]string{lockfile}, affected, nil, scanOptions{}, nil)
+	flagPrereleaseFindings(results, affected)
+
+	var suspicious []Package
+	for _, pkg := range results[0].Packages {
+		if pkg.IsSuspicious {
+			suspicious = append(suspicious, pkg)
+		}
+	}
+	if len(suspicious) != 1 || suspicious[0].Name != "left-pad" || suspicious[0].Version != "1.4.0-canary.2" {
+		t.Fatalf("Expected only left-pad@1.4.0-canary.2 to be flagged, got %+v", suspicious)
+	}
+	if !strings.Contains(suspicious[0].Notice, "1.3.0") {
+		t.Errorf("Expected the notice to name the compromised stable version, got %q", suspicious[0].Notice)
+	}
+
+	if !isPrerelease("v1.0.0-alpha") || isPrerelease("1.0.0") || isPrerelease("1.0.0+build-7") {
+		t.Error("Expected isPrerelease to detect only pre-release tags")
+	}
+}
+
+// Test that exclude patterns apply relative to each root when scanning several roots
+func TestFindLockfilesInRootsExcludePerRoot(t *testing.T) {
+	base := t.TempDir()
+	rootA := filepath.Join(base, "a")
+	rootB := filepath.Join(base, "services", "b")
+	paths := []string{
+		filepath.Join(rootA, "package-lock.json"),
+		filepath.Join(rootA, "dist", "package-lock.json"),
+		filepath.Join(rootB, "app", "yarn.lock"),
+		filepath.Join(rootB, "dist", "bundle", "yarn.lock"),
+		// Under dist relative to the common parent, but not relative to root b
+		filepath.Join(rootB, "app", "dist-docs", "package-lock.json"),
+	}
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockfiles, err := findLockfilesInRoots([]string{rootA, rootB, rootA}, []string{"yarn", "npm"}, nil, []string{"dist/**"}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{paths[0], paths[4], paths[2]}
+	if !reflect.DeepEqual(lockfiles, expected) {
+		t.Errorf("Expected %v, got %v", expected, lockfiles)
+	}
+
+	if root := commonRoot([]string{rootA, rootB}); root != base {
+		t.Errorf("Expected common root %s, got %s", base, root)
+	}
+	if root := commonRoot([]string{rootB}); root != rootB {
+		t.Errorf("Expected a single root to be its own common root, got %s", root)
+	}
+}
+
+// Test that --check prints nothing to stdout and reports only through the exit code.
+// main is run in a re-executed test binary so its output and os.Exit can be observed
+func TestCheckMode(t *testing.T) {
+	if args := os.Getenv("SHAI_HULUD_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"shai-hulud-scanner"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+
+	clean := t.TempDir()
+	dirty := t.TempDir()
+	files := map[string]string{
+		filepath.Join(clean, "package-lock.json"): `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.2.0"}}}`,
+		filepath.Join(dirty, "package-lock.json"): `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(root string) (string, string, int) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCheckMode$")
+		cmd.Env = append(os.Environ(), "SHAI_HULUD_MAIN_ARGS="+strings.Join([]string{"--check", "--root-dir", root, "--list-inline", "left-pad@1.3.0"}, "\n"))
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		code := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return stdout.String(), stderr.String(), code
+	}
+
+	stdout, _, code := run(clean)
+	if stdout != "" || code != 0 {
+		t.Errorf("Expected a clean tree to exit 0 silently, got code %d and stdout %q", code, stdout)
+	}
+
+	stdout, stderr, code := run(dirty)
+	if stdout != "" || code != 2 {
+		t.Errorf("Expected a dirty tree to exit 2 silently, got code %d and stdout %q", code, stdout)
+	}
+	if stderr != "COMPROMISED=1 WARNINGS=0 LOCKFILES=1\n" {
+		t.Errorf("Expected a one-line summary on stderr, got %q", stderr)
+	}
+}
+
+// Test that each --color mode decides ANSI codes regardless of whether stdout is a terminal
+func TestColorModes(t *testing.T) {
+	result := ScanResult{
+		AnyAffected: true,
+		Results: []Result{
+			{LockFile: "package-lock.json", Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}},
+		},
+		Summary: Summary{TotalLockfiles: 1, TotalPackages: 1, TotalCompromised: 1},
+	}
+
+	tests := []struct {
+		mode      string
+		wantColor bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"auto", false}, // a pipe is never a terminal
+	}
+
+	for _, test := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = w
+		printResults(result, "", false, false, false, false, !colorEnabled(test.mode, w), time.Now())
+		os.Stdout = stdout
+		w.Close()
+
+		var captured bytes.Buffer
+		if _, err := captured.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+
+		if hasColor := strings.Contains(captured.String(), "\033["); hasColor != test.wantColor {
+			t.Errorf("--color=%s: expected ANSI codes %v, got %v", test.mode, test.wantColor, hasColor)
+		}
+	}
+}
+
+// Test that npm-shrinkwrap.json takes precedence over a sibling package-lock.json
+func TestShrinkwrapPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	shrinkwrap := `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`
+	packageLock := `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.1.0"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "npm-shrinkwrap.json"), []byte(shrinkwrap), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "package-lock.json"), []byte(packageLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockfiles, err := findLockfiles(dir, []string{"npm"}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockfiles) != 1 || filepath.Base(lockfiles[0]) != "npm-shrinkwrap.json" {
+		t.Fatalf("Expected only npm-shrinkwrap.json to be scanned, got %v", lockfiles)
+	}
+
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	results, anyAffected, anyWarnings := scanLockfiles(lockfiles, affected, nil, scanOptions{}, nil)
+	if !anyAffected || anyWarnings {
+		t.Errorf("Expected the shrinkwrap's left-pad@1.3.0 to drive findings, got affected=%v warnings=%v", anyAffected, anyWarnings)
+	}
+	if len(results) != 1 || len(results[0].Packages) != 1 || results[0].Packages[0].Version != "1.3.0" {
+		t.Errorf("Expected a single left-pad@1.3.0 finding, got %+v", results)
+	}
+}
+
+// Test that npm v3 optional and peer flags are carried onto findings and can be ignored
+func TestParseNPMLockOptionalPeer(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "package-lock.json")
+	content := `{"lockfileVersion": 3, "packages": {
+		"": {"name": "app"},
+		"node_modules/left-pad": {"version": "1.3.0", "peer": true},
+		"node_modules/fsevents": {"version": "2.3.3", "optional": true},
+		"node_modules/chalk": {"version": "5.6.1"}
+	}}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+		"fsevents": {"2.3.3": true},
+		"chalk":    {"5.6.1": true},
+	}
+	packages, _, _, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil)
+	flags := make(map[string]Package)
+	for _, pkg := range packages {
+		flags[pkg.Name] = pkg
+	}
+	if !flags["left-pad"].Peer || flags["left-pad"].Optional {
+		t.Errorf("Expected left-pad to be a peer dependency, got %+v", flags["left-pad"])
+	}
+	if !flags["fsevents"].Optional || flags["fsevents"].Peer {
+		t.Errorf("Expected fsevents to be optional, got %+v", flags["fsevents"])
+	}
+
+	results := []Result{{LockFile: lockfile, Packages: packages}}
+	filtered, anyAffected, _ := filterResultsByDependencyType(results, true, false)
+	if !anyAffected || len(filtered) != 1 || len(filtered[0].Packages) != 2 {
+		t.Fatalf("Expected only the peer finding to be dropped, got %+v", filtered)
+	}
+	for _, pkg := range filtered[0].Packages {
+		if pkg.Name == "left-pad" {
+			t.Error("Expected the peer-flagged compromised package to be excluded by --ignore-peer")
+		}
+	}
+}
+
+// Test that --latest-only keeps only the newest affected version of a package
+func TestCollapseToLatest(t *testing.T) {
+	results := []Result{
+		{LockFile: "apps/a/package-lock.json", Packages: []Package{{Name: "left-pad", Version: "1.2.0", IsAffected: true}}},
+		{LockFile: "apps/b/package-lock.json", Packages: []Package{
+			{Name: "left-pad", Version: "1.10.0", IsAffected: true},
+			{Name: "left-pad", Version: "2.0.0", IsWarning: true},
+		}},
+		{LockFile: "apps/c/yarn.lock", Packages: []Package{
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			{Name: "yarn.lock", Notice: "informational"},
+		}},
+	}
+
+	collapsed, dropped := collapseToLatest(results)
+	if dropped != 2 {
+		t.Errorf("Expected 2 older versions to be collapsed, got %d", dropped)
+	}
+
+	var kept []string
+	for _, result := range collapsed {
+		for _, pkg := range result.Packages {
+			kept = append(kept, pkg.Name+"@"+pkg.Version)
+		}
+	}
+	expected := []string{"left-pad@1.10.0", "left-pad@2.0.0", "yarn.lock@"}
+	if !reflect.DeepEqual(kept, expected) {
+		t.Errorf("Expected %v, got %v", expected, kept)
+	}
+}
+
+// Test that a lockfile saved with a UTF-8 byte order mark is still parsed
+func TestParseNPMLockWithBOM(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "package-lock.json")
+	content := "\xef\xbb\xbf" + `{"lockfileVersion": 3, "packages": {
+		"": {"name": "app"},
+		"node_modules/chalk": {"version": "5.6.1"}
+	}}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"chalk": {"5.6.1": true}}
+	packages, _, hasAffected, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected || len(packages) != 1 || packages[0].Name != "chalk" {
+		t.Errorf("Expected compromised chalk to be found despite the BOM, got %+v", packages)
+	}
+}
+
+// Test that the root package is checked, by its package.json name, only under --include-root
+func TestParseNPMLockIncludeRoot(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "package-lock.json")
+	content := `{"lockfileVersion": 3, "packages": {
+		"": {"name": "placeholder"},
+		"node_modules/left-pad": {"version": "1.3.0"}
+	}}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "@ctrl/tinycolor", "version": "4.1.1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	affected := map[string]map[string]bool{"@ctrl/tinycolor": {"4.1.1": true}}
+
+	if packages, _, hasAffected, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil); hasAffected || len(packages) != 0 {
+		t.Errorf("Expected the root package to be skipped by default, got %+v", packages)
+	}
+
+	packages, _, hasAffected, _ := parseNPMLock(lockfile, affected, scanOptions{includeRoot: true}, nil)
+	if !hasAffected || len(packages) != 1 || packages[0].Name != "@ctrl/tinycolor" || packages[0].Version != "4.1.1" {
+		t.Errorf("Expected the root package to be flagged under --include-root, got %+v", packages)
+	}
+}
+
+// Test that a lockfileVersion 1 tree whose packages list their ancestors again terminates
+// and reports the compromised package once
+func TestParseNPMLockV1Cycle(t *testing.T) {
+	// chalk -> debug -> chalk -> debug ... as deep as a hostile file cares to go
+	versions := map[string]string{"chalk": "5.6.1", "debug": "4.4.2"}
+	child, entry := "debug", `{"version": "4.4.2"}`
+	for depth := 0; depth < 200; depth++ {
+		parent := map[string]string{"chalk": "debug", "debug": "chalk"}[child]
+		entry = fmt.Sprintf(`{"version": "%s", "dependencies": {"%s": %s}}`, versions[parent], child, entry)
+		child = parent
+	}
+	tree := fmt.Sprintf(`{"lockfileVersion": 1, "dependencies": {"%s": %s}}`, child, entry)
+
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "package-lock.json")
+	if err := os.WriteFile(lockfile, []byte(tree), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"debug": {"4.4.2": true}}
+	packages, _, hasAffected, _ := parseNPMLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected || len(packages) != 1 || packages[0].Name != "debug" || packages[0].Version != "4.4.2" {
+		t.Errorf("Expected debug@4.4.2 to be flagged exactly once, got %+v", packages)
+	}
+}
+
+// Test that every installed version of a package is checked, not just the last one listed
+func TestParseYarnLockMultipleVersions(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "yarn.lock")
+	content := `# yarn lockfile v1
+
+debug@^4.4.2:
+  version "4.4.2"
+  resolved "https://registry.yarnpkg.com/debug/-/debug-4.4.2.tgz"
+
+debug@~4.3.0:
+  version "4.3.7"
+  resolved "https://registry.yarnpkg.com/debug/-/debug-4.3.7.tgz"
+`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"debug": {"4.4.2": true}}
+	packages, _, hasAffected, hasWarnings := parseYarnLock(lockfile, affected, scanOptions{}, nil)
+	if !hasAffected || !hasWarnings {
+		t.Errorf("Expected both the compromised and the safe version to be reported, got %+v", packages)
+	}
+	found := make(map[string]bool)
+	for _, pkg := range packages {
+		found[pkg.Version] = pkg.IsAffected
+	}
+	if affected, ok := found["4.4.2"]; !ok || !affected {
+		t.Errorf("Expected debug@4.4.2 to be reported as compromised, got %+v", packages)
+	}
+	if _, ok := found["4.3.7"]; !ok {
+		t.Errorf("Expected debug@4.3.7 to be reported as a warning, got %+v", packages)
+	}
+}
+
+// Test that --context-lines captures the raw yarn.lock entry around a finding and prints it
+func TestContextLinesYarnLock(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "yarn.lock")
+	content := `# yarn lockfile v1
+
+debug@^4.4.2:
+  version "4.4.2"
+  resolved "https://registry.yarnpkg.com/debug/-/debug-4.4.2.tgz"
+`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"debug": {"4.4.2": true}}
+	packages, _, _, _ := parseYarnLock(lockfile, affected, scanOptions{contextLines: 2}, nil)
+	if len(packages) != 1 || packages[0].Context == nil {
+		t.Fatalf("Expected one finding with lockfile context, got %+v", packages)
+	}
+	context := packages[0].Context
+	if context.Line != 3 || context.StartLine != 1 || len(context.Lines) != 5 {
+		t.Errorf("Expected lines 1-5 around line 3, got %+v", context)
+	}
+
+	result := ScanResult{
+		AnyAffected: true,
+		Results:     []Result{{LockFile: lockfile, Packages: packages}},
+		Summary:     Summary{TotalLockfiles: 1, TotalPackages: 1, TotalCompromised: 1},
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printResults(result, "", false, true, false, false, true, time.Now())
+	os.Stdout = stdout
+	w.Close()
+
+	var captured bytes.Buffer
+	if _, err := captured.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	output := captured.String()
+	for _, line := range []string{"    > 3 | debug@^4.4.2:", `      4 |   version "4.4.2"`, "      1 | # yarn lockfile v1"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected context line %q in output, got:\n%s", line, output)
+		}
+	}
+}
+
+// Test that a pnpm entry with a known-bad integrity is compromised whatever version it claims
+func TestPnpmKnownBadIntegrity(t *testing.T) {
+	list := "left-pad@1.3.0 critical sha512-BADBADBAD+/w==\nis-odd@3.0.1\n"
+	integrities, err := parseKnownBadIntegrities(strings.NewReader(list))
+	if err != nil || integrities["sha512-BADBADBAD+/w=="] != "left-pad@1.3.0" || len(integrities) != 1 {
+		t.Fatalf("Expected one known-bad integrity, got %v (%v)", integrities, err)
+	}
+	affected, severities, err := parseExploitedList(strings.NewReader(list))
+	if err != nil || !affected["left-pad"]["1.3.0"] || severities["left-pad@1.3.0"] != severityCritical {
+		t.Fatalf("Expected the integrity to leave the entry and its severity intact, got %v %v", affected, severities)
+	}
+
+	content := `lockfileVersion: 5.4
+
+packages:
+  /left-pad@1.3.1:
+    resolution: {integrity: sha512-BADBADBAD+/w==}
+    engines: {node: '>=0.10.0'}
+
+  /is-odd@3.0.1:
+    resolution:
+      integrity: sha512-goodhash==
+`
+	lockfile := filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _, hasAffected, _ := parsePNMLock(lockfile, affected, scanOptions{knownBad: integrities}, nil)
+	if !hasAffected || len(packages) != 2 {
+		t.Fatalf("Expected two compromised entries, got %+v", packages)
+	}
+	for _, pkg := range packages {
+		if !pkg.IsAffected {
+			t.Errorf("Expected a compromised finding, got %+v", pkg)
+		}
+	}
+	if packages[0].Name != "left-pad" || packages[0].Version != "1.3.1" || packages[0].Confidence != confidenceHigh ||
+		!strings.Contains(packages[0].Notice, "left-pad@1.3.0") {
+		t.Errorf("Expected left-pad@1.3.1 to be caught by its integrity with high confidence, got %+v", packages[0])
+	}
+	// The list gives no hash for is-odd, so its integrity confirms nothing
+	if packages[1].Integrity != "sha512-goodhash==" || packages[1].Confidence != confidenceMedium {
+		t.Errorf("Expected the block-form integrity to be extracted at medium confidence, got %+v", packages[1])
+	}
+}
+
+// Test that every lockfile format catches an entry by its known-bad integrity, including a
+// transitive dependency, whatever version the entry claims
+func TestKnownBadIntegrityEveryFormat(t *testing.T) {
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	knownBad := map[string]string{"sha512-BADBADBAD+/w==": "left-pad@1.3.0"}
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"package-lock.json", `{
+  "name": "app",
+  "version": "1.0.0",
+  "lockfileVersion": 3,
+  "requires": true,
+  "packages": {
+    "": {
+      "name": "app",
+      "version": "1.0.0",
+      "dependencies": {
+        "is-odd": "^3.0.0"
+      }
+    },
+    "node_modules/is-odd": {
+      "version": "3.0.1",
+      "resolved": "https://registry.npmjs.org/is-odd/-/is-odd-3.0.1.tgz",
+      "integrity": "sha512-goodhash==",
+      "dependencies": {
+        "left-pad": "^1.3.0"
+      }
+    },
+    "node_modules/left-pad": {
+      "version": "1.3.1",
+      "resolved": "https://registry.npmjs.org/left-pad/-/left-pad-1.3.1.tgz",
+      "integrity": "sha512-BADBADBAD+/w=="
+    }
+  }
+}
+`},
+		{"yarn.lock", `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+is-odd@^3.0.0:
+  version "3.0.1"
+  resolved "https://registry.yarnpkg.com/is-odd/-/is-odd-3.0.1.tgz"
+  integrity sha512-goodhash==
+  dependencies:
+    left-pad "^1.3.0"
+
+left-pad@^1.3.0:
+  version "1.3.1"
+  resolved "https://registry.yarnpkg.com/left-pad/-/left-pad-1.3.1.tgz"
+  integrity sha512-BADBADBAD+/w==
+`},
+		{"pnpm-lock.yaml", `lockfileVersion: '9.0'
+
+settings:
+  autoInstallPeers: true
+  excludeLinksFromLockfile: false
+
+importers:
+
+  .:
+    dependencies:
+      is-odd:
+        specifier: ^3.0.0
+        version: 3.0.1
+
+packages:
+
+  is-odd@3.0.1:
+    resolution: {integrity: sha512-goodhash==}
+
+  left-pad@1.3.1:
+    resolution: {integrity: sha512-BADBADBAD+/w==}
+
+snapshots:
+
+  is-odd@3.0.1:
+    dependencies:
+      left-pad: 1.3.1
+
+  left-pad@1.3.1: {}
+`},
+		{"bun.lock", `{
+  "lockfileVersion": 1,
+  "workspaces": {
+    "": {
+      "name": "app",
+      "dependencies": {
+        "is-odd": "^3.0.0",
+      },
+    },
+  },
+  "packages": {
+    "is-odd": ["is-odd@3.0.1", "", { "dependencies": { "left-pad": "^1.3.0" } }, "sha512-goodhash=="],
+
+    "left-pad": ["left-pad@1.3.1", "", {}, "sha512-BADBADBAD+/w=="],
+  }
+}
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			lockfile := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(lockfile, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			packages, _, hasAffected, _ := scanLockfileAs(lockfile, lockfileFormat(tt.file, nil), affected, scanOptions{knownBad: knownBad}, nil)
+			if !hasAffected || len(packages) != 1 {
+				t.Fatalf("Expected one compromised entry, got %+v", packages)
+			}
+			pkg := packages[0]
+			if pkg.Name != "left-pad" || pkg.Version != "1.3.1" || !pkg.IsAffected || pkg.Confidence != confidenceHigh ||
+				!strings.Contains(pkg.Notice, "compromised left-pad@1.3.0 tarball") {
+				t.Errorf("Expected left-pad@1.3.1 to be caught by its integrity, got %+v", pkg)
+			}
+
+			// Without the list's hashes the same entry is only a warning
+			if packages, _, hasAffected, _ := scanLockfileAs(lockfile, lockfileFormat(tt.file, nil), affected, scanOptions{}, nil); hasAffected ||
+				len(packages) != 1 || !packages[0].IsWarning {
+				t.Errorf("Expected a warning without known-bad integrities, got %+v", packages)
+			}
+		})
+	}
+}
+
+// Test that every lockfile format records a finding's integrity as one algorithm-hash token
+func TestNormalizedIntegrityAcrossFormats(t *testing.T) {
+	const sha512 = "sha512-q9RUmXsEp0ij9PDFoSDJaA=="
+	tests := []struct {
+		file     string
+		content  string
+		expected string
+	}{
+		{"package-lock.json", `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0",
+			"resolved": "https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz", "integrity": "sha1-W5AJhEj4q4fBPcWRVuXvwDQrEoU= ` + sha512 + `"}}}`, sha512},
+		{"npm-shrinkwrap.json", `{"lockfileVersion": 1, "dependencies": {"left-pad": {"version": "1.3.0", "integrity": "` + sha512 + `"}}}`, sha512},
+		{"yarn.lock", "left-pad@^1.3.0:\n  version \"1.3.0\"\n  integrity " + sha512 + "\n", sha512},
+		{"yarn.lock", "left-pad@^1.3.0:\n  version \"1.3.0\"\n  resolved \"https://registry.yarnpkg.com/left-pad/-/left-pad-1.3.0.tgz#5b90098448f8ab87c13dc59156e5efc0342b1285\"\n",
+			"sha1-W5AJhEj4q4fBPcWRVuXvwDQrEoU="},
+		{"yarn.lock", "\"left-pad@npm:^1.3.0\":\n  version: 1.3.0\n  checksum: 10c0/abcdef\n", "10c0/abcdef"},
+		{"pnpm-lock.yaml", "lockfileVersion: 5.4\n\npackages:\n  /left-pad@1.3.0:\n    resolution: {integrity: " + sha512 + "}\n", sha512},
+		{"bun.lock", `{"lockfileVersion": 1, "workspaces": {"": {"dependencies": {"left-pad": "^1.3.0"}}},
+			"packages": {"left-pad": ["left-pad@1.3.0", "", {}, "` + sha512 + `"]}}`, sha512},
+	}
+
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	for _, test := range tests {
+		lockfile := filepath.Join(t.TempDir(), test.file)
+		if err := os.WriteFile(lockfile, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		packages, _, hasAffected, _ := parseLockfileAs(lockfile, lockfileFormat(test.file, nil), affected, scanOptions{}, nil)
+		if !hasAffected {
+			t.Errorf("%s: expected left-pad@1.3.0 to be found, got %+v", test.file, packages)
+			continue
+		}
+		for _, pkg := range packages {
+			if pkg.IsAffected && pkg.Integrity != test.expected {
+				t.Errorf("%s: expected integrity %q, got %q", test.file, test.expected, pkg.Integrity)
+			}
+		}
+	}
+}
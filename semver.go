@@ -0,0 +1,387 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed major.minor.patch version with an optional pre-release tag
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseSemver parses a full version, ignoring build metadata
+func parseSemver(version string) (semver, bool) {
+	version, _, _ = strings.Cut(normalizeVersion(version), "+")
+	core, pre, _ := strings.Cut(version, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		numbers[i] = n
+	}
+	return semver{major: numbers[0], minor: numbers[1], patch: numbers[2], pre: pre}, true
+}
+
+// compareSemver orders versions by precedence; a pre-release sorts before its release
+func compareSemver(a, b semver) int {
+	for _, diff := range []int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if diff != 0 {
+			if diff < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case a.pre == b.pre:
+		return 0
+	case a.pre == "":
+		return 1
+	case b.pre == "":
+		return -1
+	}
+
+	idsA, idsB := strings.Split(a.pre, "."), strings.Split(b.pre, ".")
+	for i := 0; i < len(idsA) && i < len(idsB); i++ {
+		if idsA[i] == idsB[i] {
+			continue
+		}
+		numA, errA := strconv.Atoi(idsA[i])
+		numB, errB := strconv.Atoi(idsB[i])
+		switch {
+		case errA == nil && errB == nil:
+			if numA < numB {
+				return -1
+			}
+			return 1
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case idsA[i] < idsB[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(idsA) < len(idsB):
+		return -1
+	case len(idsA) > len(idsB):
+		return 1
+	}
+	return 0
+}
+
+// comparator is a single primitive constraint such as >=1.2.3
+type comparator struct {
+	op      string
+	version semver
+}
+
+// matches reports whether v satisfies the comparator
+func (c comparator) matches(v semver) bool {
+	cmp := compareSemver(v, c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// partialVersionRegex matches a possibly partial version such as 1, 1.2, 1.x or 1.2.3-rc.1
+var partialVersionRegex = regexp.MustCompile(`^[vV=]?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// operatorSpaceRegex joins operators separated from their version, as in ">= 1.2.3"
+var operatorSpaceRegex = regexp.MustCompile(`(<=|>=|<|>|=|~|\^)\s+`)
+
+// parsePartial parses a partial version, returning -1 for missing or wildcard components
+func parsePartial(s string) (major, minor, patch int, pre string, ok bool) {
+	m := partialVersionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, 0, "", false
+	}
+	component := func(part string) int {
+		if part == "" || part == "x" || part == "X" || part == "*" {
+			return -1
+		}
+		n, _ := strconv.Atoi(part)
+		return n
+	}
+	major, minor, patch = component(m[1]), component(m[2]), component(m[3])
+	// Anything after a wildcard is a wildcard too
+	if major == -1 {
+		minor = -1
+	}
+	if minor == -1 {
+		patch = -1
+	}
+	return major, minor, patch, m[4], true
+}
+
+// parseComparator expands one range token such as ^1.2.3, ~1.2 or >=1.0 into primitive comparators
+func parseComparator(token string) ([]comparator, bool) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(token, candidate) {
+			op = candidate
+			token = token[len(candidate):]
+			break
+		}
+	}
+	if token == "" || token == "*" || token == "x" || token == "X" {
+		return nil, op == "" || op == ">=" || op == "="
+	}
+
+	major, minor, patch, pre, ok := parsePartial(token)
+	if !ok {
+		return nil, false
+	}
+	lower := semver{major: max(major, 0), minor: max(minor, 0), patch: max(patch, 0), pre: pre}
+	atLeast := comparator{">=", lower}
+	below := func(major, minor, patch int) comparator {
+		return comparator{"<", semver{major: major, minor: minor, patch: patch, pre: "0"}}
+	}
+	// nextUpper is the first version above the partial version's wildcard range
+	nextUpper := func() comparator {
+		if minor == -1 {
+			return below(major+1, 0, 0)
+		}
+		return below(major, minor+1, 0)
+	}
+
+	switch op {
+	case "^":
+		switch {
+		case major == -1:
+			return nil, true
+		case major > 0 || minor == -1:
+			return []comparator{atLeast, below(major+1, 0, 0)}, true
+		case minor > 0 || patch == -1:
+			return []comparator{atLeast, below(0, minor+1, 0)}, true
+		default:
+			return []comparator{atLeast, below(0, 0, patch+1)}, true
+		}
+	case "~":
+		if major == -1 {
+			return nil, true
+		}
+		if minor == -1 {
+			return []comparator{atLeast, below(major+1, 0, 0)}, true
+		}
+		return []comparator{atLeast, below(major, minor+1, 0)}, true
+	case ">":
+		if patch == -1 {
+			if major == -1 {
+				return []comparator{{"<", semver{pre: "0"}}}, true
+			}
+			return []comparator{{">=", nextUpper().version}}, true
+		}
+		return []comparator{{">", lower}}, true
+	case ">=":
+		return []comparator{atLeast}, true
+	case "<":
+		return []comparator{{"<", lower}}, true
+	case "<=":
+		if patch == -1 {
+			if major == -1 {
+				return nil, true
+			}
+			return []comparator{nextUpper()}, true
+		}
+		return []comparator{{"<=", lower}}, true
+	default:
+		if major == -1 {
+			return nil, true
+		}
+		if patch == -1 {
+			return []comparator{atLeast, nextUpper()}, true
+		}
+		return []comparator{{"=", lower}}, true
+	}
+}
+
+// parseRange parses an npm semver range into alternatives of comparator sets. It supports
+// ||, hyphen ranges, ^, ~, x-ranges, partial versions and the comparison operators
+func parseRange(rng string) ([][]comparator, bool) {
+	var sets [][]comparator
+	for _, alternative := range strings.Split(rng, "||") {
+		alternative = strings.TrimSpace(operatorSpaceRegex.ReplaceAllString(strings.TrimSpace(alternative), "$1"))
+
+		if from, to, found := strings.Cut(alternative, " - "); found {
+			lower, ok := parseComparator(">=" + strings.TrimSpace(from))
+			if !ok {
+				return nil, false
+			}
+			upper, ok := parseComparator("<=" + strings.TrimSpace(to))
+			if !ok {
+				return nil, false
+			}
+			sets = append(sets, append(lower, upper...))
+			continue
+		}
+
+		set := []comparator{}
+		for _, token := range strings.Fields(alternative) {
+			comparators, ok := parseComparator(token)
+			if !ok {
+				return nil, false
+			}
+			set = append(set, comparators...)
+		}
+		sets = append(sets, set)
+	}
+	return sets, true
+}
+
+// satisfiesRange reports whether version satisfies an npm semver range. As in npm, a
+// pre-release only matches when a comparator names a pre-release of the same x.y.z
+func satisfiesRange(version, rng string) bool {
+	v, ok := parseSemver(version)
+	if !ok {
+		return false
+	}
+	sets, ok := parseRange(rng)
+	if !ok {
+		return false
+	}
+
+	for _, set := range sets {
+		matched := true
+		for _, c := range set {
+			if !c.matches(v) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if v.pre == "" || allowsPrerelease(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSatisfying returns the highest version satisfying rng, or "" when none does
+func maxSatisfying(versions []string, rng string) string {
+	best := ""
+	var bestVersion semver
+	for _, version := range versions {
+		if !satisfiesRange(version, rng) {
+			continue
+		}
+		v, _ := parseSemver(version)
+		if best == "" || compareSemver(v, bestVersion) > 0 {
+			best, bestVersion = version, v
+		}
+	}
+	return best
+}
+
+// bound is one end of the interval a comparator set allows
+type bound struct {
+	version   semver
+	inclusive bool
+}
+
+// setBounds narrows a comparator set to its greatest lower bound and least upper bound. An
+// unbounded lower end is the lowest possible version and an unbounded upper end is nil
+func setBounds(set []comparator) (bound, *bound) {
+	lower := bound{version: semver{pre: "0"}, inclusive: true}
+	var upper *bound
+	for _, c := range set {
+		if c.op == ">" || c.op == ">=" || c.op == "=" {
+			candidate := bound{c.version, c.op != ">"}
+			if cmp := compareSemver(c.version, lower.version); cmp > 0 || (cmp == 0 && !candidate.inclusive) {
+				lower = candidate
+			}
+		}
+		if c.op == "<" || c.op == "<=" || c.op == "=" {
+			candidate := bound{c.version, c.op != "<"}
+			if upper == nil {
+				upper = &candidate
+			} else if cmp := compareSemver(c.version, upper.version); cmp < 0 || (cmp == 0 && !candidate.inclusive) {
+				upper = &candidate
+			}
+		}
+	}
+	return lower, upper
+}
+
+// below reports whether v lies under the upper bound
+func (b *bound) below(v semver) bool {
+	if b == nil {
+		return true
+	}
+	cmp := compareSemver(v, b.version)
+	return cmp < 0 || (cmp == 0 && b.inclusive)
+}
+
+// allowsPrerelease reports whether a comparator set names a pre-release of v's x.y.z, which
+// npm requires before a pre-release can satisfy it
+func allowsPrerelease(set []comparator, v semver) bool {
+	for _, c := range set {
+		if c.version.pre != "" && c.version.pre != "0" && c.version.major == v.major && c.version.minor == v.minor && c.version.patch == v.patch {
+			return true
+		}
+	}
+	return false
+}
+
+// rangesIntersect reports whether some version satisfies both ranges, where either may be an
+// exact version. Each pair of alternatives is narrowed to the highest lower bound and the
+// lowest upper bound, which overlap when some release lies between them, or a pre-release
+// both alternatives admit
+func rangesIntersect(a, b string) bool {
+	setsA, ok := parseRange(a)
+	if !ok {
+		return false
+	}
+	setsB, ok := parseRange(b)
+	if !ok {
+		return false
+	}
+
+	for _, setA := range setsA {
+		for _, setB := range setsB {
+			lower, upper := setBounds(append(append([]comparator{}, setA...), setB...))
+			if !upper.below(lower.version) || (!lower.inclusive && upper != nil && compareSemver(lower.version, upper.version) == 0) {
+				continue
+			}
+
+			// The lowest release above the lower bound
+			release := semver{major: lower.version.major, minor: lower.version.minor, patch: lower.version.patch}
+			if lower.version.pre == "" && !lower.inclusive {
+				release.patch++
+			}
+			if upper.below(release) {
+				return true
+			}
+
+			// Otherwise only pre-releases of that release's x.y.z lie between the bounds
+			if allowsPrerelease(setA, release) && allowsPrerelease(setB, release) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestSatisfiesRange(t *testing.T) {
+	tests := []struct {
+		version  string
+		rng      string
+		expected bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.4", "1.2.3", false},
+		{"1.9.0", "^1.2.3", true},
+		{"2.0.0", "^1.2.3", false},
+		{"0.2.9", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.4", "^0.0.3", false},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.4.0", "1.x", true},
+		{"2.0.0", "1", false},
+		{"1.2.7", "1.2", true},
+		{"5.0.0", "*", true},
+		{"3.0.0", "", true},
+		{"1.5.0", ">=1.2.0 <2.0.0", true},
+		{"2.0.0", ">=1.2.0 <2.0.0", false},
+		{"1.5.0", ">= 1.2.0", true},
+		{"1.3.0", ">1.2", true},
+		{"1.2.9", ">1.2", false},
+		{"1.2.9", "<=1.2", true},
+		{"1.3.0", "<=1.2", false},
+		{"2.3.0", "1.2.3 - 2.3", true},
+		{"2.4.0", "1.2.3 - 2.3", false},
+		{"3.1.0", "^1.0.0 || ^3.0.0", true},
+		{"2.1.0", "^1.0.0 || ^3.0.0", false},
+		{"1.3.0-rc.1", "^1.2.0", false},
+		{"1.3.0-rc.2", "^1.3.0-rc.1", true},
+		{"1.4.0-rc.1", "^1.3.0-rc.1", false},
+		{"v1.2.3", "=1.2.3", true},
+		{"1.2.3", "git+https://example.com/repo.git", false},
+	}
+
+	for _, test := range tests {
+		if got := satisfiesRange(test.version, test.rng); got != test.expected {
+			t.Errorf("satisfiesRange(%q, %q) = %v, want %v", test.version, test.rng, got, test.expected)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0-beta.1", "2.0.0"}
+	if got := maxSatisfying(versions, "^1.0.0"); got != "1.10.0" {
+		t.Errorf("Expected 1.10.0, got %q", got)
+	}
+	if got := maxSatisfying(versions, "^3.0.0"); got != "" {
+		t.Errorf("Expected no match, got %q", got)
+	}
+}
+
+func TestRangesIntersect(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"5.6.1", "^5.6.0", true},
+		{"5.6.1", "~5.5.0", false},
+		{">=1.2.0 <1.4.0", "^1.3.0", true},
+		{">=1.2.0 <1.4.0", "^1.4.0", false},
+		{"4.1.1", "*", true},
+		{"4.1.1", "4.1.0 - 4.1.2", true},
+		{"2.0.0-rc.1", "^1.0.0 || ^2.0.0", false},
+		{"2.0.0-rc.1", ">=2.0.0-rc.0 <2.0.0", true},
+		{"1.0.0", "not-a-range", false},
+		{"^1.2.0", ">1.2.3 <1.3.0", true},
+		{"^1.2.0", ">1.2.3 <=1.2.3", false},
+		{">1.2.3", "<=1.2.3", false},
+		{">1.2.3", "<1.2.4", false},
+		{">=1.2.3", "<=1.2.3", true},
+		{"~1.2.0", ">=1.2.5 || <1.0.0", true},
+		{"^1.0.0", ">=2.0.0-rc.0 <2.0.0", false},
+		{">=2.0.0-rc.0 <2.0.0", "2.0.0-rc.3 - 2.0.0", true},
+	}
+	for _, tt := range tests {
+		if got := rangesIntersect(tt.a, tt.b); got != tt.want {
+			t.Errorf("rangesIntersect(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// scanStats accumulates internal counters for diagnosing slow scans.
+// All methods are safe to call on a nil receiver so callers can opt out.
+type scanStats struct {
+	FilesWalked        int
+	FilesMatched       int
+	FilesParsed        int
+	PackagesEnumerated int
+	MapLookups         int
+	PatternEvaluations int
+	phases             []scanPhase
+
+	// enumerated collects every name@version the parsers enumerate when non-nil
+	enumerated map[string]map[string]bool
+}
+
+// scanPhase records how long a named phase of the scan took
+type scanPhase struct {
+	Name     string
+	Duration time.Duration
+}
+
+func (s *scanStats) fileWalked() {
+	if s != nil {
+		s.FilesWalked++
+	}
+}
+
+func (s *scanStats) fileMatched() {
+	if s != nil {
+		s.FilesMatched++
+	}
+}
+
+func (s *scanStats) fileParsed() {
+	if s != nil {
+		s.FilesParsed++
+	}
+}
+
+func (s *scanStats) packageEnumerated(name, version string) {
+	if s == nil {
+		return
+	}
+	s.PackagesEnumerated++
+	if s.enumerated != nil && name != "" && version != "" {
+		if s.enumerated[name] == nil {
+			s.enumerated[name] = make(map[string]bool)
+		}
+		s.enumerated[name][version] = true
+	}
+}
+
+func (s *scanStats) mapLookup() {
+	if s != nil {
+		s.MapLookups++
+	}
+}
+
+func (s *scanStats) patternEvaluated() {
+	if s != nil {
+		s.PatternEvaluations++
+	}
+}
+
+// phase records the time elapsed since start under the given name
+func (s *scanStats) phase(name string, start time.Time) {
+	if s != nil {
+		s.phases = append(s.phases, scanPhase{Name: name, Duration: time.Since(start)})
+	}
+}
+
+// print writes the counters and phase timings in a human-readable form
+func (s *scanStats) print(w io.Writer) {
+	if s == nil {
+		return
+	}
+
+	fmt.Fprintln(w, "📈 Scan Statistics:")
+	fmt.Fprintf(w, "   Files walked: %d\n", s.FilesWalked)
+	fmt.Fprintf(w, "   Files matched: %d\n", s.FilesMatched)
+	fmt.Fprintf(w, "   Files parsed: %d\n", s.FilesParsed)
+	fmt.Fprintf(w, "   Packages enumerated: %d\n", s.PackagesEnumerated)
+	fmt.Fprintf(w, "   Map lookups: %d\n", s.MapLookups)
+	fmt.Fprintf(w, "   Pattern evaluations: %d\n", s.PatternEvaluations)
+	for _, phase := range s.phases {
+		fmt.Fprintf(w, "   Phase %s: %v\n", phase.Name, phase.Duration.Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that stats counters reflect a known small tree
+func TestScanStatsCounters(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"package-lock.json": `{
+			"lockfileVersion": 2,
+			"packages": {
+				"node_modules/left-pad": {"version": "1.3.0"},
+				"node_modules/safe-package": {"version": "1.0.0"}
+			}
+		}`,
+		"app/yarn.lock": `left-pad@^1.2.0:
+  version "1.2.0"
+`,
+		"README.md":              "# not a lockfile",
+		"dist/package-lock.json": `{"packages": {}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := &scanStats{}
+	lockfiles, err := findLockfiles(root, []string{"yarn", "npm"}, nil, []string{"dist/**"}, nil, nil, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad": {"1.3.0": true},
+	}
+	scanLockfiles(lockfiles, affected, nil, scanOptions{}, stats)
+
+	if stats.FilesWalked != 4 {
+		t.Errorf("Expected 4 files walked, got %d", stats.FilesWalked)
+	}
+	if stats.FilesMatched != 2 {
+		t.Errorf("Expected 2 files matched, got %d", stats.FilesMatched)
+	}
+	if stats.FilesParsed != 2 {
+		t.Errorf("Expected 2 files parsed, got %d", stats.FilesParsed)
+	}
+	if stats.PackagesEnumerated != 3 {
+		t.Errorf("Expected 3 packages enumerated, got %d", stats.PackagesEnumerated)
+	}
+	if stats.MapLookups != 3 {
+		t.Errorf("Expected 3 map lookups, got %d", stats.MapLookups)
+	}
+	if stats.PatternEvaluations != 3 {
+		t.Errorf("Expected 3 pattern evaluations, got %d", stats.PatternEvaluations)
+	}
+
+	stats.phase("discovery", time.Now())
+	var buf bytes.Buffer
+	stats.print(&buf)
+	if !strings.Contains(buf.String(), "Files walked: 4") || !strings.Contains(buf.String(), "Phase discovery") {
+		t.Errorf("Unexpected stats output:\n%s", buf.String())
+	}
+}
+
+// Test that a nil stats accumulator is safe to use
+func TestScanStatsNil(t *testing.T) {
+	var stats *scanStats
+	stats.fileWalked()
+	stats.phase("discovery", time.Now())
+	stats.print(&bytes.Buffer{})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// resultStream writes results as each lockfile completes without retaining them,
+// tracking summary counts incrementally
+type resultStream struct {
+	w             io.Writer
+	format        string
+	root          string
+	indent        string
+	noColor       bool
+	count         int
+	anyAffected   bool
+	anyWarnings   bool
+	anySuspicious bool
+	summary       Summary
+}
+
+// newResultStream creates a stream writing the given format ("text" or "json") to w. Text goes
+// through the same printers as printResults
+func newResultStream(w io.Writer, format, root, indent string, noColor bool) *resultStream {
+	return &resultStream{w: w, format: format, root: root, indent: indent, noColor: noColor}
+}
+
+// begin writes the output header
+func (s *resultStream) begin() error {
+	if s.format != "json" {
+		return nil
+	}
+	root, err := json.Marshal(s.root)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "{\n%s\"root\": %s,\n%s\"results\": [", s.indent, root, s.indent)
+	return err
+}
+
+// add writes a single result and updates the summary counts
+func (s *resultStream) add(result Result) error {
+	s.summary.TotalPackages += len(result.Packages)
+	s.anyWarnings = s.anyWarnings || result.Incomplete
+	for _, pkg := range result.Packages {
+		if pkg.IsAffected {
+			s.summary.TotalCompromised++
+			s.anyAffected = true
+		}
+		if pkg.IsWarning {
+			s.summary.TotalWarnings++
+			s.anyWarnings = true
+		}
+		if pkg.IsSuspicious {
+			s.summary.TotalSuspicious++
+			s.anySuspicious = true
+		}
+	}
+
+	if s.format == "json" {
+		output, err := json.MarshalIndent(result, s.indent+s.indent, s.indent)
+		if err != nil {
+			return err
+		}
+		separator := ","
+		if s.count == 0 {
+			separator = ""
+		}
+		s.count++
+		_, err = fmt.Fprintf(s.w, "%s\n%s%s", separator, s.indent+s.indent, output)
+		return err
+	}
+
+	for _, notice := range result.Notices {
+		printNoticeFinding(s.w, result, notice, s.noColor)
+	}
+	for _, pkg := range result.Packages {
+		switch {
+		case pkg.IsAffected:
+			printAffectedFinding(s.w, result, pkg, s.noColor)
+		case pkg.IsWarning:
+			printWarningFinding(s.w, result, pkg, s.noColor)
+		case pkg.Notice != "":
+			printNoticeFinding(s.w, result, pkg.Notice, s.noColor)
+		}
+	}
+	s.count++
+	return nil
+}
+
+// finish writes the trailing summary; totalLockfiles is the number of lockfiles scanned and
+// suppressedWarnings the number of warnings --quiet-errors kept off stderr
+func (s *resultStream) finish(totalLockfiles, suppressedWarnings int) error {
+	s.summary.TotalLockfiles = totalLockfiles
+	s.summary.SuppressedWarnings = suppressedWarnings
+
+	if s.format == "json" {
+		summary, err := json.MarshalIndent(s.summary, s.indent, s.indent)
+		if err != nil {
+			return err
+		}
+		closing := "]"
+		if s.count > 0 {
+			closing = "\n" + s.indent + "]"
+		}
+		// anySuspicious is omitted when false, as in the collected JSON report
+		suspicious := ""
+		if s.anySuspicious {
+			suspicious = fmt.Sprintf("%s\"anySuspicious\": true,\n", s.indent)
+		}
+		_, err = fmt.Fprintf(s.w, "%s,\n%[2]s\"anyAffected\": %[3]t,\n%[2]s\"anyWarnings\": %[4]t,\n%[5]s%[2]s\"summary\": %[6]s\n}\n",
+			closing, s.indent, s.anyAffected, s.anyWarnings, suspicious, summary)
+		return err
+	}
+
+	printSummary(s.w, ScanResult{Summary: s.summary}, s.noColor)
+	return nil
+}
+
+// streamLockfiles scans lockfiles one at a time, handing each non-empty result to emit
+func streamLockfiles(lockfiles []string, affected map[string]map[string]bool, extra []lockfileMapping, opts scanOptions, stats *scanStats, emit func(Result) error) error {
+	for _, lockfile := range lockfiles {
+		format := lockfileFormat(filepath.Base(lockfile), extra)
+		packages, notices, _, _ := scanLockfileAs(lockfile, format, affected, opts, stats)
+		if len(packages) == 0 && len(notices) == 0 {
+			continue
+		}
+		if err := emit(Result{LockFile: lockfile, Packages: packages, Notices: notices, Incomplete: incompleteScan(notices)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
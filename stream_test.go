@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeStreamFixtures writes count npm lockfiles each containing packagesPerFile affected packages
+func writeStreamFixtures(t *testing.T, dir string, count, packagesPerFile int) ([]string, map[string]map[string]bool) {
+	affected := make(map[string]map[string]bool)
+	var entries []string
+	for i := 0; i < packagesPerFile; i++ {
+		name := fmt.Sprintf("compromised-package-%d", i)
+		affected[name] = map[string]bool{"1.0.0": true}
+		entries = append(entries, fmt.Sprintf(`"node_modules/%s": {"version": "1.0.0"}`, name))
+	}
+	content := `{"lockfileVersion": 2, "packages": {` + strings.Join(entries, ",") + `}}`
+
+	var lockfiles []string
+	for i := 0; i < count; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("app%d", i), "package-lock.json")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		lockfiles = append(lockfiles, path)
+	}
+	return lockfiles, affected
+}
+
+// heapAfterStreaming streams lockfiles to a discarding writer and returns the live heap afterwards
+func heapAfterStreaming(t *testing.T, lockfiles []string, affected map[string]map[string]bool) (uint64, *resultStream) {
+	stream := newResultStream(io.Discard, "json", "/root", "  ", true)
+	if err := stream.begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	if err := streamLockfiles(lockfiles, affected, nil, scanOptions{}, nil, stream.add); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.finish(len(lockfiles), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0, stream
+	}
+	return after.HeapAlloc - before.HeapAlloc, stream
+}
+
+// Test that streaming keeps live memory flat as the number of lockfiles grows
+func TestStreamLockfilesMemoryFlat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping memory test in short mode")
+	}
+
+	lockfiles, affected := writeStreamFixtures(t, t.TempDir(), 200, 50)
+
+	smallGrowth, _ := heapAfterStreaming(t, lockfiles[:20], affected)
+	largeGrowth, stream := heapAfterStreaming(t, lockfiles, affected)
+
+	if stream.summary.TotalCompromised != 200*50 {
+		t.Errorf("Expected %d compromised packages counted, got %d", 200*50, stream.summary.TotalCompromised)
+	}
+
+	// Retaining 10,000 findings would cost well over a megabyte of live heap
+	const tolerance = 256 * 1024
+	if largeGrowth > smallGrowth+tolerance {
+		t.Errorf("Live heap grew with file count: %d bytes for 20 files, %d bytes for 200 files", smallGrowth, largeGrowth)
+	}
+}
+
+// Test that streamed JSON output is a valid scan result document
+func TestResultStreamJSON(t *testing.T) {
+	lockfiles, affected := writeStreamFixtures(t, t.TempDir(), 3, 2)
+
+	var buf bytes.Buffer
+	stream := newResultStream(&buf, "json", "/root", "  ", true)
+	if err := stream.begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := streamLockfiles(lockfiles, affected, nil, scanOptions{}, nil, stream.add); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.finish(len(lockfiles), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed ScanResult
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Invalid streamed JSON: %v\n%s", err, buf.String())
+	}
+	if len(parsed.Results) != 3 || !parsed.AnyAffected || parsed.Summary.TotalCompromised != 6 || parsed.Summary.TotalLockfiles != 3 {
+		t.Errorf("Unexpected streamed result: %+v", parsed.Summary)
+	}
+}
+
+// Test that streamed text prints findings the way printResults does, to the stream's writer
+func TestResultStreamText(t *testing.T) {
+	lockfiles, affected := writeStreamFixtures(t, t.TempDir(), 1, 1)
+
+	var buf bytes.Buffer
+	var results []Result
+	stdout := captureOutput(t, &os.Stdout, func() {
+		stream := newResultStream(&buf, "text", "/root", "  ", true)
+		add := func(result Result) error {
+			results = append(results, result)
+			return stream.add(result)
+		}
+		if err := streamLockfiles(lockfiles, affected, nil, scanOptions{}, nil, add); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.finish(len(lockfiles), 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if stdout != "" {
+		t.Errorf("Expected nothing on standard output, got:\n%s", stdout)
+	}
+	if len(results) != 1 || len(results[0].Packages) != 1 {
+		t.Fatalf("Expected one streamed finding, got %+v", results)
+	}
+
+	var expected bytes.Buffer
+	printAffectedFinding(&expected, results[0], results[0].Packages[0], true)
+	streamed := buf.String()
+	if !strings.HasPrefix(streamed, expected.String()) || !strings.Contains(streamed, "Compromised packages: ❌ 1") {
+		t.Errorf("Expected the streamed text to match printResults, got:\n%s", streamed)
+	}
+}
+
+// Test that streamed JSON reports anySuspicious like the collected report
+func TestResultStreamJSONSuspicious(t *testing.T) {
+	var buf bytes.Buffer
+	stream := newResultStream(&buf, "json", "/root", "  ", true)
+	if err := stream.begin(); err != nil {
+		t.Fatal(err)
+	}
+	result := Result{LockFile: "/root/app/package-lock.json", Packages: []Package{{Name: "lodahs", Version: "1.0.0", IsSuspicious: true}}}
+	if err := stream.add(result); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.finish(1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed ScanResult
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Invalid streamed JSON: %v\n%s", err, buf.String())
+	}
+	if !parsed.AnySuspicious || parsed.AnyAffected || parsed.Summary.TotalSuspicious != 1 {
+		t.Errorf("Expected a suspicious-only streamed report, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitmodulesPaths returns the submodule paths declared in repoRoot/.gitmodules
+func gitmodulesPaths(repoRoot string) map[string]bool {
+	paths := make(map[string]bool)
+	file, err := os.Open(filepath.Join(repoRoot, ".gitmodules"))
+	if err != nil {
+		return paths
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if found && strings.TrimSpace(key) == "path" {
+			paths[filepath.Join(repoRoot, filepath.FromSlash(strings.TrimSpace(value)))] = true
+		}
+	}
+	return paths
+}
+
+// owningSubmodule returns the innermost submodule containing path, relative to repoRoot in
+// forward-slash form, or "" when path belongs to the outer repository. A directory is a
+// submodule when .gitmodules declares it or it holds its own .git entry
+func owningSubmodule(path, repoRoot string, declared map[string]bool) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(repoRoot, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	for dir := filepath.Dir(abs); dir != repoRoot && strings.HasPrefix(dir, repoRoot); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || declared[dir] {
+			if rel, err := filepath.Rel(repoRoot, dir); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return ""
+}
+
+// annotateSubmodules sets the owning submodule on every result whose lockfile lives in one.
+// Submodules are resolved against the repository enclosing root, or root itself outside a repository
+func annotateSubmodules(results []Result, root string) {
+	repoRoot := findRepoRoot(root)
+	if repoRoot == "" {
+		repoRoot = root
+	}
+	declared := gitmodulesPaths(repoRoot)
+	for i := range results {
+		results[i].Submodule = owningSubmodule(results[i].LockFile, repoRoot, declared)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a lockfile inside a submodule is attributed to it
+func TestAnnotateSubmodules(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git", "vendor/shared-ui"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		".gitmodules":                        "[submodule \"shared-ui\"]\n\tpath = vendor/shared-ui\n\turl = https://example.com/shared-ui.git\n",
+		"vendor/shared-ui/.git":              "gitdir: ../../.git/modules/shared-ui\n",
+		"vendor/shared-ui/package-lock.json": `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`,
+		"package-lock.json":                  `{"lockfileVersion": 3, "packages": {"node_modules/left-pad": {"version": "1.3.0"}}}`,
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(rel)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockfiles, err := findLockfiles(root, []string{"npm"}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	results, anyAffected, _ := scanLockfiles(lockfiles, affected, nil, scanOptions{}, nil)
+	if !anyAffected || len(results) != 2 {
+		t.Fatalf("Expected findings in both lockfiles, got %+v", results)
+	}
+
+	annotateSubmodules(results, root)
+	for _, result := range results {
+		expected := ""
+		if filepath.Dir(result.LockFile) != root {
+			expected = "vendor/shared-ui"
+		}
+		if result.Submodule != expected {
+			t.Errorf("%s: expected submodule %q, got %q", result.LockFile, expected, result.Submodule)
+		}
+	}
+}
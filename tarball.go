@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tarballNameRegex splits a tarball filename such as left-pad-1.3.0.tgz into name and version
+var tarballNameRegex = regexp.MustCompile(`^(.+?)-([0-9]+\.[0-9]+\.[0-9]+(?:-[0-9A-Za-z.-]+)?)\.tgz$`)
+
+// parseTarballName extracts the package name and version from a tarball filename.
+// Scoped packages packed with npm are flattened to scope-name-version.tgz and are
+// returned flattened; URL-encoded names like %40scope%2fname-1.0.0.tgz keep their scope.
+func parseTarballName(filename string) (name, version string) {
+	base := filename[strings.LastIndexAny(filename, `/\`)+1:]
+	if decoded, err := url.PathUnescape(base); err == nil {
+		base = decoded
+	}
+
+	matches := tarballNameRegex.FindStringSubmatch(base)
+	if matches == nil {
+		return "", ""
+	}
+
+	return matches[1], matches[2]
+}
+
+// scanTarballs walks rootDir for *.tgz files and matches their names against the affected list
+func scanTarballs(rootDir string, affected map[string]map[string]bool, include, exclude []string) ([]Result, bool, bool) {
+	comparator := versionComparatorFor("npm")
+	var results []Result
+	anyAffected := false
+	anyWarnings := false
+
+	// npm pack flattens "@scope/name" into "scope-name"
+	flattened := make(map[string]string)
+	for name := range affected {
+		if strings.HasPrefix(name, "@") {
+			flattened[strings.Replace(strings.TrimPrefix(name, "@"), "/", "-", 1)] = name
+		}
+	}
+
+	filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".tgz") {
+			return nil
+		}
+		if !shouldIncludePath(path, rootDir, include, exclude) {
+			return nil
+		}
+
+		name, version := parseTarballName(d.Name())
+		if name == "" {
+			return nil
+		}
+
+		// Registry layouts store scoped tarballs as @scope/name/-/name-1.0.0.tgz
+		if dir := filepath.Dir(path); filepath.Base(dir) == "-" {
+			pkgDir := filepath.Dir(dir)
+			if scope := filepath.Base(filepath.Dir(pkgDir)); strings.HasPrefix(scope, "@") && filepath.Base(pkgDir) == name {
+				name = scope + "/" + name
+			}
+		}
+
+		if _, listed := affected[name]; !listed {
+			if scoped, ok := flattened[name]; ok {
+				name = scoped
+			}
+		}
+
+		pkg, ok := checkPackage(comparator, name, version, affected)
+		if !ok {
+			return nil
+		}
+
+		results = append(results, Result{
+			LockFile: path,
+			Packages: []Package{pkg},
+		})
+		if pkg.IsAffected {
+			anyAffected = true
+		}
+		if pkg.IsWarning {
+			anyWarnings = true
+		}
+		return nil
+	})
+
+	return results, anyAffected, anyWarnings
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTarballName(t *testing.T) {
+	tests := []struct {
+		filename string
+		name     string
+		version  string
+	}{
+		{"left-pad-1.3.0.tgz", "left-pad", "1.3.0"},
+		{"es5-ext-0.10.62.tgz", "es5-ext", "0.10.62"},
+		{"/tmp/cache/left-pad-1.3.0.tgz", "left-pad", "1.3.0"},
+		{"scoped-package-2.0.0.tgz", "scoped-package", "2.0.0"},
+		{"%40scoped%2fpackage-2.0.0.tgz", "@scoped/package", "2.0.0"},
+		{"package-1.0.0-beta.1.tgz", "package", "1.0.0-beta.1"},
+		{"not-a-tarball.tgz", "", ""},
+		{"left-pad-1.3.0.zip", "", ""},
+	}
+
+	for _, test := range tests {
+		name, version := parseTarballName(test.filename)
+		if name != test.name || version != test.version {
+			t.Errorf("parseTarballName(%q) = (%q, %q), want (%q, %q)",
+				test.filename, name, version, test.name, test.version)
+		}
+	}
+}
+
+// Test that compromised tarballs are found on disk, including scoped naming
+func TestScanTarballs(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"downloads/left-pad-1.3.0.tgz",
+		"downloads/scoped-package-2.0.0.tgz",
+		"mirror/@other/tool/-/tool-3.1.0.tgz",
+		"downloads/safe-package-1.0.0.tgz",
+	}
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("tarball"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected := map[string]map[string]bool{
+		"left-pad":        {"1.3.0": true},
+		"@scoped/package": {"2.0.0": true},
+		"@other/tool":     {"3.1.0": true},
+	}
+
+	results, anyAffected, _ := scanTarballs(root, affected, nil, nil)
+
+	if !anyAffected {
+		t.Error("Expected compromised tarballs to be found")
+	}
+
+	found := make(map[string]bool)
+	for _, result := range results {
+		for _, pkg := range result.Packages {
+			found[pkg.Name+"@"+pkg.Version] = pkg.IsAffected
+		}
+	}
+	for _, expected := range []string{"left-pad@1.3.0", "@scoped/package@2.0.0", "@other/tool@3.1.0"} {
+		if !found[expected] {
+			t.Errorf("Expected %s to be flagged, got %v", expected, found)
+		}
+	}
+	if len(found) != 3 {
+		t.Errorf("Expected 3 findings, got %v", found)
+	}
+}
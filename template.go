@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helpers available to --template and --template-file
+var templateFuncs = template.FuncMap{
+	// compromised returns every compromised package in the scan
+	"compromised": func(result ScanResult) []Package {
+		return filterPackages(result, func(pkg Package) bool { return pkg.IsAffected })
+	},
+	// warnings returns every package that is safe but has compromised versions
+	"warnings": func(result ScanResult) []Package {
+		return filterPackages(result, func(pkg Package) bool { return pkg.IsWarning })
+	},
+	// notices returns every informational message, about a file or about a package
+	"notices": func(result ScanResult) []string {
+		var notices []string
+		for _, res := range result.Results {
+			notices = append(notices, res.Notices...)
+			for _, pkg := range res.Packages {
+				if pkg.Notice != "" && !pkg.IsAffected && !pkg.IsWarning {
+					notices = append(notices, pkg.Notice)
+				}
+			}
+		}
+		return notices
+	},
+	"count": func(packages []Package) int { return len(packages) },
+	"join":  strings.Join,
+}
+
+// filterPackages returns the packages across all results that match keep
+func filterPackages(result ScanResult, keep func(Package) bool) []Package {
+	var packages []Package
+	for _, res := range result.Results {
+		for _, pkg := range res.Packages {
+			if keep(pkg) {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+	return packages
+}
+
+// loadOutputTemplate returns the template text from --template or the file named by --template-file
+func loadOutputTemplate(text, path string) (string, error) {
+	if text != "" && path != "" {
+		return "", fmt.Errorf("--template and --template-file cannot be combined")
+	}
+	if path == "" {
+		return text, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// renderTemplate executes a text/template with the scan result as its context
+func renderTemplate(w io.Writer, text string, result ScanResult) error {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, result)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a custom template can print just the compromised package names
+func TestRenderTemplate(t *testing.T) {
+	result := ScanResult{
+		Results: []Result{{LockFile: "yarn.lock", Packages: []Package{
+			{Name: "left-pad", Version: "1.3.0", IsAffected: true},
+			{Name: "is-odd", Version: "3.0.1", IsWarning: true},
+			{Name: "@scoped/package", Version: "2.0.0", IsAffected: true},
+		}}},
+	}
+
+	var buf bytes.Buffer
+	tmpl := `{{range compromised .}}{{.Name}}
+{{end}}{{count (warnings .)}} warnings`
+	if err := renderTemplate(&buf, tmpl, result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "left-pad\n@scoped/package\n1 warnings"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	if err := renderTemplate(&buf, "{{range}", result); err == nil {
+		t.Error("Expected a malformed template to fail")
+	}
+}
+
+// Test that templates load from a file and that both sources cannot be combined
+func TestLoadOutputTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	if err := os.WriteFile(path, []byte("{{.Root}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if text, err := loadOutputTemplate("", path); err != nil || text != "{{.Root}}" {
+		t.Errorf("Expected template from file, got %q, %v", text, err)
+	}
+	if _, err := loadOutputTemplate("{{.Root}}", path); err == nil {
+		t.Error("Expected an error when both --template and --template-file are set")
+	}
+}
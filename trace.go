@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// packageTracer explains, for one package name, every version the parsers evaluated and why
+// it did or did not match. All methods are safe to call on a nil receiver
+type packageTracer struct {
+	name  string
+	w     io.Writer
+	mu    sync.Mutex
+	found int
+}
+
+// newPackageTracer creates a tracer writing explanations for name to w
+func newPackageTracer(name string, w io.Writer) *packageTracer {
+	return &packageTracer{name: name, w: w}
+}
+
+// record explains the matching decision for name@version found in lockfile, if name is traced
+func (t *packageTracer) record(lockfile, name, version string, affected map[string]map[string]bool) {
+	if t == nil || name != t.name {
+		return
+	}
+
+	steps := []string{fmt.Sprintf("found version %q", version)}
+	normalized := normalizeVersion(version)
+	if normalized != version {
+		steps = append(steps, fmt.Sprintf("normalized to %q", normalized))
+	}
+
+	var verdict string
+	affectedVersions, listed := affected[name]
+	switch {
+	case !listed:
+		steps = append(steps, "name is not on the exploited packages list")
+		verdict = "no match"
+	case len(affectedVersions) == 0:
+		steps = append(steps, "name is on the list without versions")
+		verdict = "no match"
+	default:
+		versions := make([]string, 0, len(affectedVersions))
+		for v := range affectedVersions {
+			versions = append(versions, v)
+		}
+		sortAffectedVersions(versions)
+		steps = append(steps, fmt.Sprintf("comparison against listed versions and ranges %s", strings.Join(versions, ", ")))
+		comparator := versionComparatorFor(lockfileFormat(filepath.Base(lockfile), nil))
+		if listed, ok := listedVersion(comparator, affectedVersions, version); ok {
+			if listed != normalized {
+				steps = append(steps, fmt.Sprintf("satisfies range %s", listed))
+			}
+			verdict = "compromised"
+		} else {
+			steps = append(steps, fmt.Sprintf("miss: %s is not a listed version", normalized))
+			verdict = "warning (other versions are compromised)"
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.found++
+	fmt.Fprintf(t.w, "trace: %s@%s in %s: %s; verdict: %s\n", name, version, lockfile, strings.Join(steps, "; "), verdict)
+}
+
+// finish reports a traced package that no parser evaluated, which is itself the explanation
+// for a missing finding
+func (t *packageTracer) finish(lockfiles []string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.found > 0 {
+		return
+	}
+	scanned := append([]string{}, lockfiles...)
+	sort.Strings(scanned)
+	fmt.Fprintf(t.w, "trace: %s was not found in any of the %d scanned lockfile(s)\n", t.name, len(scanned))
+	for _, lockfile := range scanned {
+		fmt.Fprintf(t.w, "trace:   %s\n", lockfile)
+	}
+}
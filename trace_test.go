@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that the trace explains a non-match with the version found and the reason it missed
+func TestPackageTrace(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "package-lock.json")
+	content := `{"lockfileVersion": 3, "packages": {
+		"node_modules/left-pad": {"version": "v1.3.1"},
+		"node_modules/is-odd": {"version": "3.0.1"}
+	}}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	trace := newPackageTracer("left-pad", &buf)
+
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	scanLockfiles([]string{lockfile}, affected, nil, scanOptions{trace: trace}, nil)
+	trace.finish([]string{lockfile})
+
+	output := buf.String()
+	for _, want := range []string{`found version "v1.3.1"`, `normalized to "1.3.1"`, "miss: 1.3.1 is not a listed version", "verdict: warning"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected trace to mention %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "is-odd") || strings.Contains(output, "not found in any") {
+		t.Errorf("Expected only left-pad to be traced, got:\n%s", output)
+	}
+
+	buf.Reset()
+	trace = newPackageTracer("chalk", &buf)
+	scanLockfiles([]string{lockfile}, affected, nil, scanOptions{trace: trace}, nil)
+	trace.finish([]string{lockfile})
+	if !strings.Contains(buf.String(), "chalk was not found in any of the 1 scanned lockfile(s)") {
+		t.Errorf("Expected trace to report an absent package, got:\n%s", buf.String())
+	}
+}
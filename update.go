@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// latestReleaseURL is the GitHub API endpoint for the scanner's newest published release
+const latestReleaseURL = "https://api.github.com/repos/jpmckearin/shai-hulud-scanner/releases/latest"
+
+// release is the subset of a GitHub release we use
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// fetchLatestRelease asks the GitHub releases API at url for the newest release
+func fetchLatestRelease(client *http.Client, url string) (release, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return release{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	var latest release
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil {
+		return release{}, err
+	}
+	return latest, nil
+}
+
+// isNewerRelease reports whether a release tag such as v1.2.0 is a later version than current.
+// Tags that are not semantic versions are never considered newer
+func isNewerRelease(current, tag string) bool {
+	latest, ok := parseSemver(tag)
+	if !ok {
+		return false
+	}
+	running, ok := parseSemver(current)
+	return !ok || compareSemver(latest, running) > 0
+}
+
+// checkForUpdate returns an upgrade notice when the release at url is newer than current,
+// or "" when current is up to date. Nothing is downloaded or installed
+func checkForUpdate(client *http.Client, url, current string) (string, error) {
+	latest, err := fetchLatestRelease(client, url)
+	if err != nil {
+		return "", err
+	}
+	if !isNewerRelease(current, latest.TagName) {
+		return "", nil
+	}
+	notice := fmt.Sprintf("Shai-Hulud Scanner %s is available (running v%s), and newer releases embed a newer exploited packages list", latest.TagName, current)
+	if latest.HTMLURL != "" {
+		notice += ": " + latest.HTMLURL
+	}
+	return notice, nil
+}
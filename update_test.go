@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsNewerRelease(t *testing.T) {
+	tests := []struct {
+		current, tag string
+		expected     bool
+	}{
+		{"1.0.0", "v1.1.0", true},
+		{"1.0.0", "1.0.1", true},
+		{"1.0.0", "v1.0.0", false},
+		{"1.2.0", "v1.10.0", true},
+		{"2.0.0", "v1.9.9", false},
+		{"1.0.0", "v1.0.0-rc.1", false},
+		{"1.0.0", "nightly", false},
+	}
+	for _, test := range tests {
+		if got := isNewerRelease(test.current, test.tag); got != test.expected {
+			t.Errorf("isNewerRelease(%q, %q) = %v, expected %v", test.current, test.tag, got, test.expected)
+		}
+	}
+}
+
+// Test that a newer tag from the releases API produces an upgrade notice
+func TestCheckForUpdate(t *testing.T) {
+	tag := "v1.4.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "` + tag + `", "html_url": "https://github.com/jpmckearin/shai-hulud-scanner/releases/tag/` + tag + `"}`))
+	}))
+	defer server.Close()
+
+	notice, err := checkForUpdate(server.Client(), server.URL, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(notice, "v1.4.0 is available") || !strings.Contains(notice, "/releases/tag/v1.4.0") {
+		t.Errorf("Expected an upgrade notice for v1.4.0, got %q", notice)
+	}
+
+	tag = "v1.0.0"
+	if notice, err := checkForUpdate(server.Client(), server.URL, "1.0.0"); err != nil || notice != "" {
+		t.Errorf("Expected no notice when up to date, got %q, %v", notice, err)
+	}
+}
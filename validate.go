@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParserCheck is the --validate-parsers outcome for one lockfile
+type ParserCheck struct {
+	LockFile string `json:"lockFile"`
+	Format   string `json:"format"`
+	Packages int    `json:"packages"`
+	Error    string `json:"error,omitempty"`
+}
+
+// validateParsers runs every lockfile through its parser without matching against any list,
+// reporting whether the file was understood and how many packages it enumerated
+func validateParsers(lockfiles []string, extra []lockfileMapping, opts scanOptions) []ParserCheck {
+	checks := make([]ParserCheck, 0, len(lockfiles))
+	for _, lockfile := range lockfiles {
+		checks = append(checks, validateLockfile(lockfile, lockfileFormat(filepath.Base(lockfile), extra), opts))
+	}
+	return checks
+}
+
+// validateLockfile checks one lockfile's syntax for its format, then parses it with an empty
+// list and counts the packages the parser enumerated
+func validateLockfile(lockfile, format string, opts scanOptions) ParserCheck {
+	check := ParserCheck{LockFile: lockfile, Format: format}
+	if filepath.Base(lockfile) == "bun.lockb" && opts.bunBinary == "" {
+		check.Error = "binary bun.lockb is only fully parsed with --bun-bin"
+		return check
+	}
+
+	content, err := readLockfile(lockfile)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	if err := lockfileSyntaxError(content, format, filepath.Base(lockfile)); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	stats := &scanStats{}
+	_, notices, _, _ := parseRecovered(lockfile, func() ([]Package, []string, bool, bool) {
+		return parseLockfileAs(lockfile, format, map[string]map[string]bool{}, opts, stats)
+	})
+	for _, notice := range notices {
+		if strings.HasPrefix(notice, "parse error:") || strings.HasPrefix(notice, bunExportFailedReason) {
+			check.Error = notice
+			return check
+		}
+	}
+	check.Packages = stats.PackagesEnumerated
+	return check
+}
+
+// lockfileSyntaxError reports a lockfile its parser would silently skip or only partly read
+func lockfileSyntaxError(content []byte, format, baseName string) error {
+	switch format {
+	case "npm", "bun":
+		if baseName == "bun.lockb" {
+			return nil
+		}
+		if baseName == "bun.lock" {
+			content = stripJSONC(content)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(content, &data); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	case "pnpm":
+		if err := validatePnpmYAML(string(content)); err != nil {
+			return fmt.Errorf("malformed YAML: %w", err)
+		}
+	case "yarn":
+		// Berry lockfiles are YAML, classic ones a YAML-like format of unindented headers
+		if strings.Contains(string(content), "__metadata:") {
+			var data map[string]interface{}
+			if err := yaml.Unmarshal(content, &data); err != nil {
+				return fmt.Errorf("malformed YAML: %w", err)
+			}
+			return nil
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") || line != strings.TrimLeft(line, " ") {
+				continue
+			}
+			if !strings.HasSuffix(trimmed, ":") {
+				return fmt.Errorf("line %d: expected an entry header ending in ':'", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// printParserChecks prints the per-lockfile validation report
+func printParserChecks(checks []ParserCheck, noColor bool) {
+	failed := 0
+	for _, check := range checks {
+		if check.Error != "" {
+			failed++
+			colorPrint(fmt.Sprintf("❌ %s (%s): %s\n", check.LockFile, check.Format, check.Error), "red", noColor)
+			continue
+		}
+		colorPrint(fmt.Sprintf("✅ %s (%s): %d packages\n", check.LockFile, check.Format, check.Packages), "green", noColor)
+	}
+	colorPrint(fmt.Sprintf("\n%d lockfiles checked, %d understood, %d failed\n", len(checks), len(checks)-failed, failed), "cyan", noColor)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that a valid and a malformed lockfile of each format are told apart with package counts
+func TestValidateParsers(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"good/yarn.lock":         "# yarn lockfile v1\n\nleft-pad@^1.3.0:\n  version \"1.3.0\"\n\nis-odd@^3.0.0:\n  version \"3.0.1\"\n",
+		"bad/yarn.lock":          "# yarn lockfile v1\n\nleft-pad@^1.3.0\n  version \"1.3.0\"\n",
+		"good/package-lock.json": `{"lockfileVersion": 3, "packages": {"": {"name": "app"}, "node_modules/left-pad": {"version": "1.3.0"}}}`,
+		"bad/package-lock.json":  `{"lockfileVersion": 3, "packages": {`,
+		"good/pnpm-lock.yaml":    "lockfileVersion: 5.4\n\npackages:\n  /left-pad@1.3.0:\n    resolution: {integrity: sha512-abc}\n",
+		"bad/pnpm-lock.yaml":     "packages:\n  /left-pad@1.3.0:\n    resolution: {integrity: sha512-abc\n",
+		"good/bun.lock":          `{"lockfileVersion": 1, "workspaces": {"": {"dependencies": {"left-pad": "^1.3.0"}}}, "packages": {"left-pad": ["left-pad@1.3.0", "", {}, "sha512-abc"]}}`,
+		"bad/bun.lock":           `{"lockfileVersion": 1, "packages": `,
+	}
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockfiles, err := findLockfiles(root, []string{"yarn", "npm", "pnpm", "bun"}, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := validateParsers(lockfiles, nil, scanOptions{})
+	if len(checks) != len(files) {
+		t.Fatalf("Expected %d checks, got %+v", len(files), checks)
+	}
+
+	expectedPackages := map[string]int{"yarn.lock": 2, "package-lock.json": 1, "pnpm-lock.yaml": 1, "bun.lock": 1}
+	for _, check := range checks {
+		name := filepath.Base(check.LockFile)
+		if strings.Contains(check.LockFile, string(filepath.Separator)+"bad"+string(filepath.Separator)) {
+			if check.Error == "" {
+				t.Errorf("Expected malformed %s to fail validation, got %+v", name, check)
+			}
+			continue
+		}
+		if check.Error != "" || check.Packages != expectedPackages[name] {
+			t.Errorf("Expected valid %s to parse with %d packages, got %+v", name, expectedPackages[name], check)
+		}
+	}
+}
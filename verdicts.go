@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// packageVerdict is the remembered outcome of checking one package artifact
+type packageVerdict struct {
+	Name     string  `json:"name"`
+	Version  string  `json:"version"`
+	Resolved string  `json:"resolved,omitempty"`
+	Matched  bool    `json:"matched"`
+	Finding  Package `json:"finding"`
+}
+
+// verdictCache remembers per-package verdicts keyed by integrity hash, so an artifact seen
+// in another lockfile or an earlier run is a map hit. Verdicts are only valid for the list
+// they were computed against, identified by ListHash. All methods are safe to call on a nil
+// receiver and from several goroutines
+type verdictCache struct {
+	ListHash string                    `json:"listHash"`
+	Verdicts map[string]packageVerdict `json:"verdicts"`
+	// Lockfiles maps each lockfile's absolute path to its content hash at the previous
+	// --report-only-changed run
+	Lockfiles map[string]string `json:"lockfiles,omitempty"`
+	mu        sync.Mutex
+	dirty     bool
+}
+
+// hashExploitedList identifies an exploited packages list for cache invalidation
+func hashExploitedList(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// newVerdictCache creates an empty cache for the list with the given hash
+func newVerdictCache(listHash string) *verdictCache {
+	return &verdictCache{ListHash: listHash, Verdicts: make(map[string]packageVerdict)}
+}
+
+// loadVerdictCache reads the cache at path, starting over when it is missing, unreadable
+// or was built against a different list
+func loadVerdictCache(path, listHash string) *verdictCache {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return newVerdictCache(listHash)
+	}
+
+	var cache verdictCache
+	if err := json.Unmarshal(content, &cache); err != nil || cache.ListHash != listHash || cache.Verdicts == nil {
+		return newVerdictCache(listHash)
+	}
+	return &cache
+}
+
+// save writes the cache to path if any verdict was added since it was loaded
+func (c *verdictCache) save(path string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	content, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// check evaluates name@version like evaluatePackage, reusing the verdict remembered for the
+// same integrity hash. Entries without an integrity hash are always evaluated
+func (c *verdictCache) check(comparator VersionComparator, name, version, integrity, resolved string, affected map[string]map[string]bool, knownBad map[string]string) (Package, bool) {
+	if c == nil || integrity == "" {
+		return evaluatePackage(comparator, name, version, integrity, resolved, affected, knownBad)
+	}
+
+	c.mu.Lock()
+	verdict, ok := c.Verdicts[integrity]
+	c.mu.Unlock()
+	if ok && verdict.Name == name && verdict.Version == version && verdict.Resolved == resolved {
+		return verdict.Finding, verdict.Matched
+	}
+
+	finding, matched := evaluatePackage(comparator, name, version, integrity, resolved, affected, knownBad)
+	c.mu.Lock()
+	c.Verdicts[integrity] = packageVerdict{Name: name, Version: version, Resolved: resolved, Matched: matched, Finding: finding}
+	c.dirty = true
+	c.mu.Unlock()
+	return finding, matched
+}
+
+// recordLockfiles remembers the content hash of each lockfile and returns those whose content
+// is unchanged since the previous run with this cache. Since the cache is reset when the list
+// changes, every lockfile counts as changed against a new list
+func (c *verdictCache) recordLockfiles(lockfiles []string) map[string]bool {
+	unchanged := make(map[string]bool)
+	if c == nil {
+		return unchanged
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Lockfiles == nil {
+		c.Lockfiles = make(map[string]string)
+	}
+
+	for _, lockfile := range lockfiles {
+		content, err := os.ReadFile(lockfile)
+		if err != nil {
+			continue
+		}
+		key := lockfile
+		if abs, err := filepath.Abs(lockfile); err == nil {
+			key = abs
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		if c.Lockfiles[key] == hash {
+			unchanged[lockfile] = true
+			continue
+		}
+		c.Lockfiles[key] = hash
+		c.dirty = true
+	}
+	return unchanged
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that verdicts are reused by integrity and dropped when the list changes
+func TestVerdictCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verdicts.json")
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+
+	cache := loadVerdictCache(path, hashExploitedList([]byte("left-pad@1.3.0")))
+	finding, ok := cache.check(npmSemverComparator{}, "left-pad", "1.3.0", "sha512-abc", "", affected, nil)
+	if !ok || !finding.IsAffected {
+		t.Fatalf("Expected left-pad@1.3.0 to be affected, got %+v", finding)
+	}
+	if err := cache.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reloaded cache answers from the stored verdict, even for an empty list
+	reloaded := loadVerdictCache(path, hashExploitedList([]byte("left-pad@1.3.0")))
+	if finding, ok := reloaded.check(npmSemverComparator{}, "left-pad", "1.3.0", "sha512-abc", "", nil, nil); !ok || !finding.IsAffected {
+		t.Errorf("Expected the cached verdict to be reused, got %+v", finding)
+	}
+
+	// The same integrity under a different name is evaluated afresh
+	if _, ok := reloaded.check(npmSemverComparator{}, "right-pad", "1.3.0", "sha512-abc", "", affected, nil); ok {
+		t.Error("Expected a name mismatch to bypass the cached verdict")
+	}
+
+	changed := loadVerdictCache(path, hashExploitedList([]byte("left-pad@1.4.0")))
+	if len(changed.Verdicts) != 0 {
+		t.Errorf("Expected a list change to invalidate the cache, got %d verdicts", len(changed.Verdicts))
+	}
+}
+
+// Test that the cache is bypassed when it is not configured
+func TestVerdictCacheNil(t *testing.T) {
+	var cache *verdictCache
+	affected := map[string]map[string]bool{"left-pad": {"1.3.0": true}}
+	if _, ok := cache.check(npmSemverComparator{}, "left-pad", "1.3.0", "sha512-abc", "", affected, nil); !ok {
+		t.Error("Expected a nil cache to evaluate the package directly")
+	}
+	if err := cache.save(filepath.Join(t.TempDir(), "verdicts.json")); err != nil {
+		t.Errorf("Expected saving a nil cache to be a no-op, got %v", err)
+	}
+}
+
+// verdictBenchmarkList is an affected entry with many versions, so evaluation has to sort them
+func verdictBenchmarkList() map[string]map[string]bool {
+	versions := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		versions[fmt.Sprintf("1.%d.0", i)] = true
+	}
+	return map[string]map[string]bool{"left-pad": versions}
+}
+
+func BenchmarkEvaluatePackageUncached(b *testing.B) {
+	affected := verdictBenchmarkList()
+	for i := 0; i < b.N; i++ {
+		evaluatePackage(npmSemverComparator{}, "left-pad", "1.3.0", "sha512-abc", "", affected, nil)
+	}
+}
+
+func BenchmarkEvaluatePackageCached(b *testing.B) {
+	affected := verdictBenchmarkList()
+	cache := newVerdictCache("bench")
+	for i := 0; i < b.N; i++ {
+		cache.check(npmSemverComparator{}, "left-pad", "1.3.0", "sha512-abc", "", affected, nil)
+	}
+}
+
+// Test that --report-only-changed hides unchanged lockfiles from output but not from the exit code
+func TestReportOnlyChanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "verdicts.json")
+	edited := filepath.Join(dir, "a", "yarn.lock")
+	untouched := filepath.Join(dir, "b", "yarn.lock")
+	for _, lockfile := range []string{edited, untouched} {
+		if err := os.MkdirAll(filepath.Dir(lockfile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(lockfile, []byte("# yarn lockfile v1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listHash := hashExploitedList([]byte("left-pad@1.3.0"))
+	cache := loadVerdictCache(path, listHash)
+	if unchanged := cache.recordLockfiles([]string{edited, untouched}); len(unchanged) != 0 {
+		t.Errorf("Expected every lockfile to be new on the first run, got %v", unchanged)
+	}
+	if err := cache.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(edited, []byte("# yarn lockfile v1\n\nleft-pad@^1.3.0:\n  version \"1.3.0\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unchanged := loadVerdictCache(path, listHash).recordLockfiles([]string{edited, untouched})
+	if !unchanged[untouched] || unchanged[edited] {
+		t.Fatalf("Expected only the untouched lockfile to be unchanged, got %v", unchanged)
+	}
+
+	results := []Result{
+		{LockFile: edited, Packages: []Package{{Name: "is-odd", Version: "3.0.0", IsWarning: true}}},
+		{LockFile: untouched, Packages: []Package{{Name: "left-pad", Version: "1.3.0", IsAffected: true}}},
+	}
+	scanResult := ScanResult{Results: results, AnyAffected: true, AnyWarnings: true, Summary: summarizeResults(results, 2)}
+	scanResult.Results, scanResult.Unchanged = omitUnchangedLockfiles(scanResult.Results, unchanged)
+	if len(scanResult.Results) != 1 || scanResult.Results[0].LockFile != edited || scanResult.Unchanged != 1 {
+		t.Errorf("Expected only the edited lockfile's findings to be shown, got %+v", scanResult)
+	}
+	if code := scanExitCode(scanResult, 0, 2, 0, false); code != 2 {
+		t.Errorf("Expected the hidden compromised package to still fail the scan, got exit code %d", code)
+	}
+
+	if unchanged := loadVerdictCache(path, hashExploitedList([]byte("other@1.0.0"))).recordLockfiles([]string{untouched}); len(unchanged) != 0 {
+		t.Errorf("Expected a new list to report every lockfile again, got %v", unchanged)
+	}
+}
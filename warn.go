@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// warner reports non-fatal operational problems, such as an unreadable directory or a failed
+// lookup, on stderr. Findings never go through a warner. A nil warner reports every warning
+type warner struct {
+	// quiet keeps warnings off stderr, set by --quiet-errors
+	quiet bool
+	// count is the number of warnings reported, shown or not
+	count atomic.Int64
+}
+
+// warnf reports a warning on stderr unless the warner is quiet
+func (w *warner) warnf(format string, args ...interface{}) {
+	if w != nil {
+		w.count.Add(1)
+		if w.quiet {
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// suppressed is the number of warnings a quiet warner kept off stderr
+func (w *warner) suppressed() int {
+	if w == nil || !w.quiet {
+		return 0
+	}
+	return int(w.count.Load())
+}
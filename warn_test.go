@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureOutput returns what fn writes to *target, which is os.Stdout or os.Stderr
+func captureOutput(t *testing.T, target **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *target
+	*target = w
+	fn()
+	*target = original
+	w.Close()
+
+	var captured bytes.Buffer
+	if _, err := captured.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	return captured.String()
+}
+
+// Test that --quiet-errors keeps operational warnings off stderr but counts them in the summary
+func TestQuietErrors(t *testing.T) {
+	warnings := &warner{}
+	stderr := captureOutput(t, &os.Stderr, func() { warnings.warnf("skipping %s: %v", "secret", "permission denied") })
+	if stderr != "Warning: skipping secret: permission denied\n" {
+		t.Errorf("Expected the warning on stderr, got %q", stderr)
+	}
+	if warnings.suppressed() != 0 {
+		t.Errorf("Expected no suppressed count without --quiet-errors, got %d", warnings.suppressed())
+	}
+	result := ScanResult{Summary: Summary{TotalLockfiles: 1, TotalPackages: 2, SuppressedWarnings: warnings.suppressed()}}
+	stdout := captureOutput(t, &os.Stdout, func() { printSummary(os.Stdout, result, true) })
+	if strings.Contains(stdout, "Suppressed warnings") {
+		t.Errorf("Expected no suppressed count without --quiet-errors, got:\n%s", stdout)
+	}
+
+	warnings = &warner{quiet: true}
+	stderr = captureOutput(t, &os.Stderr, func() {
+		warnings.warnf("skipping %s: %v", "secret", "permission denied")
+		warnings.warnf("registry lookup failed: %v", "timeout")
+	})
+	if stderr != "" {
+		t.Errorf("Expected --quiet-errors to keep stderr empty, got %q", stderr)
+	}
+	result.Summary.SuppressedWarnings = warnings.suppressed()
+	stdout = captureOutput(t, &os.Stdout, func() { printSummary(os.Stdout, result, true) })
+	if !strings.Contains(stdout, "Compromised packages: ✅ 0") || !strings.Contains(stdout, "Suppressed warnings: 2") {
+		t.Errorf("Expected the summary and the suppressed warning count, got:\n%s", stdout)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// listFetchTimeout bounds a single fetch of a remote exploited packages list
+const listFetchTimeout = 30 * time.Second
+
+// isListURL reports whether a --list-path value is an http:// or https:// URL to fetch
+func isListURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// defaultListCacheDir returns the on-disk cache location for fetched exploited package lists
+func defaultListCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shai-hulud-scanner", "lists")
+}
+
+// resolveListCacheDir picks the list cache directory from --list-cache-dir and --no-list-cache;
+// an empty result disables the cache
+func resolveListCacheDir(dir string, disabled bool) string {
+	if disabled {
+		return ""
+	}
+	if dir != "" {
+		return dir
+	}
+	return defaultListCacheDir()
+}
+
+// fetchExploitedList downloads a remote exploited packages list. Successful fetches are
+// cached under cacheDir and the cached copy is returned if a later fetch fails; an empty
+// cacheDir disables the cache
+func fetchExploitedList(client *http.Client, url, cacheDir string) ([]byte, error) {
+	cachePath := ""
+	if cacheDir != "" {
+		sum := sha256.Sum256([]byte(url))
+		cachePath = filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".txt")
+	}
+	return fetchExploitedListCached(client, url, cachePath)
+}
+
+// fetchExploitedListCached downloads a remote exploited packages list, saving each successful
+// fetch to cachePath, set by --list-cache, and returning that copy if a later fetch fails. An
+// empty cachePath disables the cache
+func fetchExploitedListCached(client *http.Client, url, cachePath string) ([]byte, error) {
+	content, err := func() ([]byte, error) {
+		list, err := openExploitedList(client, url)
+		if err != nil {
+			return nil, err
+		}
+		defer list.Close()
+		return io.ReadAll(list)
+	}()
+	if err != nil {
+		if cachePath != "" {
+			if cached, cacheErr := os.ReadFile(cachePath); cacheErr == nil {
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			os.WriteFile(cachePath, content, 0644)
+		}
+	}
+
+	return content, nil
+}
+
+// openExploitedList starts downloading a remote exploited packages list and returns its body
+// as a stream. A gzip- or zstd-compressed body, recognized by its magic bytes since feeds are
+// often served as plain application/octet-stream files, is decompressed as it arrives rather
+// than buffered whole first, which keeps memory flat for large feeds
+func openExploitedList(client *http.Client, url string) (io.ReadCloser, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	if magic, err := body.Peek(len(zstdMagic)); err == nil && isZstd(magic) {
+		decoder, err := zstd.NewReader(body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("decompressing %s: %w", url, err)
+		}
+		return zstdReadCloser{decoder, resp.Body}, nil
+	}
+	if magic, err := body.Peek(2); err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return struct {
+			io.Reader
+			io.Closer
+		}{body, resp.Body}, nil
+	}
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("decompressing %s: %w", url, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, resp.Body}, nil
+}
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// zstdReadCloser streams a zstd-decoded response body, releasing the decoder along with the body
+type zstdReadCloser struct {
+	decoder *zstd.Decoder
+	body    io.Closer
+}
+
+func (z zstdReadCloser) Read(p []byte) (int, error) {
+	return z.decoder.Read(p)
+}
+
+func (z zstdReadCloser) Close() error {
+	z.decoder.Close()
+	return z.body.Close()
+}
+
+// isZstd reports whether content starts with a zstd frame
+func isZstd(content []byte) bool {
+	return bytes.HasPrefix(content, zstdMagic)
+}
+
+// readExploitedListFile reads a --list-path list, decompressing zstd-compressed files,
+// recognized by extension or magic bytes, rather than parsing them as an empty list
+func readExploitedListFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".zst") && !isZstd(content) {
+		return content, nil
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	decoded, err := decoder.DecodeAll(content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing %s: %w", path, err)
+	}
+	return decoded, nil
+}
+
+// watcher re-scans a fixed set of lockfiles when they change on disk or when the
+// exploited packages list they are checked against changes
+type watcher struct {
+	lockfiles []string
+	fetchList func() ([]byte, error)
+	scan      func(affected map[string]map[string]bool, list []byte)
+	warn      *warner
+
+	affected map[string]map[string]bool
+	list     []byte
+	listHash [sha256.Size]byte
+	modTimes map[string]time.Time
+}
+
+// newWatcher creates a watcher over lockfiles, initially checked against affected.
+// fetchList may be nil when the list is not refreshed. scan is given the list's raw
+// content alongside affected so it can read the list's per-entry metadata. Failed refreshes
+// are reported through warn
+func newWatcher(lockfiles []string, affected map[string]map[string]bool, fetchList func() ([]byte, error), scan func(map[string]map[string]bool, []byte), warn *warner) *watcher {
+	w := &watcher{
+		lockfiles: lockfiles,
+		fetchList: fetchList,
+		scan:      scan,
+		warn:      warn,
+		affected:  affected,
+		modTimes:  make(map[string]time.Time),
+	}
+	w.lockfilesChanged()
+	return w
+}
+
+// setListContent records the raw list content used for change detection
+func (w *watcher) setListContent(content []byte) {
+	w.list = content
+	w.listHash = sha256.Sum256(content)
+}
+
+// refreshList fetches the list and reports whether its content changed since the last fetch
+func (w *watcher) refreshList() (bool, error) {
+	content, err := w.fetchList()
+	if err != nil {
+		return false, err
+	}
+	hash := sha256.Sum256(content)
+	if hash == w.listHash {
+		return false, nil
+	}
+
+	affected, err := parseExploitedPackages(bytes.NewReader(content))
+	if err != nil {
+		return false, err
+	}
+	if len(affected) == 0 {
+		return false, fmt.Errorf("refreshed list has no valid package@version entries")
+	}
+
+	w.list = content
+	w.listHash = hash
+	w.affected = affected
+	return true, nil
+}
+
+// lockfilesChanged reports whether any watched lockfile was modified since the last check
+func (w *watcher) lockfilesChanged() bool {
+	changed := false
+	for _, lockfile := range w.lockfiles {
+		info, err := os.Stat(lockfile)
+		if err != nil {
+			continue
+		}
+		if previous, seen := w.modTimes[lockfile]; !seen || !info.ModTime().Equal(previous) {
+			w.modTimes[lockfile] = info.ModTime()
+			changed = true
+		}
+	}
+	return changed
+}
+
+// run re-scans on lockfile changes at every poll tick and on list changes at every
+// refresh tick, until stop is closed
+func (w *watcher) run(stop <-chan struct{}, poll, refresh <-chan time.Time) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-poll:
+			if w.lockfilesChanged() {
+				w.scan(w.affected, w.list)
+			}
+		case <-refresh:
+			changed, err := w.refreshList()
+			if err != nil {
+				w.warn.warnf("refreshing exploited packages list failed: %v", err)
+				continue
+			}
+			if changed {
+				w.scan(w.affected, w.list)
+			}
+		}
+	}
+}
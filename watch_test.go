@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Test that a list change mid-watch triggers a re-scan with the new entries
+func TestWatcherRefreshesList(t *testing.T) {
+	var mu sync.Mutex
+	list := "left-pad@1.3.0\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(list))
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	lockfile := filepath.Join(root, "package-lock.json")
+	content := `{"lockfileVersion": 3, "packages": {"node_modules/is-odd": {"version": "3.0.1"}}}`
+	if err := os.WriteFile(lockfile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Timeout: listFetchTimeout}
+	fetch := func() ([]byte, error) { return fetchExploitedList(client, server.URL, "") }
+
+	initial, err := fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := parseExploitedPackages(bytes.NewReader(initial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make(chan bool, 4)
+	w := newWatcher([]string{lockfile}, affected, fetch, func(affected map[string]map[string]bool, list []byte) {
+		_, hasAffected, _ := scanLockfiles([]string{lockfile}, affected, nil, scanOptions{}, nil)
+		scans <- hasAffected
+	}, nil)
+	w.setListContent(initial)
+
+	stop := make(chan struct{})
+	poll := make(chan time.Time)
+	refresh := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		w.run(stop, poll, refresh)
+		close(done)
+	}()
+
+	// An unchanged list must not trigger a re-scan
+	refresh <- time.Now()
+
+	mu.Lock()
+	list = "left-pad@1.3.0\nis-odd@3.0.1\n"
+	mu.Unlock()
+	refresh <- time.Now()
+
+	select {
+	case hasAffected := <-scans:
+		if !hasAffected {
+			t.Error("Expected re-scan against the updated list to flag is-odd@3.0.1")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a re-scan after the list changed")
+	}
+
+	close(stop)
+	<-done
+	if len(scans) != 0 {
+		t.Errorf("Expected exactly one re-scan, got %d more", len(scans))
+	}
+}
+
+// Test that a failed fetch falls back to the cached copy of the list
+func TestFetchExploitedListCache(t *testing.T) {
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("left-pad@1.3.0\n"))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	client := &http.Client{Timeout: listFetchTimeout}
+	if _, err := fetchExploitedList(client, server.URL, cacheDir); err != nil {
+		t.Fatal(err)
+	}
+
+	available = false
+	content, err := fetchExploitedList(client, server.URL, cacheDir)
+	if err != nil || string(content) != "left-pad@1.3.0\n" {
+		t.Errorf("Expected cached list after a failed fetch, got %q, %v", content, err)
+	}
+
+	if _, err := fetchExploitedList(client, server.URL, ""); err == nil {
+		t.Error("Expected an error without a cache to fall back to")
+	}
+}
+
+// Test that a URL --list-path is recognized and that --list-cache keeps the fetched list in
+// the given file for a failed fetch to fall back to
+func TestListPathURLWithListCache(t *testing.T) {
+	for path, want := range map[string]bool{
+		"https://security.example.com/exploited.txt": true,
+		"http://localhost:8080/list":                 true,
+		"exploited_packages.txt":                     false,
+		"/srv/https/list.txt":                        false,
+	} {
+		if got := isListURL(path); got != want {
+			t.Errorf("isListURL(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("left-pad@1.3.0\n"))
+	}))
+	defer server.Close()
+
+	cache := filepath.Join(t.TempDir(), "ci", "exploited.txt")
+	client := &http.Client{Timeout: listFetchTimeout}
+	if _, err := fetchExploitedListCached(client, server.URL, cache); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(cache); err != nil || string(content) != "left-pad@1.3.0\n" {
+		t.Fatalf("Expected the list saved to %s, got %q (%v)", cache, content, err)
+	}
+
+	available = false
+	content, err := fetchExploitedListCached(client, server.URL, cache)
+	if err != nil || string(content) != "left-pad@1.3.0\n" {
+		t.Errorf("Expected the --list-cache copy after a failed fetch, got %q, %v", content, err)
+	}
+}
+
+// Test that the list cache honors --list-cache-dir and that --no-list-cache writes nothing
+func TestListCacheDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("left-pad@1.3.0\n"))
+	}))
+	defer server.Close()
+
+	userCache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", userCache)
+	t.Setenv("HOME", userCache)
+	client := &http.Client{Timeout: listFetchTimeout}
+
+	override := filepath.Join(t.TempDir(), "workspace-cache")
+	if _, err := fetchExploitedList(client, server.URL, resolveListCacheDir(override, false)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(override)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected one cached list in %s, got %v (%v)", override, entries, err)
+	}
+
+	if dir := resolveListCacheDir(override, true); dir != "" {
+		t.Fatalf("Expected --no-list-cache to disable the cache, got %q", dir)
+	}
+	if _, err := fetchExploitedList(client, server.URL, resolveListCacheDir("", true)); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(userCache); len(entries) != 0 {
+		t.Errorf("Expected --no-list-cache to write nothing, found %v", entries)
+	}
+}
+
+// Test that a gzipped list is decompressed as it streams in: the first entry is readable
+// while the server is still holding back the rest of a chunked response
+func TestOpenExploitedListStreamsGzip(t *testing.T) {
+	const entries = 50000
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprintln(gz, "pkg-0@1.0.0")
+		gz.Flush()
+		w.(http.Flusher).Flush()
+		<-release
+		for i := 1; i < entries; i++ {
+			fmt.Fprintf(gz, "pkg-%d@1.0.0\n", i)
+		}
+		gz.Close()
+	}))
+	defer server.Close()
+
+	client := &http.Client{Timeout: listFetchTimeout}
+	list, err := openExploitedList(client, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+
+	lines := bufio.NewScanner(list)
+	first := make(chan string)
+	go func() {
+		if lines.Scan() {
+			first <- lines.Text()
+		}
+		close(first)
+	}()
+	select {
+	case line := <-first:
+		if line != "pkg-0@1.0.0" {
+			t.Fatalf("Expected the first entry, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("Expected the first entry before the rest of the body was sent")
+	}
+	close(release)
+
+	count := 1
+	for lines.Scan() {
+		count++
+	}
+	if err := lines.Err(); err != nil || count != entries {
+		t.Errorf("Expected %d entries, got %d (%v)", entries, count, err)
+	}
+
+	// The fetched copy is the decompressed list
+	content, err := fetchExploitedList(client, server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := parseExploitedPackages(bytes.NewReader(content))
+	if err != nil || len(affected) != entries || !affected["pkg-49999"]["1.0.0"] {
+		t.Errorf("Expected %d parsed entries, got %d (%v)", entries, len(affected), err)
+	}
+}
+
+// Test that zstd-compressed lists round-trip through --list-url and --list-path, recognized
+// by magic bytes or by a .zst extension
+func TestZstdListRoundTrip(t *testing.T) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := encoder.EncodeAll([]byte("left-pad@1.3.0\n"), nil)
+	encoder.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(frame)
+	}))
+	defer server.Close()
+
+	content, err := fetchExploitedList(&http.Client{Timeout: listFetchTimeout}, server.URL, "")
+	if err != nil || string(content) != "left-pad@1.3.0\n" {
+		t.Errorf("Expected a zstd body to be decompressed, got %q (%v)", content, err)
+	}
+
+	dir := t.TempDir()
+	byMagic := filepath.Join(dir, "list.bin")
+	byName := filepath.Join(dir, "list.txt.zst")
+	plain := filepath.Join(dir, "list.txt")
+	for path, content := range map[string][]byte{byMagic: frame, byName: frame, plain: []byte("left-pad@1.3.0\n")} {
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{byMagic, byName, plain} {
+		content, err := readExploitedListFile(path)
+		if err != nil || string(content) != "left-pad@1.3.0\n" {
+			t.Errorf("Expected %s to read as the list, got %q (%v)", filepath.Base(path), content, err)
+		}
+	}
+
+	// A .zst name that is not actually zstd is an error, not an empty list
+	corrupt := filepath.Join(dir, "corrupt.txt.zst")
+	if err := os.WriteFile(corrupt, []byte("left-pad@1.3.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readExploitedListFile(corrupt); err == nil {
+		t.Error("Expected a .zst file that is not zstd to fail to decompress")
+	}
+}
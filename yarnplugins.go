@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// officialYarnPluginSources are the URL prefixes the Yarn project publishes plugins from
+var officialYarnPluginSources = []string{
+	"https://repo.yarnpkg.com/",
+	"https://raw.githubusercontent.com/yarnpkg/",
+	"https://github.com/yarnpkg/",
+}
+
+// yarnPlugin is one entry of a .yarnrc.yml plugins list
+type yarnPlugin struct {
+	Path string `yaml:"path"`
+	Spec string `yaml:"spec"`
+}
+
+// UnmarshalYAML accepts both the bare path and the path/spec mapping forms of a plugin entry
+func (p *yarnPlugin) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Path = node.Value
+		return nil
+	}
+	type plain yarnPlugin
+	return node.Decode((*plain)(p))
+}
+
+// parseYarnPlugins returns the plugins declared in a .yarnrc.yml
+func parseYarnPlugins(content []byte) ([]yarnPlugin, error) {
+	var config struct {
+		Plugins []yarnPlugin `yaml:"plugins"`
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return config.Plugins, nil
+}
+
+// checkYarnPlugin checks a plugin spec against the affected packages. Package specs are
+// matched like lockfile entries; a spec naming a listed package without a version is flagged
+// since the version that was imported is unknown. URL specs outside the Yarn project's own
+// sources are flagged as suspicious, since they run unreviewed code on every yarn command
+func checkYarnPlugin(plugin yarnPlugin, affected map[string]map[string]bool) (Package, bool) {
+	comparator := versionComparatorFor("yarn")
+	spec := plugin.Spec
+	if spec == "" {
+		return Package{}, false
+	}
+
+	if parsed, err := url.Parse(spec); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		for _, source := range officialYarnPluginSources {
+			if strings.HasPrefix(spec, source) {
+				return Package{}, false
+			}
+		}
+		return Package{
+			Name:         spec,
+			IsSuspicious: true,
+			Notice:       fmt.Sprintf("Yarn plugin %s is fetched from an unofficial URL and runs on every yarn command; review it", spec),
+		}, true
+	}
+
+	name, version := splitBunPackageKey(spec)
+	if version != "" {
+		return checkPackage(comparator, name, version, affected)
+	}
+	if _, listed := affected[name]; listed {
+		return Package{
+			Name:         name,
+			IsSuspicious: true,
+			Notice:       fmt.Sprintf("Yarn plugin %s shares its name with a compromised package and pins no version; check the plugin at %s", name, plugin.Path),
+		}, true
+	}
+	return Package{}, false
+}
+
+// scanYarnPlugins checks the plugins of every .yarnrc.yml under rootDir
+func scanYarnPlugins(rootDir string, affected map[string]map[string]bool) ([]Result, []error) {
+	var results []Result
+	var errs []error
+
+	filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip inaccessible files
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != ".yarnrc.yml" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading %s: %w", path, err))
+			return nil
+		}
+		plugins, err := parseYarnPlugins(content)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parsing %s: %w", path, err))
+			return nil
+		}
+
+		var packages []Package
+		for _, plugin := range plugins {
+			if pkg, ok := checkYarnPlugin(plugin, affected); ok {
+				packages = append(packages, pkg)
+			}
+		}
+		if len(packages) > 0 {
+			results = append(results, Result{LockFile: path, Packages: packages})
+		}
+		return nil
+	})
+
+	return results, errs
+}
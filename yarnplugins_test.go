@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that plugins referencing compromised specs or unofficial URLs are reported
+func TestScanYarnPlugins(t *testing.T) {
+	content := `yarnPath: .yarn/releases/yarn-4.0.2.cjs
+plugins:
+  - path: .yarn/plugins/@yarnpkg/plugin-interactive-tools.cjs
+    spec: "@yarnpkg/plugin-interactive-tools"
+  - path: .yarn/plugins/@yarnpkg/plugin-workspace-tools.cjs
+    spec: "https://raw.githubusercontent.com/yarnpkg/berry/master/packages/plugin-workspace-tools/bin/%40yarnpkg/plugin-workspace-tools.js"
+  - path: .yarn/plugins/yarn-plugin-outdated.cjs
+    spec: "yarn-plugin-outdated@2.3.0"
+  - path: .yarn/plugins/plugin-env.cjs
+    spec: "https://example.com/plugin-env.js"
+  - .yarn/plugins/local-plugin.cjs
+`
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".yarnrc.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	affected := map[string]map[string]bool{"yarn-plugin-outdated": {"2.3.0": true}}
+	results, errs := scanYarnPlugins(root, affected)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(results) != 1 || len(results[0].Packages) != 2 {
+		t.Fatalf("Expected two plugin findings, got %+v", results)
+	}
+
+	compromised := results[0].Packages[0]
+	if !compromised.IsAffected || compromised.Name != "yarn-plugin-outdated" || compromised.Version != "2.3.0" {
+		t.Errorf("Expected yarn-plugin-outdated@2.3.0 to be compromised, got %+v", compromised)
+	}
+	unofficial := results[0].Packages[1]
+	if !unofficial.IsSuspicious || unofficial.Name != "https://example.com/plugin-env.js" {
+		t.Errorf("Expected the unofficial plugin URL to be suspicious, got %+v", unofficial)
+	}
+	if resultManager(results[0].LockFile, nil) != "yarn" {
+		t.Errorf("Expected .yarnrc.yml findings to belong to yarn")
+	}
+}